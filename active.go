@@ -0,0 +1,88 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// activeDataConn is a data connection of active mode. It only holds the
+// listener the server connects to until accept is called, after the reply to
+// the transfer command.
+type activeDataConn struct {
+	net.Conn // nil until accept
+	listener *net.TCPListener
+	c        *ServerConn
+}
+
+// listenActive listens on the address of the control connection, or the one
+// set with DialWithActiveIP, and announces it to the server.
+func (c *ServerConn) listenActive() (*activeDataConn, error) {
+	host, err := c.activeHost()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	listener := l.(*net.TCPListener)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	if _, _, err := c.exchange(StatusCommandOK, "%s", portCommand(host, port)); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return &activeDataConn{listener: listener, c: c}, nil
+}
+
+// activeHost returns the host active mode listens on
+func (c *ServerConn) activeHost() (string, error) {
+	if c.options.activeIP != nil {
+		return c.options.activeIP.String(), nil
+	}
+	host, _, err := net.SplitHostPort(c.netConn.LocalAddr().String())
+	return host, err
+}
+
+// accept waits for the server to connect, for the timeout of the dialer at
+// most, and checks that the peer is the server.
+func (a *activeDataConn) accept() error {
+	defer a.listener.Close()
+
+	if timeout := a.c.options.dialer.Timeout; timeout > 0 {
+		if err := a.listener.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+	}
+
+	conn, err := a.listener.Accept()
+	if err != nil {
+		return err
+	}
+	if err := a.c.checkDataPeer(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	// The client still is the TLS client of the data connection
+	if a.c.options.tlsConfig != nil {
+		config := a.c.options.tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = a.c.host
+		}
+		conn = tls.Client(conn, config)
+	}
+
+	a.Conn = conn
+	return nil
+}
+
+func (a *activeDataConn) Close() error {
+	err := a.listener.Close()
+	if a.Conn != nil {
+		err = a.Conn.Close()
+	}
+	return err
+}
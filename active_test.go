@@ -0,0 +1,85 @@
+package ftp
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataConnModes(t *testing.T) {
+	tests := []struct {
+		addr    string
+		active  bool
+		command string
+	}{
+		{"127.0.0.1", false, "EPSV"},
+		{"127.0.0.1", true, "PORT"},
+		{"::1", false, "EPSV"},
+		{"::1", true, "EPRT"},
+	}
+
+	for _, test := range tests {
+		mock, c := openConn(t, test.addr, DialWithActiveMode(test.active))
+
+		assert.NoError(t, c.Stor("file", strings.NewReader(testData)), test.addr)
+
+		r, err := c.Retr("file")
+		if assert.NoError(t, err, test.addr) {
+			buf, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, testData, string(buf))
+			assert.NoError(t, r.Close())
+		}
+
+		_, err = c.List("")
+		assert.NoError(t, err, test.addr)
+
+		closeConn(t, mock, c, []string{test.command, "STOR", test.command, "RETR", test.command, "LIST"})
+	}
+}
+
+func TestActiveModeRefused(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "no-port", DialWithActiveMode(true))
+
+	_, err := c.List("")
+	assert.Error(t, err)
+
+	closeConn(t, mock, c, []string{"PORT"})
+}
+
+func TestActiveIP(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"PORT": "500 Illegal PORT command.",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s, DialWithActiveMode(true), DialWithActiveIP(net.IPv4(127, 0, 0, 2)))
+
+	_, err := c.List("/")
+	assert.Error(t, err)
+
+	commands := s.Commands()
+	if assert.NotEmpty(t, commands) {
+		assert.True(t, strings.HasPrefix(commands[len(commands)-1], "PORT 127,0,0,2,"), commands)
+	}
+	assert.NoError(t, c.Quit())
+}
+
+func TestConnAddrs(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	assert.Equal(t, s.Addr, c.RemoteAddr().String())
+	local, ok := c.LocalAddr().(*net.TCPAddr)
+	if assert.True(t, ok) {
+		assert.True(t, local.IP.IsLoopback())
+		assert.NotZero(t, local.Port)
+	}
+	assert.NoError(t, c.Quit())
+}
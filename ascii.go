@@ -0,0 +1,167 @@
+package ftp
+
+import (
+	"io"
+	"net"
+)
+
+// TransferType is the representation type of the transferred files.
+type TransferType int
+
+// The transfer types supported by Retr, Stor and Append
+const (
+	// TransferTypeBinary transfers the files as is, with TYPE I.
+	TransferTypeBinary TransferType = iota
+	// TransferTypeASCII transfers text files with TYPE A: the server converts
+	// its line endings and record boundaries to CRLF, which the client
+	// converts to LF.
+	TransferTypeASCII
+	// TransferTypeEBCDIC transfers the files with TYPE E, in the EBCDIC
+	// character set of z/OS servers, which then do not translate them.
+	TransferTypeEBCDIC
+)
+
+// String returns the argument of the TYPE command for t.
+func (t TransferType) String() string {
+	switch t {
+	case TransferTypeASCII:
+		return "A"
+	case TransferTypeEBCDIC:
+		return "E"
+	}
+	return "I"
+}
+
+// DialWithTransferType returns a DialOption that sets the default transfer
+// type of Retr, Stor and Append. It can be overridden for a single transfer
+// with TransferWithType. The default is TransferTypeBinary.
+//
+// In ASCII mode, the data is converted on the fly: CRLF line endings become
+// LF when reading, and LF becomes CRLF when writing.
+func DialWithTransferType(t TransferType) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.transferType = t
+	}}
+}
+
+// TransferWithType returns a TransferOption that sets the transfer type of a
+// single transfer. See DialWithTransferType.
+//
+// Offsets given to RetrFrom and StorFrom count the bytes as sent by the
+// server, which differ from the ones of the file in ASCII mode.
+func TransferWithType(t TransferType) TransferOption {
+	return TransferOption{func(to *transferOptions) {
+		to.transferType = t
+		to.transferTypeSet = true
+	}}
+}
+
+// transferType returns the transfer type of a transfer with options to
+func (c *ServerConn) transferType(to *transferOptions) TransferType {
+	if to.transferTypeSet {
+		return to.transferType
+	}
+	return c.options.transferType
+}
+
+// setType issues a TYPE FTP command, unless the server already uses t.
+func (c *ServerConn) setType(t TransferType) error {
+	if err := c.acquire(); err != nil {
+		return err
+	}
+	defer c.release()
+
+	if c.currentType == t {
+		return nil
+	}
+	if _, _, err := c.exchange(StatusCommandOK, "TYPE %s", t); err != nil {
+		return err
+	}
+	c.currentType = t
+	return nil
+}
+
+// asciiConn converts the CRLF line endings read from a TYPE A data
+// connection to LF. A CR is only dropped when followed by a LF, even if the
+// LF comes with the next read.
+type asciiConn struct {
+	net.Conn
+	buf []byte // converted bytes not returned yet
+	cr  bool   // the last byte read is a CR, not converted yet
+	err error  // error of the last read from the connection
+
+	// raw receives the bytes read, after room for a pending CR, and holds
+	// the converted bytes in place
+	raw [4097]byte
+}
+
+func (c *asciiConn) Read(buf []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			if c.cr {
+				// A CR at the end of the file is not a line ending
+				c.cr = false
+				c.buf = append(c.raw[:0], '\r')
+				break
+			}
+			return 0, c.err
+		}
+
+		var n int
+		n, c.err = c.Conn.Read(c.raw[1:])
+		c.buf = c.convert(c.raw[1 : 1+n])
+	}
+
+	n := copy(buf, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// convert replaces CRLF with LF in data, which is right after the first byte
+// of raw. The result is written to the start of raw: it is at most one byte
+// longer than data, because of the CR pending from the previous read.
+func (c *asciiConn) convert(data []byte) []byte {
+	out := c.raw[:0]
+	for _, b := range data {
+		if c.cr {
+			c.cr = false
+			if b != '\n' {
+				out = append(out, '\r')
+			}
+		}
+		if b == '\r' {
+			c.cr = true
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// asciiWriter converts the LF line endings written to w to CRLF. Existing
+// CRLF line endings are kept as is.
+type asciiWriter struct {
+	w  io.Writer
+	cr bool // the last byte written is a CR
+}
+
+func (w *asciiWriter) Write(buf []byte) (int, error) {
+	start := 0
+	for i, b := range buf {
+		if b == '\n' && !w.cr {
+			if _, err := w.w.Write(buf[start:i]); err != nil {
+				return start, err
+			}
+			if _, err := w.w.Write([]byte("\r\n")); err != nil {
+				return i, err
+			}
+			start = i + 1
+		}
+		w.cr = b == '\r'
+	}
+
+	if _, err := w.w.Write(buf[start:]); err != nil {
+		return start, err
+	}
+	return len(buf), nil
+}
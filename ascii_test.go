@@ -0,0 +1,81 @@
+package ftp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readerConn is a net.Conn reading from r
+type readerConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *readerConn) Read(buf []byte) (int, error) {
+	return c.r.Read(buf)
+}
+
+func TestASCIIConn(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"a\r\nb\r\n":       "a\nb\n",
+		"no line ending":   "no line ending",
+		"lone\rcr\r\r\n":   "lone\rcr\r\n",
+		"trailing cr\r":    "trailing cr\r",
+		"unix\nlines\n":    "unix\nlines\n",
+		"\r\n\r\n\r\r\n\n": "\n\n\r\n\n",
+	}
+
+	for input, expected := range tests {
+		// OneByteReader splits every CRLF across two reads
+		for _, r := range []io.Reader{bytes.NewBufferString(input), iotest.OneByteReader(bytes.NewBufferString(input))} {
+			data, err := ioutil.ReadAll(&asciiConn{Conn: &readerConn{r: r}})
+			assert.NoError(t, err)
+			assert.Equal(t, expected, string(data), "input %q", input)
+		}
+	}
+}
+
+func TestASCIIWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &asciiWriter{w: &buf}
+	for _, chunk := range []string{"a\nb\r", "\nc\n", "\n", "d"} {
+		n, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.Equal(t, "a\r\nb\r\nc\r\n\r\nd", buf.String())
+}
+
+func TestTransferTypeASCII(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithTransferType(TransferTypeASCII))
+
+	err := c.Stor("file", bytes.NewBufferString("a\nb\r\nc\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a\r\nb\r\nc\r\n", mock.fileCont.String())
+
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "a\nb\nc\n", string(data))
+		assert.NoError(t, r.Close())
+	}
+
+	// binary transfers switch back to TYPE I
+	r, err = c.Retr("file", TransferWithType(TransferTypeBinary))
+	if assert.NoError(t, err) {
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "a\r\nb\r\nc\r\n", string(data))
+		assert.NoError(t, r.Close())
+	}
+
+	closeConn(t, mock, c, []string{"TYPE", "EPSV", "STOR", "EPSV", "RETR", "TYPE", "EPSV", "RETR"})
+}
@@ -0,0 +1,123 @@
+package ftp
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// defaultBufferSize is the size of the buffers of the transfers, unless set
+// with DialWithBufferSize.
+const defaultBufferSize = 128 << 10
+
+// bufferPools maps the sizes of the buffers to the *sync.Pool of *[]byte
+// holding them, shared by the connections.
+var bufferPools sync.Map
+
+// DialWithBufferSize returns a DialOption that sets the size of the buffers
+// used to copy the data of the transfers, 128 KiB by default. The buffers
+// are pooled, and shared by the connections using the same size.
+func DialWithBufferSize(size int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.bufferSize = size
+	}}
+}
+
+// bufferPool returns the pool of the buffers of the given size.
+func bufferPool(size int) *sync.Pool {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	if pool, ok := bufferPools.Load(size); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := bufferPools.LoadOrStore(size, &sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, size)
+			return &buf
+		},
+	})
+	return pool.(*sync.Pool)
+}
+
+// copyBuffer copies src to dst like io.Copy, with a buffer of pool when
+// neither of them can do without.
+func copyBuffer(pool *sync.Pool, dst io.Writer, src io.Reader) (int64, error) {
+	buf := pool.Get().(*[]byte)
+	defer pool.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// WriteTo implements the io.WriterTo interface, so that io.Copy reads the
+// data connection with a pooled buffer. When the download is neither
+// limited nor followed, and w is an io.ReaderFrom such as an *os.File, the
+// data connection is handed to w, which may then receive the data without
+// copying it through user space where the platform allows.
+func (r *Response) WriteTo(w io.Writer) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok && r.direct() {
+		// The first bytes go through Read, which times them for Stats
+		written, err := r.writeFirst(w)
+		if err != nil || r.readErr == io.EOF {
+			return written, err
+		}
+
+		n, err := rf.ReadFrom(r.conn.(*throttledConn).Conn)
+		r.count(n)
+		r.readErr = err
+		if err == nil {
+			r.readErr = io.EOF
+		}
+		return written + n, err
+	}
+	return copyBuffer(r.c.buffers, w, struct{ io.Reader }{r})
+}
+
+// writeFirst copies the result of a single Read to w, until some data was
+// received.
+func (r *Response) writeFirst(w io.Writer) (int64, error) {
+	if r.BytesRead() > 0 {
+		return 0, nil
+	}
+
+	buf := r.c.buffers.Get().(*[]byte)
+	defer r.c.buffers.Put(buf)
+
+	n, err := r.Read(*buf)
+	if n > 0 {
+		written, errWrite := w.Write((*buf)[:n])
+		if errWrite != nil {
+			return int64(written), errWrite
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return int64(n), err
+}
+
+// direct reports whether the data connection can be read directly, without
+// going through Read.
+func (r *Response) direct() bool {
+	if r.err != nil || r.progress != nil {
+		return false
+	}
+	if r.download {
+		if remaining, _ := r.c.downloadLimit(r.maxBytes, r.BytesRead()); remaining >= 0 {
+			return false
+		}
+	}
+	conn, ok := r.conn.(*throttledConn)
+	return ok && conn.limiter.burst() == 0
+}
+
+// ReadFrom implements the io.ReaderFrom interface, so that the uploads of
+// files may use sendfile when the throughput is not limited. The other
+// readers are copied with a pooled buffer.
+func (c *throttledConn) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := c.Conn.(io.ReaderFrom); ok && c.limiter.burst() == 0 {
+		if _, ok := r.(*os.File); ok {
+			return rf.ReadFrom(r)
+		}
+	}
+	return copyBuffer(c.buffers, struct{ io.Writer }{c}, r)
+}
@@ -0,0 +1,121 @@
+package ftp
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+)
+
+const benchmarkFileSize = 8 << 20
+
+// zeroReader is an endless reader which is neither an io.WriterTo nor an
+// *os.File, so that copying it needs a buffer.
+type zeroReader struct{}
+
+func (zeroReader) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return len(buf), nil
+}
+
+func benchmarkServer(b *testing.B) (*ftptest.Server, *ServerConn) {
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{
+			"/bench": make([]byte, benchmarkFileSize),
+		},
+	})
+	b.Cleanup(s.Close)
+
+	c, err := Dial(s.Addr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := c.Login("anonymous", "anonymous"); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = c.Quit() })
+	return s, c
+}
+
+func benchmarkRetr(b *testing.B, w io.Writer) {
+	_, c := benchmarkServer(b)
+
+	b.SetBytes(benchmarkFileSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := c.Retr("/bench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			b.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			b.Fatal(err)
+		}
+		if f, ok := w.(*os.File); ok {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkRetr(b *testing.B) {
+	// Hide the io.ReaderFrom of io.Discard
+	benchmarkRetr(b, struct{ io.Writer }{io.Discard})
+}
+
+func BenchmarkRetrToFile(b *testing.B) {
+	f, err := os.Create(filepath.Join(b.TempDir(), "bench"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	benchmarkRetr(b, f)
+}
+
+func BenchmarkStor(b *testing.B) {
+	_, c := benchmarkServer(b)
+
+	b.SetBytes(benchmarkFileSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Stor("/upload", io.LimitReader(zeroReader{}, benchmarkFileSize)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStorFromFile(b *testing.B) {
+	_, c := benchmarkServer(b)
+
+	name := filepath.Join(b.TempDir(), "bench")
+	if err := os.WriteFile(name, make([]byte, benchmarkFileSize), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	b.SetBytes(benchmarkFileSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			b.Fatal(err)
+		}
+		if err := c.Stor("/upload", f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
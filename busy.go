@@ -0,0 +1,61 @@
+package ftp
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrConcurrentUse is returned when an operation is started on a ServerConn
+// while another goroutine is exchanging with the server over it.
+var ErrConcurrentUse = errors.New("concurrent use of the connection")
+
+// DialWithSerialization returns a DialOption that makes the operations
+// started while the connection is busy wait for it to be available, instead
+// of failing with ErrConcurrentUse.
+//
+// The operations of several goroutines are then interleaved command by
+// command, so the goroutines should not depend on a state such as the
+// current directory. A goroutine calling the connection while it has a
+// transfer in progress, e.g. from the callback of ListEach, waits forever.
+func DialWithSerialization(enabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.serialize = enabled
+	}}
+}
+
+// acquire marks the control connection as busy for an exchange with the
+// server: a command and its reply, or a transfer from its command until its
+// final reply. It fails with ErrConcurrentUse if the connection is already
+// busy, unless the operations are serialized, with net.ErrClosed once Quit
+// was called, and with ErrServerClosed once the server closed the
+// connection.
+func (c *ServerConn) acquire() error {
+	if c.closed {
+		return net.ErrClosed
+	}
+	if c.options.serialize {
+		c.busy <- struct{}{}
+	} else {
+		select {
+		case c.busy <- struct{}{}:
+		default:
+			return ErrConcurrentUse
+		}
+	}
+
+	// The server may have closed the connection during the exchange of
+	// another goroutine, e.g. the keepalive
+	if c.closedErr != nil {
+		<-c.busy
+		return c.closedErr
+	}
+	return nil
+}
+
+// release marks the control connection as available again.
+func (c *ServerConn) release() {
+	c.transfer = nil
+	c.lastUsed = time.Now()
+	<-c.busy
+}
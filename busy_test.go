@@ -0,0 +1,114 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+const busyListing = "-rw-r--r--    1 ftp      ftp            14 Dec 02  2009 file\r\n"
+
+func dialTestServer(t *testing.T, options ...DialOption) (*ftptest.Server, *ServerConn) {
+	s := ftptest.NewServer(ftptest.Script{
+		Listings: map[string]string{"/": busyListing},
+		Files:    map[string][]byte{"file": []byte(testData)},
+	})
+	return s, dialScript(t, s, options...)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	s, c := dialTestServer(t)
+	defer s.Close()
+
+	r, err := c.Retr("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.List("/")
+	assert.True(t, errors.Is(err, ErrConcurrentUse), err)
+	assert.True(t, errors.Is(c.NoOp(), ErrConcurrentUse))
+
+	buf, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, testData, string(buf))
+	assert.NoError(t, r.Close())
+
+	// Available again once the transfer completed
+	_, err = c.List("/")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Quit())
+}
+
+// hammer runs operations on c from several goroutines, and returns the
+// number of operations which failed with ErrConcurrentUse.
+func hammer(t *testing.T, c *ServerConn) int {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		refusals int
+	)
+
+	ops := []func() error{
+		c.NoOp,
+		func() error {
+			_, err := c.List("/")
+			return err
+		},
+		func() error {
+			r, err := c.Retr("file")
+			if err != nil {
+				return err
+			}
+			if _, err := io.ReadAll(r); err != nil {
+				return r.stop(err)
+			}
+			return r.Close()
+		},
+	}
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				err := ops[(i+j)%len(ops)]()
+				if errors.Is(err, ErrConcurrentUse) {
+					mu.Lock()
+					refusals++
+					mu.Unlock()
+					continue
+				}
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return refusals
+}
+
+func TestConcurrentUseHammer(t *testing.T) {
+	s, c := dialTestServer(t)
+	defer s.Close()
+
+	hammer(t, c)
+
+	// The replies were not mixed up
+	assert.NoError(t, c.NoOp())
+	_, err := c.List("/")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Quit())
+}
+
+func TestDialWithSerialization(t *testing.T) {
+	s, c := dialTestServer(t, DialWithSerialization(true))
+	defer s.Close()
+
+	assert.Equal(t, 0, hammer(t, c))
+	assert.NoError(t, c.Quit())
+}
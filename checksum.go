@@ -0,0 +1,191 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ErrCommandNotSupported is returned when the server does not advertise the
+// commands needed for an operation.
+var ErrCommandNotSupported = errors.New("command not supported by the server")
+
+// HashAlgorithm is a hash function computed by the server for Checksum.
+type HashAlgorithm int
+
+// The hash algorithms supported by Checksum
+const (
+	HashCRC32 HashAlgorithm = iota
+	HashMD5
+	HashSHA1
+	HashSHA256
+	HashSHA512
+)
+
+var hashAlgorithms = [...]struct {
+	name    string // name in the HASH command
+	command string // legacy command
+	size    int    // length of the hexadecimal digest
+}{
+	HashCRC32:  {"CRC32", "XCRC", 8},
+	HashMD5:    {"MD5", "XMD5", 32},
+	HashSHA1:   {"SHA-1", "XSHA1", 40},
+	HashSHA256: {"SHA-256", "XSHA256", 64},
+	HashSHA512: {"SHA-512", "XSHA512", 128},
+}
+
+// String returns the name of the algorithm in the HASH command, or
+// "unknown(n)" for a value which is not one of the constants.
+func (h HashAlgorithm) String() string {
+	if !h.valid() {
+		return "unknown(" + strconv.Itoa(int(h)) + ")"
+	}
+	return hashAlgorithms[h].name
+}
+
+// valid reports whether h is one of the constants
+func (h HashAlgorithm) valid() bool {
+	return h >= 0 && int(h) < len(hashAlgorithms)
+}
+
+// errInvalidHash is returned for a HashAlgorithm which is not one of the
+// constants
+func errInvalidHash(h HashAlgorithm) error {
+	return fmt.Errorf("invalid hash algorithm %d", int(h))
+}
+
+// Checksum returns the hexadecimal digest of the file at path computed by the
+// server, to check a transfer without downloading the file again.
+//
+// The HASH command is used when the server advertises it with the requested
+// algorithm, selecting it with OPTS HASH if needed. The XCRC, XMD5, XSHA1,
+// XSHA256 and XSHA512 commands are used otherwise, when FEAT or the result
+// of Help lists them. ErrCommandNotSupported is returned if the server
+// advertises none of them.
+func (c *ServerConn) Checksum(path string, algo HashAlgorithm) (string, error) {
+	if !algo.valid() {
+		return "", errInvalidHash(algo)
+	}
+	if c.hashSupported(algo) {
+		return c.hash(path, algo, "")
+	}
+
+	command := hashAlgorithms[algo].command
+	_, ok := c.features[command]
+	if listed, _ := helpLists(c.help, command); !ok && !listed {
+		return "", ErrCommandNotSupported
+	}
+
+	code, msg, err := c.cmd(-1, "%s %s", command, path)
+	if err != nil {
+		return "", err
+	}
+	if code/100 != 2 {
+		return "", &textproto.Error{Code: code, Msg: msg}
+	}
+
+	return parseDigest(msg, hashAlgorithms[algo].size)
+}
+
+// ChecksumRange is like Checksum for the bytes of the file from start up to
+// end excluded, which must not be empty. It requires the HASH command.
+func (c *ServerConn) ChecksumRange(path string, algo HashAlgorithm, start, end uint64) (string, error) {
+	if !algo.valid() {
+		return "", errInvalidHash(algo)
+	}
+	if end <= start {
+		return "", fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	if !c.hashSupported(algo) {
+		return "", ErrCommandNotSupported
+	}
+
+	// RANG takes the offset of the last byte
+	return c.hash(path, algo, fmt.Sprintf("%d %d", start, end-1))
+}
+
+// hashSupported reports whether the HASH command supports algo
+func (c *ServerConn) hashSupported(algo HashAlgorithm) bool {
+	for _, name := range strings.Split(c.features["HASH"], ";") {
+		if strings.EqualFold(strings.TrimSuffix(name, "*"), algo.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// hash issues a HASH FTP command, for the range rang if it is not empty.
+func (c *ServerConn) hash(path string, algo HashAlgorithm, rang string) (string, error) {
+	if err := c.selectHash(algo); err != nil {
+		return "", err
+	}
+
+	if rang != "" {
+		if _, _, err := c.cmd(StatusRequestFilePending, "RANG %s", rang); err != nil {
+			return "", err
+		}
+	}
+
+	_, msg, err := c.cmd(StatusFile, "HASH %s", path)
+	if err != nil {
+		return "", err
+	}
+
+	// The reply is made of the algorithm, the range, the digest and the
+	// path: SHA-256 0-49 169cd22282da7f147cb491e559e9dd filename
+	fields := strings.Fields(msg)
+	if len(fields) < 3 || !strings.EqualFold(fields[0], algo.String()) {
+		return "", fmt.Errorf("invalid HASH response: %q", msg)
+	}
+	return parseDigest(fields[2], hashAlgorithms[algo].size)
+}
+
+// selectHash makes algo the algorithm of the HASH command with an OPTS HASH
+// command, unless it is already selected. The selected algorithm is marked
+// with a star in the list advertised by FEAT.
+func (c *ServerConn) selectHash(algo HashAlgorithm) error {
+	names := strings.Split(c.features["HASH"], ";")
+	for _, name := range names {
+		if strings.EqualFold(name, algo.String()+"*") {
+			return nil
+		}
+	}
+
+	if _, _, err := c.cmd(StatusCommandOK, "OPTS HASH %s", algo); err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		name = strings.TrimSuffix(name, "*")
+		if strings.EqualFold(name, algo.String()) {
+			name += "*"
+		}
+		names[i] = name
+	}
+	c.features["HASH"] = strings.Join(names, ";")
+	return nil
+}
+
+// parseDigest finds a hexadecimal digest of size digits in the reply to a
+// checksum command. Servers may quote it or add the path of the file.
+func parseDigest(msg string, size int) (string, error) {
+	for _, field := range strings.Fields(msg) {
+		field = strings.Trim(field, `"'`)
+		if len(field) == size && isHex(field) {
+			return strings.ToLower(field), nil
+		}
+	}
+	return "", fmt.Errorf("no digest in response: %q", msg)
+}
+
+// isHex reports whether str only contains hexadecimal digits
+func isHex(str string) bool {
+	for _, r := range str {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksum(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	// the selected algorithm
+	digest, err := c.Checksum("file", HashSHA1)
+	assert.NoError(t, err)
+	assert.Equal(t, "76ed2f7fa6a1dd5d2cd1e7ff4a3b28f46d8ac6c3", digest)
+
+	// another algorithm needs to be selected first, once
+	digest, err = c.Checksum("file", HashSHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, "25a9bca5d1d2b2f1d9fe6b1ef0d0c4d2f95e1dd3a3f4b87a0e1a1be14c53ee63", digest)
+	_, err = c.ChecksumRange("file", HashSHA256, 0, 14)
+	assert.NoError(t, err)
+
+	// legacy command
+	digest, err = c.Checksum("file", HashCRC32)
+	assert.NoError(t, err)
+	assert.Equal(t, "b7cf6c9a", digest)
+
+	_, err = c.Checksum("file", HashSHA512)
+	assert.Equal(t, ErrCommandNotSupported, err)
+	_, err = c.ChecksumRange("file", HashCRC32, 0, 14)
+	assert.Equal(t, ErrCommandNotSupported, err)
+
+	// refused without being sent
+	_, err = c.Checksum("file", HashAlgorithm(42))
+	assert.Error(t, err)
+	_, err = c.ChecksumRange("file", HashAlgorithm(-1), 0, 14)
+	assert.Error(t, err)
+	_, err = c.ChecksumRange("file", HashSHA256, 0, 0)
+	assert.Error(t, err)
+	_, err = c.ChecksumRange("file", HashSHA256, 14, 10)
+	assert.Error(t, err)
+	assert.Equal(t, "unknown(42)", HashAlgorithm(42).String())
+
+	closeConn(t, mock, c, []string{"HASH", "OPTS", "HASH", "RANG", "HASH", "XCRC"})
+}
+
+func TestParseDigest(t *testing.T) {
+	tests := []struct {
+		msg    string
+		digest string
+	}{
+		{"B7CF6C9A", "b7cf6c9a"},
+		{`"B7CF6C9A"`, "b7cf6c9a"},
+		{"XCRC successful B7CF6C9A", "b7cf6c9a"},
+		{"/pub/file.txt B7CF6C9A", "b7cf6c9a"},
+		{"B7CF6C9A /pub/cafe1234", "b7cf6c9a"},
+	}
+
+	for _, test := range tests {
+		digest, err := parseDigest(test.msg, 8)
+		if assert.NoError(t, err, test.msg) {
+			assert.Equal(t, test.digest, digest, test.msg)
+		}
+	}
+
+	_, err := parseDigest("not a digest", 8)
+	assert.Error(t, err)
+}
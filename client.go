@@ -0,0 +1,71 @@
+package ftp
+
+import (
+	"io"
+	"time"
+)
+
+// Client is the set of operations of a ServerConn, so that the code using
+// them can be tested against a fake implementation instead of a server.
+// NewClient returns the Client of a ServerConn.
+//
+// Retr and RetrFrom return an io.ReadCloser rather than a *Response, which
+// can only be obtained from a ServerConn: fakes can return any reader, e.g.
+// io.NopCloser(strings.NewReader("content")).
+type Client interface {
+	Login(user, password string) error
+	Logout() error
+	Quit() error
+	NoOp() error
+
+	ChangeDir(path string) error
+	ChangeDirToParent() error
+	CurrentDir() (string, error)
+	MakeDir(path string) error
+	MakeDirAll(dir string) error
+	RemoveDir(path string) error
+	RemoveDirRecur(dir string) error
+
+	List(path string) ([]*Entry, error)
+	NameList(path string) ([]string, error)
+	Walk(root string) *Walker
+
+	FileSize(path string) (int64, error)
+	GetTime(path string) (time.Time, error)
+	SetTime(path string, t time.Time) error
+	Rename(from, to string) error
+	Delete(path string) error
+
+	Retr(path string, options ...TransferOption) (io.ReadCloser, error)
+	RetrFrom(path string, offset uint64, options ...TransferOption) (io.ReadCloser, error)
+	Stor(path string, r io.Reader, options ...TransferOption) error
+	StorFrom(path string, r io.Reader, offset uint64, options ...TransferOption) error
+	Append(path string, r io.Reader, options ...TransferOption) error
+}
+
+// NewClient returns c as a Client. The readers returned by its Retr and
+// RetrFrom methods are the *Response of c.
+func NewClient(c *ServerConn) Client {
+	return serverClient{c}
+}
+
+// serverClient is the Client of a ServerConn
+type serverClient struct {
+	*ServerConn
+}
+
+func (c serverClient) Retr(path string, options ...TransferOption) (io.ReadCloser, error) {
+	r, err := c.ServerConn.Retr(path, options...)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (c serverClient) RetrFrom(path string, offset uint64, options ...TransferOption) (io.ReadCloser, error) {
+	r, err := c.ServerConn.RetrFrom(path, offset, options...)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
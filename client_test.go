@@ -2,7 +2,10 @@ package ftp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"net"
 	"net/textproto"
@@ -11,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jlaffaye/ftp/ftptest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -263,6 +267,68 @@ func TestWrongLogin(t *testing.T) {
 	}
 }
 
+// dialMock returns a client connected to a mock server, not logged in
+func dialMock(t *testing.T) (*ftpMock, *ServerConn) {
+	mock, err := newFtpMock(t, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := DialTimeout(mock.Addr(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock, c
+}
+
+func TestLoginWithAccount(t *testing.T) {
+	mock, c := dialMock(t)
+	defer mock.Close()
+
+	err := c.Login("acct", "secret")
+	assert.Error(t, err)
+
+	assert.NoError(t, c.LoginWithAccount("acct", "secret", "ACC123"))
+	assert.NoError(t, c.NoOp())
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+	assert.Equal(t, []string{"USER", "PASS", "USER", "PASS", "ACCT", "FEAT", "TYPE", "OPTS", "NOOP", "QUIT"}, mock.commands)
+}
+
+func TestLoginWrongAccount(t *testing.T) {
+	mock, c := dialMock(t)
+	defer mock.Close()
+
+	err := c.LoginWithAccount("acct", "secret", "WRONG")
+	var protoErr *textproto.Error
+	if assert.True(t, errors.As(err, &protoErr)) {
+		assert.Equal(t, StatusNotLoggedIn, protoErr.Code)
+	}
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestLoginSeparateReplies(t *testing.T) {
+	mock, c := dialMock(t)
+	defer mock.Close()
+
+	assert.NoError(t, c.Login("chatty", "secret"))
+	assert.NoError(t, c.NoOp())
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+	assert.Equal(t, []string{"USER", "PASS", "FEAT", "TYPE", "OPTS", "NOOP", "QUIT"}, mock.commands)
+}
+
+func TestAnonymousLogin(t *testing.T) {
+	mock, c := dialMock(t)
+	defer mock.Close()
+
+	assert.NoError(t, c.AnonymousLogin())
+	assert.NoError(t, c.Quit())
+}
+
 func TestDeleteDirRecur(t *testing.T) {
 	mock, c := openConn(t, "127.0.0.1")
 
@@ -328,6 +394,249 @@ func TestListCurrentDir(t *testing.T) {
 	mock.Wait()
 }
 
+func TestListClock(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Listings: map[string]string{
+			"/": "-rw-r--r-- 1 user group 1 Dec 02 10:00 a\r\n",
+		},
+	})
+	defer s.Close()
+
+	for _, test := range []struct {
+		now  time.Time
+		year int
+	}{
+		{time.Date(2021, time.January, 15, 0, 0, 0, 0, time.UTC), 2020},
+		{time.Date(2021, time.December, 15, 0, 0, 0, 0, time.UTC), 2021},
+	} {
+		now := test.now
+		c := dialScript(t, s, DialWithClock(func() time.Time { return now }))
+		entries, err := c.List("/")
+		if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+			assert.Equal(t, time.Date(test.year, time.December, 2, 10, 0, 0, 0, time.UTC), entries[0].Time)
+		}
+		assert.NoError(t, c.Quit())
+	}
+}
+
+func TestListFiltered(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	entries, skipped, err := c.ListFiltered("vms")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	if assert.Len(t, skipped, 2) {
+		assert.Equal(t, "Directory DISK$USER:[JDOE]", skipped[0].Line)
+		assert.Equal(t, errUnsupportedListLine, skipped[0].Err)
+		assert.Equal(t, "Total of 3 files, 20 blocks.", skipped[1].Line)
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestListStrict(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithStrictList(true))
+
+	_, err := c.List("vms")
+	var lineErr *ListLineError
+	if assert.True(t, errors.As(err, &lineErr)) {
+		assert.Equal(t, "Directory DISK$USER:[JDOE]", lineErr.Line)
+	}
+	assert.True(t, errors.Is(err, errUnsupportedListLine))
+
+	// the block count header and blank lines are not errors
+	entries, err := c.List("unix")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "pub", entries[0].Name)
+		assert.Equal(t, "b.txt", entries[1].Name)
+		assert.Equal(t, "a.txt", entries[2].Name)
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestListEach(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	var names []string
+	err := c.ListEach("unix", func(e *Entry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pub", "b.txt", "a.txt"}, names)
+
+	// stopping early aborts the transfer
+	errStop := errors.New("stop")
+	names = nil
+	err = c.ListEach("unix", func(e *Entry) error {
+		names = append(names, e.Name)
+		return errStop
+	})
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, []string{"pub"}, names)
+
+	// unparsable lines are skipped
+	names = nil
+	err = c.ListEach("vms", func(e *Entry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, names, 3)
+
+	var lines []string
+	err = c.NameListEach("", func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/incoming"}, lines)
+
+	err = c.NameListEach("", func(line string) error {
+		return errStop
+	})
+	assert.Equal(t, errStop, err)
+
+	closeConn(t, mock, c, []string{"EPSV", "LIST", "EPSV", "LIST", "ABOR", "EPSV", "LIST", "EPSV", "NLST", "EPSV", "NLST", "ABOR"})
+}
+
+func TestListEachStrict(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithStrictList(true))
+
+	called := false
+	err := c.ListEach("vms", func(e *Entry) error {
+		called = true
+		return nil
+	})
+	var lineErr *ListLineError
+	if assert.True(t, errors.As(err, &lineErr)) {
+		assert.Equal(t, "Directory DISK$USER:[JDOE]", lineErr.Line)
+	}
+	assert.False(t, called)
+
+	closeConn(t, mock, c, []string{"EPSV", "LIST", "ABOR"})
+}
+
+func TestListVmsContinuation(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	entries, _, err := c.ListFiltered("vms")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "DATA", entries[0].Name)
+		assert.Equal(t, "A_RATHER_LONG_FILE_NAME.TXT", entries[1].Name)
+		assert.Equal(t, uint64(17*512), entries[1].Size)
+		assert.Equal(t, "LOGIN.COM", entries[2].Name)
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestListRawLines(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	entries, err := c.List("unix")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub", entries[0].Raw)
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+
+	mock, c = openConn(t, "127.0.0.1", DialWithDisabledRawLines(true))
+
+	entries, err = c.List("unix")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "", entries[0].Raw)
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestResponseBytesRead(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	err := c.Stor("file", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		assert.NoError(t, r.SetDeadline(time.Now().Add(time.Minute)))
+		assert.Equal(t, int64(0), r.BytesRead())
+
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(r, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), r.BytesRead())
+
+		_, err = ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(testData)), r.BytesRead())
+		assert.NoError(t, r.Close())
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestRetrMaxBytes(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	err := c.Stor("file", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+
+	r, err := c.Retr("file", TransferWithMaxBytes(5))
+	if assert.NoError(t, err) {
+		buf, err := ioutil.ReadAll(r)
+		assert.Equal(t, testData[:5], string(buf))
+		assert.True(t, errors.Is(err, ErrMaxSizeExceeded))
+		assert.Equal(t, &MaxSizeError{Limit: 5, Written: 5}, err)
+		assert.NoError(t, r.Close())
+	}
+
+	// the size is checked before the transfer starts
+	_, err = c.Retr("magic-file", TransferWithMaxBytes(10))
+	assert.Equal(t, &MaxSizeError{Limit: 10}, err)
+
+	// the connection must still be usable after the abort
+	assert.NoError(t, c.NoOp())
+
+	closeConn(t, mock, c, []string{"EPSV", "STOR", "SIZE", "EPSV", "RETR", "ABOR", "SIZE", "NOOP"})
+}
+
+func TestMaxDownloadBytes(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithMaxDownloadBytes(20))
+
+	err := c.Stor("file", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		buf, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, string(buf))
+		assert.NoError(t, r.Close())
+	}
+
+	r, err = c.Retr("file")
+	if assert.NoError(t, err) {
+		buf, err := ioutil.ReadAll(r)
+		assert.Equal(t, testData[:6], string(buf))
+		assert.Equal(t, &MaxSizeError{Limit: 20, Written: 6}, err)
+		assert.NoError(t, r.Close())
+	}
+
+	closeConn(t, mock, c, []string{"EPSV", "STOR", "SIZE", "EPSV", "RETR", "SIZE", "EPSV", "RETR", "ABOR"})
+}
+
 func TestTimeUnsupported(t *testing.T) {
 	mock, c := openConnExt(t, "127.0.0.1", "no-time")
 
@@ -436,3 +745,540 @@ func TestDialWithDialer(t *testing.T) {
 
 	assert.Equal(t, true, dialerCalled)
 }
+
+func TestMakeDirAll(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	assert.NoError(t, c.MakeDirAll("/a/existing-dir/b/"))
+	assert.NoError(t, c.MakeDirAll("x/y"))
+	assert.NoError(t, c.MakeDirAll("/"))
+
+	closeConn(t, mock, c, []string{
+		"MKD", "MKD", "PWD", "CWD", "CWD", "MKD",
+		"MKD", "MKD",
+	})
+}
+
+func TestDeleteDirRecurTree(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	assert.NoError(t, c.RemoveDirRecur("unix/"))
+
+	closeConn(t, mock, c, []string{
+		"EPSV", "LIST", // unix
+		"EPSV", "LIST", "DELE", "RMD", // pub
+		"DELE", "DELE", // b.txt, a.txt
+		"RMD",
+	})
+}
+
+func TestStatList(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	entries, err := c.StatList("dir")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "pub", entries[0].Name)
+		assert.Equal(t, EntryTypeFolder, entries[0].Type)
+		assert.Equal(t, "b.txt", entries[1].Name)
+		assert.Equal(t, uint64(1024), entries[1].Size)
+	}
+
+	entries, err = c.StatList("empty")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = c.StatList("missing-dir")
+	assert.Error(t, err)
+
+	closeConn(t, mock, c, []string{"STAT", "STAT", "STAT"})
+}
+
+func TestStatFile(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"STAT /file": "213-Status of /file:\r\n" +
+				" -rw-r--r--    1 ftp      ftp          1024 Dec 02  2009 /file\r\n" +
+				"213 End of status",
+			"STAT inline": "213 -rw-r--r--    1 ftp      ftp          5 Dec 02  2009 inline",
+			"STAT /dir": "213-Status of /dir:\r\n" +
+				" total 2\r\n" +
+				" drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub\r\n" +
+				"213--rw-r--r--    1 ftp      ftp          1024 Dec 02  2009 b.txt\r\n" +
+				"213 End of status",
+			"STAT /single": "213-Status of /single:\r\n" +
+				" -rw-r--r--    1 ftp      ftp          1024 Dec 02  2009 b.txt\r\n" +
+				"213 End of status",
+			"STAT /empty":   "213-Status of /empty:\r\n213 End of status",
+			"STAT /missing": "450 /missing: No such file or directory",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	entry, err := c.StatFile("/file")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "file", entry.Name)
+		assert.Equal(t, EntryTypeFile, entry.Type)
+		assert.Equal(t, uint64(1024), entry.Size)
+	}
+
+	entry, err = c.StatFile("inline")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "inline", entry.Name)
+		assert.Equal(t, uint64(5), entry.Size)
+	}
+
+	_, err = c.StatFile("/dir")
+	assert.True(t, errors.Is(err, ErrStatDirectory))
+	_, err = c.StatFile("/single")
+	assert.True(t, errors.Is(err, ErrStatDirectory))
+
+	_, err = c.StatFile("/empty")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = c.StatFile("/missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestTransferProgress(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	var calls []int64
+	progress := TransferWithProgress(func(transferred int64) {
+		calls = append(calls, transferred)
+	}, 1<<20)
+
+	err := c.Stor("file", bytes.NewBufferString(testData), progress)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{int64(len(testData)), int64(len(testData))}, calls)
+
+	calls = nil
+	r, err := c.Retr("file", progress)
+	if assert.NoError(t, err) {
+		_, err = ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+	}
+	assert.Equal(t, []int64{int64(len(testData)), int64(len(testData))}, calls)
+
+	// the last call is made even if the transfer is not read completely
+	calls = nil
+	r, err = c.Retr("file", progress)
+	if assert.NoError(t, err) {
+		assert.NoError(t, r.Close())
+	}
+	assert.Equal(t, []int64{0}, calls)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestModeZ(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "mode-z", DialWithModeZLevel(9))
+	assert.True(t, c.modeZ)
+
+	err := c.Stor("file", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+	assert.Equal(t, testData, mock.fileCont.String())
+
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, string(data))
+		assert.Equal(t, int64(len(testData)), r.BytesRead())
+		assert.NoError(t, r.Close())
+	}
+
+	entries, err := c.List("")
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, "lo", entries[0].Name)
+	}
+
+	// an empty file is still sent as a complete stream
+	err = c.Stor("empty", &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mock.fileCont.Len())
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+	assert.Contains(t, mock.commands, "MODE")
+}
+
+func TestModeZRejected(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "mode-z-rejected", DialWithModeZ(true))
+	assert.False(t, c.modeZ)
+
+	err := c.Stor("file", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+	assert.Equal(t, testData, mock.fileCont.String())
+
+	closeConn(t, mock, c, []string{"MODE", "EPSV", "STOR"})
+}
+
+func TestModeZNotAdvertised(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithModeZ(true))
+	assert.False(t, c.modeZ)
+	closeConn(t, mock, c, nil)
+}
+
+func TestWelcomeSystem(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+	assert.Equal(t, "FTP Server ready.", c.Welcome())
+
+	for i := 0; i < 2; i++ {
+		system, err := c.System()
+		assert.NoError(t, err)
+		assert.Equal(t, "UNIX Type: L8", system)
+	}
+
+	closeConn(t, mock, c, []string{"SYST"})
+}
+
+func TestCmd(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	code, lines, err := c.Cmd(StatusSystem, "FEAT")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSystem, code)
+	if assert.Greater(t, len(lines), 2) {
+		assert.Equal(t, "Features:", lines[0])
+		assert.Equal(t, "End", lines[len(lines)-1])
+	}
+
+	code, lines, err = c.Cmd(2, "NOOP")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCommandOK, code)
+	assert.Equal(t, []string{"NOOP ok."}, lines)
+
+	_, _, err = c.Cmd(StatusCommandOK, "RNFR missing")
+	assert.Error(t, err)
+
+	assert.NoError(t, c.Stor("file", strings.NewReader(testData)))
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		_, _, err = c.Cmd(-1, "NOOP")
+		assert.True(t, errors.Is(err, ErrTransferPending))
+
+		_, err = io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+	}
+
+	_, _, err = c.Cmd(-1, "NOOP")
+	assert.NoError(t, err)
+
+	closeConn(t, mock, c, []string{"FEAT", "NOOP", "RNFR", "EPSV", "STOR", "EPSV", "RETR", "NOOP"})
+}
+
+func TestPRET(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "pret")
+
+	_, err := c.List("")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Stor("file", strings.NewReader(testData)))
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		assert.NoError(t, r.Close())
+	}
+
+	closeConn(t, mock, c, []string{"PRET", "EPSV", "LIST", "PRET", "EPSV", "STOR", "PRET", "EPSV", "RETR"})
+}
+
+func TestPRETRejected(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithPRET(true))
+
+	_, err := c.List("")
+	assert.NoError(t, err)
+	_, err = c.List("")
+	assert.NoError(t, err)
+
+	closeConn(t, mock, c, []string{"PRET", "EPSV", "LIST", "EPSV", "LIST"})
+}
+
+func TestParseEPSVReply(t *testing.T) {
+	for _, line := range []string{
+		"Entering Extended Passive Mode (|||6446|)",
+		"Entering Extended Passive Mode ( |||6446| )",
+		"Entering Extended Passive Mode (||| 6446 |)",
+		"Entering Extended Passive Mode (!!!6446!)",
+	} {
+		port, err := parseEPSVReply(line)
+		if assert.NoError(t, err, line) {
+			assert.Equal(t, 6446, port, line)
+		}
+	}
+
+	for _, line := range []string{
+		"Entering Extended Passive Mode",
+		"Entering Extended Passive Mode (|||6446)",
+		"Entering Extended Passive Mode (||!6446|)",
+		"Entering Extended Passive Mode (|||port|)",
+		"Entering Extended Passive Mode (|||70000|)",
+		"Entering Extended Passive Mode )|||6446|(",
+	} {
+		_, err := parseEPSVReply(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestParsePASVReply(t *testing.T) {
+	for _, line := range []string{
+		"Entering Passive Mode (192,168,1,2,4,1)",
+		"Entering Passive Mode (192,168,1,2,4,1).",
+		"Entering Passive Mode 192,168,1,2,4,1",
+		"Entering Passive Mode (192,168,1,2,4,1) - ok, port 1025",
+		"Entering Passive Mode (192, 168, 1, 2, 4, 1)",
+		"Entering Passive Mode ( 192 , 168 , 1 , 2 , 4 , 1 )",
+		"Entering Passive Mode =192,168,1,2,4,1",
+		"Entering Passive Mode\n(192,168,1,2,4,1)",
+		"Passive mode (10 connections left):\n 192,168,1,2,4,1",
+	} {
+		host, port, err := parsePASVReply(line)
+		if assert.NoError(t, err, line) {
+			assert.Equal(t, "192.168.1.2", host, line)
+			assert.Equal(t, 1025, port, line)
+		}
+	}
+
+	for _, line := range []string{
+		"Entering Passive Mode",
+		"Entering Passive Mode ()",
+		"Entering Passive Mode (192,168,1,2,4)",
+		"Entering Passive Mode (192,168,1,2,4,)",
+		"Entering Passive Mode (192;168;1;2;4;1)",
+		"Entering Passive Mode (192,168,1,256,4,1)",
+		"Entering Passive Mode (192,168,1,2,4,1000000000000000000000)",
+		"Entering Passive Mode (192,168,1,2,0,0)",
+	} {
+		_, _, err := parsePASVReply(line)
+		if assert.Error(t, err, line) {
+			assert.Contains(t, err.Error(), fmt.Sprintf("%q", line))
+		}
+	}
+}
+
+func TestEPSVFallback(t *testing.T) {
+	for _, flavor := range []string{"epsv-broken", "epsv-firewalled"} {
+		mock, c := openConnScenario(t, "127.0.0.1", flavor)
+
+		_, err := c.List("")
+		assert.NoError(t, err, flavor)
+		_, err = c.List("")
+		assert.NoError(t, err, flavor)
+
+		closeConn(t, mock, c, []string{"EPSV", "PASV", "LIST", "PASV", "LIST"})
+	}
+}
+
+func TestForcedEPSV(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "epsv-broken", DialWithForcedEPSV(true))
+
+	_, err := c.List("")
+	assert.Error(t, err)
+	_, err = c.List("")
+	assert.Error(t, err)
+
+	closeConn(t, mock, c, []string{"EPSV", "EPSV"})
+}
+
+// fakeClient is a Client which only implements what a test needs
+type fakeClient struct {
+	Client
+	deleted []string
+}
+
+func (c *fakeClient) Delete(path string) error {
+	c.deleted = append(c.deleted, path)
+	return nil
+}
+
+func (c *fakeClient) Retr(path string, options ...TransferOption) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(testData)), nil
+}
+
+func TestClientInterface(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{"/file": []byte(testData)},
+	})
+	defer s.Close()
+	c := NewClient(dialScript(t, s))
+
+	// The same code reads from a ServerConn or a fake
+	read := func(c Client, path string) string {
+		r, err := c.Retr(path)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer r.Close()
+		buf, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		return string(buf)
+	}
+	assert.Equal(t, testData, read(c, "/file"))
+	assert.Equal(t, testData, read(&fakeClient{}, "/file"))
+
+	// No typed nil on failure
+	r, err := c.Retr("/missing")
+	assert.Error(t, err)
+	assert.Nil(t, r)
+	assert.NoError(t, c.Quit())
+
+	// Code taking a Client can be tested without a server
+	deleteAll := func(c Client, paths ...string) error {
+		for _, p := range paths {
+			if err := c.Delete(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fake := &fakeClient{}
+	assert.NoError(t, deleteAll(fake, "a", "b"))
+	assert.Equal(t, []string{"a", "b"}, fake.deleted)
+}
+
+func TestQuitTwice(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	var closer io.Closer = c
+	assert.NoError(t, closer.Close())
+	assert.NoError(t, c.Quit())
+	assert.True(t, errors.Is(c.NoOp(), net.ErrClosed))
+}
+
+func TestQuitUnresponsive(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// A server which never replies once connected
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("220 Ready\r\n"))
+		_, _ = io.Copy(ioutil.Discard, conn)
+	}()
+
+	c, err := Dial(l.Addr().String(), DialWithQuitTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	assert.NoError(t, c.Quit())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestQuitDuringTransfer(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{"file": []byte(testData)},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	r, err := c.Retr("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, c.Quit())
+	assert.NoError(t, r.Close())
+
+	r, err = c.Retr("file")
+	assert.True(t, errors.Is(err, net.ErrClosed), err)
+	assert.Nil(t, r)
+}
+
+func TestResponseEarlyClose(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{
+			"/big":   make([]byte, 8<<20),
+			"/small": []byte(testData),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	// The transfer is aborted, and the connection stays usable
+	for i := 0; i < 5; i++ {
+		r, err := c.Retr("/big")
+		if !assert.NoError(t, err) {
+			break
+		}
+		_, err = io.ReadFull(r, make([]byte, 1024))
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+
+		_, err = c.List("/")
+		assert.NoError(t, err)
+	}
+
+	// The small remainder is drained, and the transfer completes normally
+	r, err := c.Retr("/small")
+	if assert.NoError(t, err) {
+		_, err = io.ReadFull(r, make([]byte, 4))
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+	}
+	assert.NoError(t, c.NoOp())
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestTransferChatter(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Listings: map[string]string{
+			"/": "-rw-r--r-- 1 user group 1 Jan 01  2020 a\r\n",
+		},
+		Files: map[string][]byte{
+			"/file": []byte(testData),
+		},
+		Transfers: map[string]ftptest.TransferReplies{
+			// ProFTPD telling the options of ls
+			"LIST": {
+				Start: "150-Accepted data connection\r\n150 Opening ASCII mode data connection",
+				End:   "226-Options: -l\r\n226 5 matches total",
+			},
+			// An extra preliminary reply, and wu-ftpd's double completion
+			"RETR /file": {
+				Start: "150 Opening data connection\r\n150 Accepted data connection",
+				End:   "226 Transfer complete.\r\n226 Transfer complete.",
+			},
+			"STOR /upload": {
+				End: "226 Transfer complete.\r\n226 Transfer complete.",
+			},
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	for i := 0; i < 2; i++ {
+		entries, err := c.List("/")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		r, err := c.Retr("/file")
+		if assert.NoError(t, err) {
+			data, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, testData, string(data))
+			assert.NoError(t, r.Close())
+		}
+		assert.NoError(t, c.NoOp())
+
+		assert.NoError(t, c.Stor("/upload", strings.NewReader(testData)))
+		assert.NoError(t, c.ChangeDir("/"))
+	}
+
+	assert.NoError(t, c.Quit())
+}
@@ -0,0 +1,35 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrServerClosed is matched by the errors of the operations on a connection
+// the server closed with a 421 reply, e.g. when it shuts down for
+// maintenance. The connection can not be used anymore, and must be replaced.
+var ErrServerClosed = errors.New("server closed the connection")
+
+// readResponse reads a reply like textproto.Conn.ReadResponse. A 421 reply,
+// whether it answers the command or was sent by the server before it, closes
+// the connection: it fails with ErrServerClosed.
+func (c *ServerConn) readResponse(expected int) (int, string, error) {
+	code, msg, err := c.conn.ReadResponse(expected)
+	if code == StatusNotAvailable {
+		c.serverClosed(msg)
+		return code, msg, c.closedErr
+	}
+	return code, msg, err
+}
+
+// serverClosed closes the connection after the server announced it closes
+// it, so that the next operations fail with ErrServerClosed. The keepalive,
+// which may be the caller, stops by itself on its next command, or with
+// Quit.
+func (c *ServerConn) serverClosed(msg string) {
+	if c.closed || c.closedErr != nil {
+		return
+	}
+	c.closedErr = fmt.Errorf("%w: %d %s", ErrServerClosed, StatusNotAvailable, msg)
+	_ = c.conn.Close()
+}
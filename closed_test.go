@@ -0,0 +1,83 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+const shutdownReply = "421 Service not available, closing control connection"
+
+func TestServerClosed(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"NOOP": shutdownReply,
+		},
+	})
+	defer s.Close()
+
+	var disconnectErr error
+	hooks := Hooks{
+		OnDisconnect: func(addr string, err error) { disconnectErr = err },
+	}
+	c := dialScript(t, s, DialWithHooks(hooks))
+
+	err := c.NoOp()
+	assert.True(t, errors.Is(err, ErrServerClosed), "%v", err)
+	assert.Contains(t, err.Error(), "Service not available")
+
+	// The next operations fail without being sent
+	err = c.ChangeDir("/")
+	assert.True(t, errors.Is(err, ErrServerClosed), "%v", err)
+	_, err = c.List("/")
+	assert.True(t, errors.Is(err, ErrServerClosed), "%v", err)
+	assert.Zero(t, countCommands(s, "CWD"))
+
+	assert.NoError(t, c.Quit())
+	assert.True(t, errors.Is(disconnectErr, ErrServerClosed), "%v", disconnectErr)
+	assert.Zero(t, countCommands(s, "QUIT"))
+}
+
+func TestServerClosedTransfer(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{
+			"/file": []byte(testData),
+		},
+		Transfers: map[string]ftptest.TransferReplies{
+			"RETR /file": {End: shutdownReply},
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	r, err := c.Retr("/file")
+	if assert.NoError(t, err) {
+		_, err = io.ReadAll(r)
+		assert.NoError(t, err)
+		err = r.Close()
+		assert.True(t, errors.Is(err, ErrServerClosed), "%v", err)
+	}
+
+	// Not retried on the closed connection
+	_, err = c.FileSize("/file")
+	assert.True(t, errors.Is(err, ErrServerClosed), "%v", err)
+	assert.NoError(t, c.Quit())
+}
+
+func TestServerClosedNotRetried(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"DELE": shutdownReply,
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s, DialWithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+	err := c.Delete("/file")
+	assert.True(t, errors.Is(err, ErrServerClosed), "%v", err)
+	assert.Equal(t, 1, countCommands(s, "DELE"))
+	assert.NoError(t, c.Quit())
+}
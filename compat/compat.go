@@ -0,0 +1,179 @@
+// Package compat eases the migration of code written against the upstream
+// github.com/jlaffaye/ftp API.
+//
+// It exposes the common entry points of the upstream package with identical
+// names and signatures, so that typical users only need to change the import
+// path. The connection returned by Dial embeds a *ftp.ServerConn, which stays
+// reachable for the features specific to this package.
+//
+// Behavioral differences with the upstream package:
+//
+//   - Retr and RetrFrom honor the DialWithMaxDownloadBytes option of the
+//     underlying connection.
+//   - List skips lines it can not parse unless the connection was established
+//     with ftp.DialWithStrictList.
+//   - The replies refusing an operation on a path, e.g. of Retr or Delete,
+//     are returned as an *ftp.PathError wrapping the *textproto.Error: use
+//     errors.As rather than a type assertion.
+//   - Rename returns an *ftp.RenameError wrapping the *textproto.Error,
+//     telling whether RNFR or RNTO was refused, instead of the
+//     *textproto.Error itself.
+package compat
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// Types shared with the main package.
+type (
+	DialOption = ftp.DialOption
+	Entry      = ftp.Entry
+	EntryType  = ftp.EntryType
+	Response   = ftp.Response
+	Walker     = ftp.Walker
+)
+
+// The differents types of an Entry
+const (
+	EntryTypeFile   = ftp.EntryTypeFile
+	EntryTypeFolder = ftp.EntryTypeFolder
+	EntryTypeLink   = ftp.EntryTypeLink
+)
+
+// ServerConn represents the connection to a remote FTP server.
+//
+// The methods which are not redefined here are those of the embedded
+// *ftp.ServerConn, whose signatures match the upstream ones.
+type ServerConn struct {
+	*ftp.ServerConn
+}
+
+// Dial connects to the specified address with optional options
+func Dial(addr string, options ...DialOption) (*ServerConn, error) {
+	c, err := ftp.Dial(addr, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &ServerConn{c}, nil
+}
+
+// DialTimeout initializes the connection to the specified ftp server address.
+func DialTimeout(addr string, timeout time.Duration) (*ServerConn, error) {
+	return Dial(addr, DialWithTimeout(timeout))
+}
+
+// Connect is an alias to Dial, for backward compatibility
+func Connect(addr string) (*ServerConn, error) {
+	return Dial(addr)
+}
+
+// Retr issues a RETR FTP command to fetch the specified file from the remote
+// FTP server.
+func (c *ServerConn) Retr(path string) (*Response, error) {
+	return c.ServerConn.Retr(path)
+}
+
+// Stor issues a STOR FTP command to store a file to the remote FTP server.
+func (c *ServerConn) Stor(path string, r io.Reader) error {
+	return c.ServerConn.Stor(path, r)
+}
+
+// StorFrom issues a STOR FTP command to store a file to the remote FTP
+// server, writing on the server will start at the given file offset.
+func (c *ServerConn) StorFrom(path string, r io.Reader, offset uint64) error {
+	return c.ServerConn.StorFrom(path, r, offset)
+}
+
+// Append issues a APPE FTP command to store a file to the remote FTP server.
+func (c *ServerConn) Append(path string, r io.Reader) error {
+	return c.ServerConn.Append(path, r)
+}
+
+// RetrFrom issues a RETR FTP command to fetch the specified file from the
+// remote FTP server, the server will not send the offset first bytes of the
+// file.
+func (c *ServerConn) RetrFrom(path string, offset uint64) (*Response, error) {
+	return c.ServerConn.RetrFrom(path, offset)
+}
+
+// DialWithTimeout is ftp.DialWithTimeout
+func DialWithTimeout(timeout time.Duration) DialOption {
+	return ftp.DialWithTimeout(timeout)
+}
+
+// DialWithShutTimeout is ftp.DialWithShutTimeout
+func DialWithShutTimeout(shutTimeout time.Duration) DialOption {
+	return ftp.DialWithShutTimeout(shutTimeout)
+}
+
+// DialWithDialer is ftp.DialWithDialer
+func DialWithDialer(dialer net.Dialer) DialOption {
+	return ftp.DialWithDialer(dialer)
+}
+
+// DialWithNetConn is ftp.DialWithNetConn
+func DialWithNetConn(conn net.Conn) DialOption {
+	return ftp.DialWithNetConn(conn)
+}
+
+// DialWithDisabledEPSV is ftp.DialWithDisabledEPSV
+func DialWithDisabledEPSV(disabled bool) DialOption {
+	return ftp.DialWithDisabledEPSV(disabled)
+}
+
+// DialWithDisabledUTF8 is ftp.DialWithDisabledUTF8
+func DialWithDisabledUTF8(disabled bool) DialOption {
+	return ftp.DialWithDisabledUTF8(disabled)
+}
+
+// DialWithDisabledMLSD is ftp.DialWithDisabledMLSD
+func DialWithDisabledMLSD(disabled bool) DialOption {
+	return ftp.DialWithDisabledMLSD(disabled)
+}
+
+// DialWithWritingMDTM is ftp.DialWithWritingMDTM
+func DialWithWritingMDTM(enabled bool) DialOption {
+	return ftp.DialWithWritingMDTM(enabled)
+}
+
+// DialWithLocation is ftp.DialWithLocation
+func DialWithLocation(location *time.Location) DialOption {
+	return ftp.DialWithLocation(location)
+}
+
+// DialWithContext is ftp.DialWithContext
+func DialWithContext(ctx context.Context) DialOption {
+	return ftp.DialWithContext(ctx)
+}
+
+// DialWithTLS is ftp.DialWithTLS
+func DialWithTLS(tlsConfig *tls.Config) DialOption {
+	return ftp.DialWithTLS(tlsConfig)
+}
+
+// DialWithExplicitTLS is ftp.DialWithExplicitTLS
+func DialWithExplicitTLS(tlsConfig *tls.Config) DialOption {
+	return ftp.DialWithExplicitTLS(tlsConfig)
+}
+
+// DialWithDebugOutput is ftp.DialWithDebugOutput
+func DialWithDebugOutput(w io.Writer) DialOption {
+	return ftp.DialWithDebugOutput(w)
+}
+
+// DialWithDialFunc is ftp.DialWithDialFunc
+func DialWithDialFunc(f func(network, address string) (net.Conn, error)) DialOption {
+	return ftp.DialWithDialFunc(f)
+}
+
+// StatusText returns a text for the FTP status code. It returns the empty
+// string if the code is unknown.
+func StatusText(code int) string {
+	return ftp.StatusText(code)
+}
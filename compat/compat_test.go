@@ -0,0 +1,93 @@
+package compat
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// upstreamConn lists the methods of the upstream ServerConn with their
+// upstream signatures.
+type upstreamConn interface {
+	Login(user, password string) error
+	NameList(path string) ([]string, error)
+	List(path string) ([]*Entry, error)
+	ChangeDir(path string) error
+	ChangeDirToParent() error
+	CurrentDir() (string, error)
+	FileSize(path string) (int64, error)
+	GetTime(path string) (time.Time, error)
+	SetTime(path string, t time.Time) error
+	Retr(path string) (*Response, error)
+	RetrFrom(path string, offset uint64) (*Response, error)
+	Stor(path string, r io.Reader) error
+	StorFrom(path string, r io.Reader, offset uint64) error
+	Append(path string, r io.Reader) error
+	Rename(from, to string) error
+	Delete(path string) error
+	RemoveDirRecur(path string) error
+	MakeDir(path string) error
+	RemoveDir(path string) error
+	Walk(root string) *Walker
+	NoOp() error
+	Logout() error
+	Quit() error
+}
+
+var _ upstreamConn = (*ServerConn)(nil)
+
+// upstreamUsage is never run: it only has to compile, exercising the API the
+// way code written against the upstream package does.
+func upstreamUsage() error {
+	c, err := Dial("ftp.example.org:21", DialWithTimeout(5*time.Second), DialWithDisabledEPSV(true))
+	if err != nil {
+		return err
+	}
+	defer c.Quit()
+
+	if err := c.Login("anonymous", "anonymous"); err != nil {
+		return err
+	}
+	if err := c.ChangeDir("incoming"); err != nil {
+		return err
+	}
+
+	entries, err := c.List(".")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Type == EntryTypeFolder {
+			continue
+		}
+		r, err := c.RetrFrom(e.Name, 0)
+		if err != nil {
+			return err
+		}
+		_, err = ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err := c.Retr("file")
+	if err != nil {
+		return err
+	}
+	r.Close()
+
+	if err := c.Stor("file", bytes.NewBufferString("data")); err != nil {
+		return err
+	}
+	if err := c.StorFrom("file", bytes.NewBufferString("data"), 4); err != nil {
+		return err
+	}
+	if err := c.MakeDir("dir"); err != nil {
+		return err
+	}
+	return c.Delete("file")
+}
+
+var _ = upstreamUsage
@@ -2,22 +2,79 @@ package ftp
 
 import (
 	"bytes"
+	"compress/zlib"
 	"errors"
 	"io"
 	"net"
 	"net/textproto"
+	"path"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
 )
 
+// vmsListing is a LIST output of an OpenVMS server, including a long name
+// wrapped onto a second line
+const vmsListing = "\r\nDirectory DISK$USER:[JDOE]\r\n\r\n" +
+	"DATA.DIR;1   1 19-NOV-2016 18:53:39.00 [SYSTEM] (RWED,RWED,RE,RE)\r\n" +
+	"A_RATHER_LONG_FILE_NAME.TXT;12\r\n" +
+	"                    17 30-DEC-2016 17:44:10.00 [SYSTEM] (RWED,RWED,RE,RE)\r\n" +
+	"LOGIN.COM;3          2/3   5-JAN-2017 09:12:01.00 [USER,JDOE] (RWED,RWED,RE,)\r\n" +
+	"\r\nTotal of 3 files, 20 blocks.\r\n"
+
+// unixListing is a LIST output of ls, with the block count header
+const unixListing = "total 96\r\n" +
+	"drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub\r\n" +
+	"-rw-r--r--    1 ftp      ftp          1024 Dec 02  2009 b.txt\r\n" +
+	"-rw-r--r--    1 ftp      ftp          2048 Dec 02  2009 a.txt\r\n" +
+	"\r\n"
+
+// pdsListing is a LIST output of a z/OS server in a partitioned dataset
+const pdsListing = " Name     VV.MM   Created       Changed      Size  Init   Mod   Id\r\n" +
+	" ASM      01.05 2021/03/01 2021/05/18 10:01    45    40     0 IBMUSER\r\n" +
+	" NOSTATS\r\n"
+
+// dataSetListing is a LIST output of a z/OS server in a catalog
+const dataSetListing = "Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname\r\n" +
+	"WYPRC5 3390   2021/05/18  1   15  FB      80 27920  PO  ISPF.PROFILE\r\n" +
+	"Migrated                                                OLD.DATA\r\n"
+
+// gdgListing is a LIST output of a z/OS server in the catalog of a
+// generation data group
+const gdgListing = "Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname\r\n" +
+	"WYPRC5 3390   2021/05/16  1    1  FB      80 27920  PS  G0010V00\r\n" +
+	"WYPRC5 3390   2021/05/18  1    1  FB      80 27920  PS  G0012V00\r\n" +
+	"WYPRC5 3390   2021/05/17  1    1  FB      80 27920  PS  G0011V01\r\n" +
+	"WYPRC5 3390   2021/05/17  1    1  FB      80 27920  PS  G0011V00\r\n" +
+	"WYPRC5 3390   2021/05/17  1    1  FB      80 27920  PS  README\r\n"
+
+// jobListing is a LIST output of a z/OS server in JES mode
+const jobListing = "JOBNAME  JOBID    OWNER    STATUS CLASS\r\n" +
+	"IBMUSERA JOB00123 IBMUSER  OUTPUT A        RC=0000 3 spool files\r\n" +
+	"IBMUSERB JOB00124 IBMUSER  ACTIVE A\r\n" +
+	"IBMUSERC JOB00125 IBMUSER  INPUT  -\r\n"
+
+// jobDetailListing is a LIST output of a z/OS server in JES mode for a single
+// job
+const jobDetailListing = "JOBNAME  JOBID    OWNER    STATUS CLASS\r\n" +
+	"IBMUSERA JOB00123 IBMUSER  OUTPUT A        RC=0000\r\n" +
+	"--------\r\n" +
+	"         ID  STEPNAME PROCSTEP C DDNAME   BYTE-COUNT\r\n" +
+	"         001 JESE              H JESMSGLG      1200\r\n" +
+	"         002 JESE              H JESJCL         526\r\n" +
+	"         003 STEP1    PROC1    A SYSPRINT       300\r\n" +
+	"3 spool files\r\n"
+
 type ftpMock struct {
 	t        *testing.T
 	address  string
 	modtime  string // no-time, std-time, vsftpd
+	scenario string // mode-z, mode-z-rejected, latin1, mlst, no-port, zos, pret, epsv-broken, epsv-firewalled, busy
 	listener *net.TCPListener
 	proto    *textproto.Conn
 	commands []string // list of received commands
@@ -25,6 +82,12 @@ type ftpMock struct {
 	rest     int
 	fileCont *bytes.Buffer
 	dataConn *mockDataConn
+	hash     string          // algorithm selected for HASH
+	modeZ    bool            // MODE Z is enabled
+	cwd      string          // argument of the last CWD
+	user     string          // argument of the last USER
+	busy     map[string]bool // commands which failed with the busy flavor
+	jes      bool            // SITE FILETYPE=JES is in effect
 	sync.WaitGroup
 }
 
@@ -35,14 +98,21 @@ func newFtpMock(t *testing.T, address string) (*ftpMock, error) {
 }
 
 func newFtpMockExt(t *testing.T, address, modtime string) (*ftpMock, error) {
+	return newFtpMockScenario(t, address, modtime, "")
+}
+
+// newFtpMockScenario returns a mock server listing the times as modtime
+// tells, and behaving as scenario tells, see ftpMock
+func newFtpMockScenario(t *testing.T, address, modtime, scenario string) (*ftpMock, error) {
 	var err error
 	mock := &ftpMock{
-		t:       t,
-		address: address,
-		modtime: modtime,
+		t:        t,
+		address:  strings.Trim(address, "[]"),
+		modtime:  modtime,
+		scenario: scenario,
 	}
 
-	l, err := net.Listen("tcp", address+":0")
+	l, err := net.Listen("tcp", net.JoinHostPort(mock.address, "0"))
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +147,12 @@ func (mock *ftpMock) listen() {
 	mock.printfLine("220 FTP Server ready.")
 
 	for {
-		fullCommand, _ := mock.proto.ReadLine()
+		fullCommand, err := mock.proto.ReadLine()
+		if err != nil {
+			// The client closed the connection without QUIT, possibly
+			// after the end of the test
+			return
+		}
 		mock.lastFull = fullCommand
 
 		cmdParts := strings.Split(fullCommand, " ")
@@ -85,29 +160,67 @@ func (mock *ftpMock) listen() {
 		// Append to list of received commands
 		mock.commands = append(mock.commands, cmdParts[0])
 
+		// The busy flavor fails each command once
+		if mock.scenario == "busy" && !mock.busy[cmdParts[0]] && strings.Contains("CWD SIZE LIST RETR STOR DELE", cmdParts[0]) {
+			if mock.busy == nil {
+				mock.busy = make(map[string]bool)
+			}
+			mock.busy[cmdParts[0]] = true
+			if mock.dataConn != nil {
+				mock.dataConn.Wait()
+				mock.closeDataConn()
+			}
+			mock.printfLine("450 Server busy, try again later")
+			continue
+		}
+
 		// At least one command must have a multiline response
 		switch cmdParts[0] {
 		case "FEAT":
-			features := "211-Features:\r\n FEAT\r\n PASV\r\n EPSV\r\n UTF8\r\n SIZE\r\n"
+			features := "211-Features:\r\n FEAT\r\n PASV\r\n EPSV\r\n UTF8\r\n SIZE\r\n REST STREAM\r\n HASH SHA-1*;SHA-256;MD5\r\n XCRC\r\n"
 			switch mock.modtime {
 			case "std-time":
 				features += " MDTM\r\n MFMT\r\n"
 			case "vsftpd":
 				features += " MDTM\r\n"
 			}
+			switch mock.scenario {
+			case "mode-z", "mode-z-rejected":
+				features += " MODE Z\r\n"
+			case "mlst":
+				features += " MLST type*;size*;modify*;\r\n"
+			case "pret":
+				features += " PRET\r\n"
+			}
 			features += "211 End"
 			mock.printfLine(features)
 		case "USER":
-			if cmdParts[1] == "anonymous" {
+			mock.user = cmdParts[1]
+			if cmdParts[1] == "anonymous" || cmdParts[1] == "acct" || cmdParts[1] == "chatty" {
 				mock.printfLine("331 Please send your password")
 			} else {
 				mock.printfLine("530 This FTP server is anonymous only")
 			}
 		case "PASS":
-			mock.printfLine("230-Hey,\r\nWelcome to my FTP\r\n230 Access granted")
+			switch mock.user {
+			case "acct":
+				mock.printfLine("332 Need account for login")
+			case "chatty":
+				// Separate replies in a single write
+				mock.printfLine("230 Welcome to my FTP\r\n230 Access granted")
+			default:
+				mock.printfLine("230-Hey,\r\nWelcome to my FTP\r\n230 Access granted")
+			}
+		case "ACCT":
+			if cmdParts[1] == "ACC123" {
+				mock.printfLine("230 Access granted")
+			} else {
+				mock.printfLine("530 Invalid account")
+			}
 		case "TYPE":
 			mock.printfLine("200 Type set ok")
 		case "CWD":
+			mock.cwd = cmdParts[1]
 			if cmdParts[1] == "missing-dir" {
 				mock.printfLine("550 %s: No such file or directory", cmdParts[1])
 			} else {
@@ -116,7 +229,11 @@ func (mock *ftpMock) listen() {
 		case "DELE":
 			mock.printfLine("250 File successfully removed.")
 		case "MKD":
-			mock.printfLine("257 Directory successfully created.")
+			if cmdParts[1] == "existing-dir" || strings.HasSuffix(cmdParts[1], "/existing-dir") {
+				mock.printfLine("550 %s: File exists", cmdParts[1])
+			} else {
+				mock.printfLine("257 Directory successfully created.")
+			}
 		case "RMD":
 			if cmdParts[1] == "missing-dir" {
 				mock.printfLine("550 No such file or directory")
@@ -124,12 +241,29 @@ func (mock *ftpMock) listen() {
 				mock.printfLine("250 Directory successfully removed.")
 			}
 		case "PWD":
+			if mock.scenario == "latin1" {
+				mock.printfLine("257 \"/caf\xe9\"")
+				break
+			}
 			mock.printfLine("257 \"/incoming\"")
+		case "MLST":
+			switch {
+			case strings.HasPrefix(path.Base(cmdParts[1]), "missing"):
+				mock.printfLine("550 %s: No such file or directory", cmdParts[1])
+			case strings.HasSuffix(cmdParts[1], "dir"):
+				mock.printfLine("250-Listing %s\r\n type=dir;modify=20201213202400; %s\r\n250 End", cmdParts[1], cmdParts[1])
+			default:
+				mock.printfLine("250-Listing %s\r\n type=file;size=42;modify=20201213202400; %s\r\n250 End", cmdParts[1], cmdParts[1])
+			}
+		case "SYST":
+			mock.printfLine("215 UNIX Type: L8")
 		case "CDUP":
 			mock.printfLine("250 CDUP command successful")
 		case "SIZE":
 			if cmdParts[1] == "magic-file" {
 				mock.printfLine("213 42")
+			} else if cmdParts[1] == "sized-file" && mock.fileCont != nil {
+				mock.printfLine("213 %d", mock.fileCont.Len())
 			} else {
 				mock.printfLine("550 Could not get file size.")
 			}
@@ -144,13 +278,61 @@ func (mock *ftpMock) listen() {
 			p2 := p % 256
 
 			mock.printfLine("227 Entering Passive Mode (127,0,0,1,%d,%d).", p1, p2)
+		case "PORT":
+			if mock.scenario == "no-port" {
+				mock.printfLine("500 Illegal PORT command.")
+				break
+			}
+			conn, err := mock.dialDataConn(cmdParts[1])
+			if err != nil {
+				mock.printfLine("425 %s.", err)
+				break
+			}
+			mock.closeDataConn()
+			mock.dataConn = &mockDataConn{t: mock.t, conn: conn, modeZ: mock.modeZ}
+			mock.printfLine("200 PORT command successful.")
+		case "EPRT":
+			conn, err := mock.dialDataConnExt(cmdParts[1])
+			if err != nil {
+				mock.printfLine("425 %s.", err)
+				break
+			}
+			mock.closeDataConn()
+			mock.dataConn = &mockDataConn{t: mock.t, conn: conn, modeZ: mock.modeZ}
+			mock.printfLine("200 EPRT command successful.")
 		case "EPSV":
+			if mock.scenario == "epsv-broken" {
+				mock.printfLine("229 Entering Extended Passive Mode (|||port|)")
+				break
+			}
+			if mock.scenario == "epsv-firewalled" {
+				// A port nobody listens on
+				l, err := net.Listen("tcp", net.JoinHostPort(mock.address, "0"))
+				if err != nil {
+					mock.printfLine("451 %s.", err)
+					break
+				}
+				l.Close()
+				mock.printfLine("229 Entering Extended Passive Mode (|||%d|)", l.Addr().(*net.TCPAddr).Port)
+				break
+			}
 			p, err := mock.listenDataConn()
 			if err != nil {
 				mock.printfLine("451 %s.", err)
 				break
 			}
 			mock.printfLine("229 Entering Extended Passive Mode (|||%d|)", p)
+		case "SITE":
+			if strings.Contains(mock.lastFull, "FILETYPE=") {
+				mock.jes = strings.Contains(mock.lastFull, "FILETYPE=JES")
+			}
+			if cmdParts[1] == "BOGUS" {
+				mock.printfLine("500 Unknown SITE command")
+			} else if strings.Contains(mock.lastFull, "UNIT=BAD") {
+				mock.printfLine("501 Invalid UNIT")
+			} else {
+				mock.printfLine("200 SITE command was accepted")
+			}
 		case "STOR":
 			if mock.dataConn == nil {
 				mock.printfLine("425 Unable to build data connection: Connection refused")
@@ -172,8 +354,41 @@ func (mock *ftpMock) listen() {
 			}
 
 			mock.dataConn.Wait()
+			if len(cmdParts) > 1 && path.Base(cmdParts[1]) == "denied" {
+				mock.printfLine("550 Permission denied")
+				mock.closeDataConn()
+				break
+			}
 			mock.printfLine("150 Opening ASCII mode data connection for file list")
-			mock.dataConn.write([]byte("-rw-r--r--   1 ftp      wheel           0 Jan 29 10:29 lo\r\ntotal 1"))
+			switch {
+			case len(cmdParts) > 1 && path.Base(cmdParts[1]) == "vms":
+				mock.dataConn.write([]byte(vmsListing))
+			case len(cmdParts) > 1 && path.Base(cmdParts[1]) == "unix":
+				mock.dataConn.write([]byte(unixListing))
+			case len(cmdParts) > 1 && path.Base(cmdParts[1]) == "glob":
+				mock.dataConn.write([]byte("drwxr-xr-x   2 ftp      ftp          4096 Jan 29 10:29 vms\r\n" +
+					"drwxr-xr-x   2 ftp      ftp          4096 Jan 29 10:29 unix\r\n" +
+					"-rw-r--r--   1 ftp      ftp             0 Jan 29 10:29 file.txt\r\n"))
+			case len(cmdParts) > 1 && path.Base(cmdParts[1]) == "tz":
+				mock.dataConn.write([]byte("-rw-r--r--   1 ftp      ftp             0 Dec 02  2009 old\r\n" +
+					"-rw-r--r--   1 ftp      ftp             0 Dec 14 00:00 tz-file\r\n"))
+			case len(cmdParts) > 1 && path.Base(cmdParts[1]) == "latin1":
+				mock.dataConn.write([]byte("-rw-r--r--   1 ftp      ftp             0 Jan 29 10:29 caf\xe9\r\n"))
+			case len(cmdParts) > 1 && path.Base(cmdParts[1]) == "gbk":
+				mock.dataConn.write([]byte("-rw-r--r--   1 ftp      ftp             0 Jan 29 10:29 \xd6\xd0\xce\xc4.txt\r\n"))
+			case mock.jes && len(cmdParts) > 1:
+				mock.dataConn.write([]byte(jobDetailListing))
+			case mock.jes:
+				mock.dataConn.write([]byte(jobListing))
+			case mock.scenario == "zos" && mock.cwd == "'HLQ.GDG.'":
+				mock.dataConn.write([]byte(gdgListing))
+			case mock.scenario == "zos" && strings.HasSuffix(mock.cwd, ".'"):
+				mock.dataConn.write([]byte(dataSetListing))
+			case mock.scenario == "zos":
+				mock.dataConn.write([]byte(pdsListing))
+			default:
+				mock.dataConn.write([]byte("total 1\r\n-rw-r--r--   1 ftp      wheel           0 Jan 29 10:29 lo\r\n\r\n"))
+			}
 			mock.printfLine("226 Transfer complete")
 			mock.closeDataConn()
 		case "NLST":
@@ -200,9 +415,17 @@ func (mock *ftpMock) listen() {
 			mock.printfLine("226 Transfer complete")
 			mock.closeDataConn()
 		case "RNFR":
-			mock.printfLine("350 File or directory exists, ready for destination name")
+			if path.Base(cmdParts[1]) == "missing" {
+				mock.printfLine("550 %s: No such file or directory", cmdParts[1])
+			} else {
+				mock.printfLine("350 File or directory exists, ready for destination name")
+			}
 		case "RNTO":
-			mock.printfLine("250 Rename successful")
+			if path.Base(cmdParts[1]) == "denied" {
+				mock.printfLine("553 Could not create file.")
+			} else {
+				mock.printfLine("250 Rename successful")
+			}
 		case "REST":
 			if len(cmdParts) != 2 {
 				mock.printfLine("500 wrong number of arguments")
@@ -226,6 +449,8 @@ func (mock *ftpMock) listen() {
 				if err != nil {
 					answer = "501 Can't get a time stamp"
 				}
+			case len(cmdParts) == 2 && path.Base(cmdParts[1]) == "tz-file":
+				answer = "213 20201213220000"
 			case len(cmdParts) == 2:
 				answer = "213 20201213202400"
 			default:
@@ -245,15 +470,65 @@ func (mock *ftpMock) listen() {
 				answer = "500 Unknown command MFMT"
 			}
 			mock.printfLine(answer)
+		case "ABOR":
+			mock.printfLine("225 No transfer to ABOR")
+		case "STAT":
+			switch {
+			case len(cmdParts) < 2:
+				mock.printfLine("211 FTP server status")
+			case cmdParts[1] == "missing-dir":
+				mock.printfLine("450 %s: No such file or directory", cmdParts[1])
+			case cmdParts[1] == "empty":
+				mock.printfLine("213-Status of %s:\r\n213 End of status", cmdParts[1])
+			default:
+				mock.printfLine("213-Status of %s:\r\n"+
+					" total 2\r\n"+
+					" drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub\r\n"+
+					"213--rw-r--r--    1 ftp      ftp          1024 Dec 02  2009 b.txt\r\n"+
+					"213 End of status", cmdParts[1])
+			}
+		case "HASH":
+			digests := map[string]string{
+				"":        "76ed2f7fa6a1dd5d2cd1e7ff4a3b28f46d8ac6c3",
+				"SHA-256": "25A9BCA5D1D2B2F1D9FE6B1EF0D0C4D2F95E1DD3A3F4B87A0E1A1BE14C53EE63",
+				"MD5":     "0f2f8b6e9a5a1c8b8d2a39b1b2b6a3e4",
+			}
+			algo := mock.hash
+			if algo == "" {
+				algo = "SHA-1"
+			}
+			mock.printfLine("213 %s 0-13 %s %s", algo, digests[mock.hash], cmdParts[1])
+		case "RANG":
+			mock.printfLine("350 Restarting at %s. Ending at %s.", cmdParts[1], cmdParts[2])
+		case "XCRC":
+			mock.printfLine("250 \"B7CF6C9A\"")
+		case "PRET":
+			if mock.scenario == "pret" {
+				mock.printfLine("200 OK, will use slave for %s", cmdParts[1])
+			} else {
+				mock.printfLine("500 PRET: command not understood")
+			}
 		case "NOOP":
 			mock.printfLine("200 NOOP ok.")
+		case "MODE":
+			if mock.scenario == "mode-z-rejected" {
+				mock.printfLine("504 MODE %s not supported", cmdParts[1])
+				break
+			}
+			mock.modeZ = cmdParts[1] == "Z"
+			mock.printfLine("200 Mode set to %s", cmdParts[1])
 		case "OPTS":
-			if len(cmdParts) != 3 {
+			if len(cmdParts) < 3 {
 				mock.printfLine("500 wrong number of arguments")
 				break
 			}
 			if (strings.Join(cmdParts[1:], " ")) == "UTF8 ON" {
 				mock.printfLine("200 OK, UTF-8 enabled")
+			} else if cmdParts[1] == "HASH" {
+				mock.hash = cmdParts[2]
+				mock.printfLine("200 %s", cmdParts[2])
+			} else if cmdParts[1] == "MODE" {
+				mock.printfLine("200 %s", strings.Join(cmdParts[2:], " "))
 			}
 		case "REIN":
 			mock.printfLine("220 Logged out")
@@ -285,6 +560,7 @@ type mockDataConn struct {
 	t        *testing.T
 	listener *net.TCPListener
 	conn     net.Conn
+	modeZ    bool // data is compressed
 	// WaitGroup is done when conn is accepted and stored
 	sync.WaitGroup
 }
@@ -304,6 +580,14 @@ func (d *mockDataConn) write(b []byte) {
 		d.t.Fatal("data conn is not opened")
 	}
 
+	if d.modeZ {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		_, _ = w.Write(b)
+		_ = w.Close()
+		b = buf.Bytes()
+	}
+
 	if _, err := d.conn.Write(b); err != nil {
 		d.t.Fatal(err)
 	}
@@ -312,7 +596,7 @@ func (d *mockDataConn) write(b []byte) {
 func (mock *ftpMock) listenDataConn() (int64, error) {
 	mock.closeDataConn()
 
-	l, err := net.Listen("tcp", mock.address+":0")
+	l, err := net.Listen("tcp", net.JoinHostPort(mock.address, "0"))
 	if err != nil {
 		return 0, err
 	}
@@ -337,6 +621,7 @@ func (mock *ftpMock) listenDataConn() (int64, error) {
 	dataConn := &mockDataConn{
 		t:        mock.t,
 		listener: tcpListener,
+		modeZ:    mock.modeZ,
 	}
 	dataConn.Add(1)
 
@@ -356,17 +641,60 @@ func (mock *ftpMock) listenDataConn() (int64, error) {
 	return p, nil
 }
 
+// dialDataConn connects to the h1,h2,h3,h4,p1,p2 address of a PORT command
+func (mock *ftpMock) dialDataConn(addr string) (net.Conn, error) {
+	parts := strings.Split(addr, ",")
+	if len(parts) != 6 {
+		return nil, errors.New("invalid PORT address")
+	}
+	p1, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return nil, err
+	}
+	p2, err := strconv.Atoi(parts[5])
+	if err != nil {
+		return nil, err
+	}
+
+	host := strings.Join(parts[:4], ".")
+	return net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(p1*256+p2)))
+}
+
+// dialDataConnExt connects to the address of an EPRT command, as described
+// in RFC 2428, e.g. |2|::1|1025|
+func (mock *ftpMock) dialDataConnExt(addr string) (net.Conn, error) {
+	parts := strings.Split(addr, "|")
+	if len(parts) != 5 || (parts[1] != "1" && parts[1] != "2") {
+		return nil, errors.New("invalid EPRT address")
+	}
+	return net.Dial("tcp", net.JoinHostPort(parts[2], parts[3]))
+}
+
 func (mock *ftpMock) recvDataConn(append bool) {
 	mock.dataConn.Wait()
 	if !append {
 		mock.fileCont = new(bytes.Buffer)
 	}
 
-	if _, err := io.Copy(mock.fileCont, mock.dataConn.conn); err != nil {
+	var r io.Reader = mock.dataConn.conn
+	if mock.modeZ {
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			mock.t.Fatal(err)
+		}
+		r = zr
+	}
+
+	if _, err := io.Copy(mock.fileCont, r); err != nil {
 		mock.t.Fatal(err)
 	}
 
-	mock.printfLine("226 Transfer Complete")
+	if mock.jes {
+		mock.printfLine("250-It is known to JES as JOB00123")
+		mock.printfLine("250 Transfer completed successfully.")
+	} else {
+		mock.printfLine("226 Transfer Complete")
+	}
 	mock.closeDataConn()
 }
 
@@ -385,7 +713,17 @@ func openConn(t *testing.T, addr string, options ...DialOption) (*ftpMock, *Serv
 }
 
 func openConnExt(t *testing.T, addr, modtime string, options ...DialOption) (*ftpMock, *ServerConn) {
-	mock, err := newFtpMockExt(t, addr, modtime)
+	return openConnMock(t, addr, modtime, "", options...)
+}
+
+// Helper to return a client connected to a mock server behaving as scenario
+// tells
+func openConnScenario(t *testing.T, addr, scenario string, options ...DialOption) (*ftpMock, *ServerConn) {
+	return openConnMock(t, addr, "no-time", scenario, options...)
+}
+
+func openConnMock(t *testing.T, addr, modtime, scenario string, options ...DialOption) (*ftpMock, *ServerConn) {
+	mock, err := newFtpMockScenario(t, addr, modtime, scenario)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -404,6 +742,20 @@ func openConnExt(t *testing.T, addr, modtime string, options ...DialOption) (*ft
 	return mock, c
 }
 
+// Helper to return a client logged in to a scripted test server, closed
+// along with the test
+func dialScript(t *testing.T, s *ftptest.Server, options ...DialOption) *ServerConn {
+	c, err := Dial(s.Addr, append([]DialOption{DialWithTimeout(5 * time.Second)}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Login("anonymous", "anonymous"); err != nil {
+		_ = c.Quit()
+		t.Fatal(err)
+	}
+	return c
+}
+
 // Helper to close a client connected to a mock server
 func closeConn(t *testing.T, mock *ftpMock, c *ServerConn, commands []string) {
 	expected := []string{"USER", "PASS", "FEAT", "TYPE", "OPTS"}
@@ -1,6 +1,7 @@
 package ftp
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,4 +16,38 @@ func TestEntryTypeString(t *testing.T) {
 	assert.Equal(t, "file", EntryTypeFile.String())
 	assert.Equal(t, "folder", EntryTypeFolder.String())
 	assert.Equal(t, "link", EntryTypeLink.String())
+	assert.Equal(t, "device", EntryTypeDevice.String())
+	assert.Equal(t, "pipe", EntryTypePipe.String())
+	assert.Equal(t, "socket", EntryTypeSocket.String())
+	assert.Equal(t, "unknown(42)", EntryType(42).String())
+}
+
+func TestEntryTypeText(t *testing.T) {
+	for typ := EntryTypeFile; typ <= EntryTypeSocket+1; typ++ {
+		text, err := typ.MarshalText()
+		assert.NoError(t, err)
+
+		var parsed EntryType
+		if assert.NoError(t, parsed.UnmarshalText(text)) {
+			assert.Equal(t, typ, parsed)
+		}
+	}
+
+	typ, err := ParseEntryType("fifo")
+	assert.NoError(t, err)
+	assert.Equal(t, EntryTypePipe, typ)
+
+	_, err = ParseEntryType("directory")
+	assert.Error(t, err)
+	_, err = ParseEntryType("unknown(x)")
+	assert.Error(t, err)
+
+	data, err := json.Marshal(&Entry{Name: "pub", Type: EntryTypeFolder})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"folder"`)
+
+	var entry Entry
+	if assert.NoError(t, json.Unmarshal(data, &entry)) {
+		assert.Equal(t, EntryTypeFolder, entry.Type)
+	}
 }
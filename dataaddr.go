@@ -0,0 +1,92 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Errors of DataAddressError
+var (
+	// ErrForeignDataAddress is matched by the error of a data connection
+	// to another host than the server, see DialWithAllowForeignDataAddress.
+	ErrForeignDataAddress = errors.New("data connection to another host than the server")
+
+	// ErrPrivilegedDataPort is matched by the error of a data connection to
+	// a port below 1024, see DialWithAllowPrivilegedDataPort.
+	ErrPrivilegedDataPort = errors.New("data connection to a privileged port")
+)
+
+// DataAddressError is returned when the address of a data connection is
+// refused, so that a malicious server can not use the client to connect to
+// other hosts or services, as in the FTP bounce attack. It matches
+// ErrForeignDataAddress or ErrPrivilegedDataPort with errors.Is.
+type DataAddressError struct {
+	Addr string // address announced by the server, or of the peer in active mode
+	Err  error
+}
+
+func (e *DataAddressError) Error() string {
+	return fmt.Sprintf("refused data connection with %s: %s", e.Addr, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *DataAddressError) Unwrap() error {
+	return e.Err
+}
+
+// DialWithAllowForeignDataAddress returns a DialOption that allows the data
+// connections with another host than the peer of the control connection. By
+// default, the client refuses to connect to another address announced by
+// PASV, and the connections of other hosts in active mode, with a
+// DataAddressError. An unspecified address, such as 0.0.0.0, stands for the
+// server.
+//
+// It is needed when the data connections of the server come from another
+// address, e.g. behind a NAT, or when the control connection goes through a
+// proxy set with DialWithDialFunc.
+func DialWithAllowForeignDataAddress(allowed bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.allowForeignData = allowed
+	}}
+}
+
+// DialWithAllowPrivilegedDataPort returns a DialOption that allows the data
+// connections to the ports below 1024 announced by PASV or EPSV, which are
+// refused by default with a DataAddressError.
+func DialWithAllowPrivilegedDataPort(allowed bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.allowLowDataPort = allowed
+	}}
+}
+
+// dataAddr checks the address of a passive data connection announced by the
+// server, and returns the host to connect to.
+func (c *ServerConn) dataAddr(host string, port int) (string, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		host = c.host
+	} else if !c.options.allowForeignData && !ip.Equal(net.ParseIP(c.host)) {
+		return "", &DataAddressError{Addr: addr, Err: ErrForeignDataAddress}
+	}
+
+	if port < 1024 && !c.options.allowLowDataPort {
+		return "", &DataAddressError{Addr: addr, Err: ErrPrivilegedDataPort}
+	}
+	return host, nil
+}
+
+// checkDataPeer checks the peer of an active data connection.
+func (c *ServerConn) checkDataPeer(conn net.Conn) error {
+	if c.options.allowForeignData {
+		return nil
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if ok && addr.IP.Equal(net.ParseIP(c.host)) {
+		return nil
+	}
+	return &DataAddressError{Addr: conn.RemoteAddr().String(), Err: ErrForeignDataAddress}
+}
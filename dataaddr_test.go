@@ -0,0 +1,88 @@
+package ftp
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataAddr(t *testing.T) {
+	for _, test := range []struct {
+		host    string
+		port    int
+		options []DialOption
+		want    string
+		err     error
+	}{
+		{"127.0.0.1", 50000, nil, "127.0.0.1", nil},
+		{"0.0.0.0", 50000, nil, "127.0.0.1", nil},
+		{"10.0.0.1", 50000, nil, "", ErrForeignDataAddress},
+		{"10.0.0.1", 50000, []DialOption{DialWithAllowForeignDataAddress(true)}, "10.0.0.1", nil},
+		{"127.0.0.1", 25, nil, "", ErrPrivilegedDataPort},
+		{"127.0.0.1", 25, []DialOption{DialWithAllowPrivilegedDataPort(true)}, "127.0.0.1", nil},
+	} {
+		do := &dialOptions{}
+		for _, option := range test.options {
+			option.setup(do)
+		}
+		c := &ServerConn{options: do, host: "127.0.0.1"}
+
+		host, err := c.dataAddr(test.host, test.port)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), "%s: %v", test.host, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, host)
+	}
+}
+
+func TestForeignPASVAddress(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"PASV": "227 Entering Passive Mode (10,0,0,1,200,10)",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s, DialWithDisabledEPSV(true))
+
+	_, err := c.List("/")
+	var addrErr *DataAddressError
+	if assert.True(t, errors.As(err, &addrErr), "%v", err) {
+		assert.Equal(t, "10.0.0.1:51210", addrErr.Addr)
+		assert.True(t, errors.Is(err, ErrForeignDataAddress))
+	}
+	assert.Zero(t, countCommands(s, "LIST"))
+	assert.NoError(t, c.Quit())
+}
+
+func TestCheckDataPeer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// A connection from another host than the server
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 2)}}
+	conn, err := dialer.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Skip("can not dial from 127.0.0.2:", err)
+	}
+	defer conn.Close()
+	peer, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	c := &ServerConn{options: &dialOptions{}, host: "127.0.0.1"}
+	err = c.checkDataPeer(peer)
+	assert.True(t, errors.Is(err, ErrForeignDataAddress), "%v", err)
+
+	c.host = "127.0.0.2"
+	assert.NoError(t, c.checkDataPeer(peer))
+}
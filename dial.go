@@ -0,0 +1,170 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// dialStagger is the delay before the next address of the server is tried
+// while the previous attempts are still pending, as recommended by RFC 8305
+const dialStagger = 250 * time.Millisecond
+
+// dialControl connects to the control connection at addr, over TLS with
+// implicit TLS. When the name of the server resolves to several addresses,
+// they are all tried, see dialAddrs.
+func (do *dialOptions) dialControl(addr string) (net.Conn, error) {
+	ctx := do.context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// The timeout is the budget of the whole dial, including the name
+	// resolution and the TLS handshake
+	if do.dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, do.dialer.Timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	if ip := net.ParseIP(host); ip != nil {
+		conn, err = do.dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = do.dialAddrs(ctx, host, port)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if do.tlsConfig == nil || do.explicitTLS {
+		return conn, nil
+	}
+	config := do.tlsConfig
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = host
+	}
+	tconn := tls.Client(conn, config)
+	if err := tconn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tconn, nil
+}
+
+// dialAddrs resolves host and connects to the first of its addresses to
+// answer. The attempts are staggered: the next address is tried when the
+// previous attempt failed, or after dialStagger, so that an unreachable
+// address does not use up the timeout. The families of the addresses
+// alternate, starting with the first resolved. On failure, the error of each
+// address is returned.
+func (do *dialOptions) dialAddrs(ctx context.Context, host, port string) (net.Conn, error) {
+	lookup := do.lookup
+	if lookup == nil {
+		resolver := do.dialer.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		lookup = resolver.LookupIPAddr
+	}
+	ips, err := lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	ips = interleaveFamilies(ips)
+
+	// The deadline of the attempts is the one of ctx
+	dialer := do.dialer
+	dialer.Timeout = 0
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(ips))
+	dial := func(ip net.IPAddr) {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+		results <- result{conn, err}
+	}
+
+	timer := time.NewTimer(dialStagger)
+	defer timer.Stop()
+
+	var errs *multierror.Error
+	next, pending := 0, 0
+	for {
+		if next < len(ips) && pending == 0 {
+			// Nothing else is pending: no need to wait
+			go dial(ips[next])
+			next++
+			pending++
+			timer.Reset(dialStagger)
+		}
+
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// The other attempts are canceled, and their
+				// connections closed if they completed anyway
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if res := <-results; res.conn != nil {
+							_ = res.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			errs = multierror.Append(errs, res.err)
+			if next == len(ips) && pending == 0 {
+				return nil, errs.ErrorOrNil()
+			}
+		case <-timer.C:
+			if next < len(ips) {
+				go dial(ips[next])
+				next++
+				pending++
+				timer.Reset(dialStagger)
+			}
+		}
+	}
+}
+
+// interleaveFamilies orders ips alternating IPv6 and IPv4 addresses, starting
+// with the family of the first one, as described by RFC 8305.
+func interleaveFamilies(ips []net.IPAddr) []net.IPAddr {
+	var first, other []net.IPAddr
+	for _, ip := range ips {
+		if len(first) == 0 || (ip.IP.To4() == nil) == (first[0].IP.To4() == nil) {
+			first = append(first, ip)
+		} else {
+			other = append(other, ip)
+		}
+	}
+
+	ordered := make([]net.IPAddr, 0, len(ips))
+	for i := 0; i < len(first) || i < len(other); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(other) {
+			ordered = append(ordered, other[i])
+		}
+	}
+	return ordered
+}
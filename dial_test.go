@@ -0,0 +1,75 @@
+package ftp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// dialWithLookup returns a DialOption resolving every name to ips
+func dialWithLookup(ips ...string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			var addrs []net.IPAddr
+			for _, ip := range ips {
+				addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+			}
+			return addrs, nil
+		}
+	}}
+}
+
+func TestDialAddrs(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	_, port, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first address is unreachable, and must not use up the timeout
+	start := time.Now()
+	c, err := Dial(net.JoinHostPort("ftp.example.com", port),
+		DialWithTimeout(5*time.Second), dialWithLookup("192.0.2.1", "127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+	assert.Equal(t, s.Addr, c.RemoteAddr().String())
+	assert.Equal(t, "127.0.0.1", c.host)
+	assert.NoError(t, c.Quit())
+}
+
+func TestDialAddrsFailure(t *testing.T) {
+	// A port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	_ = l.Close()
+
+	_, err = Dial(net.JoinHostPort("ftp.example.com", port),
+		DialWithTimeout(5*time.Second), dialWithLookup("127.0.0.2", "127.0.0.3"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "127.0.0.2:"+port)
+		assert.Contains(t, err.Error(), "127.0.0.3:"+port)
+	}
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	var ips []net.IPAddr
+	for _, ip := range []string{"::1", "::2", "::3", "10.0.0.1", "10.0.0.2"} {
+		ips = append(ips, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	var ordered []string
+	for _, ip := range interleaveFamilies(ips) {
+		ordered = append(ordered, ip.String())
+	}
+	assert.Equal(t, []string{"::1", "10.0.0.1", "::2", "10.0.0.2", "::3"}, ordered)
+}
@@ -0,0 +1,423 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DirOption represents an option for DownloadDir and UploadDir
+type DirOption struct {
+	setup func(do *dirOptions)
+}
+
+// dirOptions contains all the options set by DirOption.setup
+type dirOptions struct {
+	skipUnchanged   bool
+	symlinks        bool
+	progress        func(DirProgress)
+	filter          func(name string, d fs.DirEntry) bool
+	dryRun          bool
+	setModTime      bool
+	transferOptions []TransferOption
+}
+
+// DirProgress describes a file handled by DownloadDir or UploadDir, once it
+// is done.
+type DirProgress struct {
+	Remote  string // path of the file on the server
+	Local   string // path of the local file
+	Size    int64  // number of bytes transferred, or to transfer in dry runs
+	Skipped bool   // set if the file was left untouched
+	Err     error  // error which occurred while transferring the file
+}
+
+// DirWithSkipUnchanged returns a DirOption that skips the files whose local
+// copy already has the size and modification time of the remote one.
+func DirWithSkipUnchanged(skip bool) DirOption {
+	return DirOption{func(do *dirOptions) {
+		do.skipUnchanged = skip
+	}}
+}
+
+// DirWithSymlinks returns a DirOption that recreates symbolic links locally,
+// when the server provides their target. By default they are skipped.
+func DirWithSymlinks(recreate bool) DirOption {
+	return DirOption{func(do *dirOptions) {
+		do.symlinks = recreate
+	}}
+}
+
+// DirWithProgress returns a DirOption calling fn after each file.
+func DirWithProgress(fn func(DirProgress)) DirOption {
+	return DirOption{func(do *dirOptions) {
+		do.progress = fn
+	}}
+}
+
+// DirWithFilter returns a DirOption that only transfers the files and
+// directories for which fn returns true. name is relative to the transferred
+// directory. Excluded directories are not descended into.
+func DirWithFilter(fn func(name string, d fs.DirEntry) bool) DirOption {
+	return DirOption{func(do *dirOptions) {
+		do.filter = fn
+	}}
+}
+
+// DirWithDryRun returns a DirOption that only reports the files which would
+// be transferred, without creating directories or transferring anything.
+// UploadDir does not open any data connection in dry runs.
+func DirWithDryRun(dryRun bool) DirOption {
+	return DirOption{func(do *dirOptions) {
+		do.dryRun = dryRun
+	}}
+}
+
+// DirWithModTime returns a DirOption that makes UploadDir set the
+// modification time of the remote files to the one of the local files, when
+// the server supports it.
+func DirWithModTime(enabled bool) DirOption {
+	return DirOption{func(do *dirOptions) {
+		do.setModTime = enabled
+	}}
+}
+
+// DirWithTransferOptions returns a DirOption applying options to the
+// transfer of every file.
+func DirWithTransferOptions(options ...TransferOption) DirOption {
+	return DirOption{func(do *dirOptions) {
+		do.transferOptions = append(do.transferOptions, options...)
+	}}
+}
+
+func newDirOptions(options []DirOption) *dirOptions {
+	do := &dirOptions{}
+	for _, option := range options {
+		option.setup(do)
+	}
+	return do
+}
+
+// include reports whether the filter lets name through
+func (do *dirOptions) include(name string, d fs.DirEntry) bool {
+	return do.filter == nil || name == "." || do.filter(name, d)
+}
+
+func (do *dirOptions) report(p DirProgress) {
+	if do.progress != nil {
+		do.progress(p)
+	}
+}
+
+// ErrUnsafeName is matched by the errors of DownloadDir for the entries of
+// the listings whose name could write outside of the local directory, such
+// as "../x", or through a symbolic link it created.
+var ErrUnsafeName = errors.New("unsafe file name")
+
+// DownloadDir copies the remote directory tree to the local directory,
+// creating it if needed, and preserves the modification times of the files.
+//
+// Each file is first written to a temporary file, renamed once it is
+// complete, so that failed downloads leave no partial files behind. Failures
+// do not stop the download of the other files: all the errors are returned
+// together as Errors.
+//
+// The entries named "." or "..", or with a slash or a backslash, are not
+// downloaded, nor the ones which would be written through a symbolic link
+// created by the download: they fail with ErrUnsafeName, so that a hostile
+// server can not write outside of local.
+func (c *ServerConn) DownloadDir(remote, local string, options ...DirOption) error {
+	do := newDirOptions(options)
+	remote = path.Clean(remote)
+
+	var errs Errors
+	links := make(map[string]bool) // local paths of the links created
+	err := c.WalkDir(remote, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs.add("LIST", name, err)
+			return nil
+		}
+
+		rel := relPath(remote, name)
+		if name != remote && !isSafeName(d.Name()) {
+			errs.add("LIST", name, ErrUnsafeName)
+			return skipEntry(d)
+		}
+		if !do.include(rel, d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		entry := d.(entryInfo).e
+		localPath := filepath.Join(local, filepath.FromSlash(rel))
+		if !isBelow(local, localPath) || throughLink(links, local, localPath) {
+			errs.add("LIST", name, ErrUnsafeName)
+			return skipEntry(d)
+		}
+		if do.dryRun {
+			if entry.Type == EntryTypeFile {
+				do.report(DirProgress{Remote: name, Local: localPath, Size: int64(entry.Size)})
+			}
+			return nil
+		}
+
+		switch entry.Type {
+		case EntryTypeFolder:
+			if err := os.MkdirAll(localPath, 0755); err != nil {
+				errs.add("mkdir", name, err)
+				return fs.SkipDir
+			}
+		case EntryTypeFile:
+			p := c.downloadFile(name, localPath, entry, do)
+			errs.add("RETR", name, p.Err)
+			do.report(p)
+		case EntryTypeLink:
+			p := DirProgress{Remote: name, Local: localPath, Skipped: true}
+			if do.symlinks && entry.Target != "" {
+				p.Skipped = false
+				_ = os.Remove(localPath)
+				p.Err = os.Symlink(filepath.FromSlash(entry.Target), localPath)
+				errs.add("symlink", name, p.Err)
+				if p.Err == nil {
+					links[localPath] = true
+				}
+			}
+			do.report(p)
+		}
+		return nil
+	})
+	errs.add("LIST", remote, err)
+
+	return errs.errorOrNil()
+}
+
+// downloadFile downloads a single file of DownloadDir.
+func (c *ServerConn) downloadFile(remote, local string, entry *Entry, do *dirOptions) (p DirProgress) {
+	p = DirProgress{Remote: remote, Local: local}
+
+	modTime := entry.Time
+	if !c.IsTimePreciseInList() && c.mdtmSupported {
+		if t, err := c.GetTime(remote); err == nil {
+			modTime = t
+		}
+	}
+
+	if do.skipUnchanged {
+		info, err := os.Stat(local)
+		if err == nil && info.Mode().IsRegular() && uint64(info.Size()) == entry.Size &&
+			info.ModTime().Truncate(time.Second).Equal(modTime.Truncate(time.Second)) {
+			p.Skipped = true
+			return p
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(local), "."+filepath.Base(local)+".*.part")
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	defer func() {
+		if p.Err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	r, err := c.Retr(remote, do.transferOptions...)
+	if err != nil {
+		p.Err = err
+		return p
+	}
+
+	// A failed copy aborts the transfer, like RetrToFile
+	if p.Size, p.Err = io.Copy(tmp, r); p.Err != nil {
+		p.Err = r.stop(p.Err)
+		return p
+	}
+	if p.Err = r.Close(); p.Err != nil {
+		return p
+	}
+
+	mode := os.FileMode(0644)
+	if entry.HasMode() {
+		mode = entry.Mode().Perm()
+	}
+	if p.Err = tmp.Chmod(mode); p.Err != nil {
+		return p
+	}
+	if p.Err = tmp.Close(); p.Err != nil {
+		return p
+	}
+	if !modTime.IsZero() {
+		if p.Err = os.Chtimes(tmp.Name(), modTime, modTime); p.Err != nil {
+			return p
+		}
+	}
+	p.Err = os.Rename(tmp.Name(), local)
+	return p
+}
+
+// UploadDir copies the local directory tree to the remote directory. See
+// UploadFS.
+func (c *ServerConn) UploadDir(local, remote string, options ...DirOption) error {
+	return c.UploadFS(os.DirFS(local), remote, options...)
+}
+
+// UploadFS copies the tree of fsys to the remote directory, creating the
+// remote directories as needed. Files which are neither regular files nor
+// directories are skipped.
+//
+// Failures do not stop the upload of the other files: all the errors are
+// returned together as Errors.
+func (c *ServerConn) UploadFS(fsys fs.FS, remote string, options ...DirOption) error {
+	do := newDirOptions(options)
+
+	var errs Errors
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs.add("STOR", path.Join(remote, name), err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !do.include(name, d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		remotePath := path.Join(remote, name)
+		switch {
+		case d.IsDir():
+			if do.dryRun {
+				return nil
+			}
+			mkdir := c.makeDir
+			if name == "." {
+				mkdir = c.MakeDirAll
+			}
+			if err := mkdir(remotePath); err != nil {
+				errs.add("MKD", remotePath, err)
+				return fs.SkipDir
+			}
+		case d.Type().IsRegular():
+			p := c.uploadFile(fsys, name, remotePath, do)
+			errs.add("STOR", remotePath, p.Err)
+			do.report(p)
+		default:
+			do.report(DirProgress{Remote: remotePath, Local: name, Skipped: true})
+		}
+		return nil
+	})
+	errs.add("STOR", remote, err)
+
+	return errs.errorOrNil()
+}
+
+// uploadFile uploads a single file of UploadFS.
+func (c *ServerConn) uploadFile(fsys fs.FS, name, remote string, do *dirOptions) (p DirProgress) {
+	p = DirProgress{Remote: remote, Local: name}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	if do.dryRun {
+		p.Size = info.Size()
+		return p
+	}
+
+	counter := &countingReader{r: f}
+	p.Err = c.Stor(remote, counter)
+	p.Size = counter.n
+	if p.Err == nil && do.setModTime && c.IsSetTimeSupported() && !info.ModTime().IsZero() {
+		p.Err = c.SetTime(remote, info.ModTime())
+	}
+	return p
+}
+
+// makeDir creates a remote directory, unless it already exists.
+func (c *ServerConn) makeDir(path string) error {
+	err := c.MakeDir(path)
+	if err != nil {
+		if exists, _ := c.DirExists(path); exists {
+			return nil
+		}
+	}
+	return err
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	r.n += int64(n)
+	return n, err
+}
+
+// isSafeName reports whether the name of an entry of a listing designates a
+// file of the listed directory.
+func isSafeName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\")
+}
+
+// isBelow reports whether the local path p is root or below it.
+func isBelow(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// throughLink reports whether the local path p, below root, is one of links
+// or below one of them.
+func throughLink(links map[string]bool, root, p string) bool {
+	for ; p != root && isBelow(root, p); p = filepath.Dir(p) {
+		if links[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// skipEntry skips the directory d, or the file d alone, in a WalkDirFunc.
+func skipEntry(d fs.DirEntry) error {
+	if d.IsDir() {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// relPath returns the path of name, a file below root, relative to root.
+func relPath(root, name string) string {
+	switch {
+	case name == root:
+		return "."
+	case root == ".":
+		return name
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+}
@@ -0,0 +1,237 @@
+package ftp
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadDir(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	err := c.Stor("file", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+
+	local, err := ioutil.TempDir("", "ftp")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(local)
+
+	var done []string
+	err = c.DownloadDir("unix", local, DirWithProgress(func(p DirProgress) {
+		assert.NoError(t, p.Err)
+		assert.Equal(t, int64(len(testData)), p.Size)
+		done = append(done, p.Remote)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unix/a.txt", "unix/b.txt", "unix/pub/lo"}, done)
+
+	for _, name := range []string{"a.txt", "b.txt", filepath.Join("pub", "lo")} {
+		content, err := ioutil.ReadFile(filepath.Join(local, name))
+		assert.NoError(t, err)
+		assert.Equal(t, testData, string(content))
+	}
+
+	info, err := os.Stat(filepath.Join(local, "a.txt"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, newTime(2009, time.December, 2), info.ModTime().UTC())
+		assert.Equal(t, os.FileMode(0644), info.Mode())
+	}
+
+	// no temporary file is left behind
+	files, err := ioutil.ReadDir(local)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestDownloadDirSkipUnchanged(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	local, err := ioutil.TempDir("", "ftp")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(local)
+
+	// the mock serves empty files, so only the empty "lo" matches the listing
+	assert.NoError(t, c.Stor("file", &bytes.Buffer{}))
+	assert.NoError(t, c.DownloadDir("unix", local))
+
+	var skipped []string
+	err = c.DownloadDir("unix", local, DirWithSkipUnchanged(true), DirWithProgress(func(p DirProgress) {
+		if p.Skipped {
+			skipped = append(skipped, p.Remote)
+		}
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unix/pub/lo"}, skipped)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestDownloadDirMaxBytes(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	err := c.Stor("file", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+
+	local, err := ioutil.TempDir("", "ftp")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(local)
+
+	err = c.DownloadDir("unix", local, DirWithTransferOptions(TransferWithMaxBytes(5)))
+	assert.True(t, errors.Is(err, ErrMaxSizeExceeded))
+
+	// partial files are removed
+	files, err := ioutil.ReadDir(local)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		assert.Equal(t, "pub", files[0].Name())
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+var uploadFS = fstest.MapFS{
+	"a.txt":      {Data: []byte(testData), ModTime: newTime(2020, time.December, 13, 20, 24)},
+	"sub/b.txt":  {Data: []byte("b")},
+	"skip/c.txt": {Data: []byte("c")},
+}
+
+func TestUploadFS(t *testing.T) {
+	mock, c := openConnExt(t, "127.0.0.1", "std-time")
+
+	var done []DirProgress
+	err := c.UploadFS(uploadFS, "existing-dir",
+		DirWithModTime(true),
+		DirWithFilter(func(name string, d fs.DirEntry) bool {
+			return name != "skip"
+		}),
+		DirWithProgress(func(p DirProgress) {
+			done = append(done, p)
+		}))
+	assert.NoError(t, err)
+	if assert.Len(t, done, 2) {
+		assert.Equal(t, DirProgress{Remote: "existing-dir/a.txt", Local: "a.txt", Size: int64(len(testData))}, done[0])
+		assert.Equal(t, DirProgress{Remote: "existing-dir/sub/b.txt", Local: "sub/b.txt", Size: 1}, done[1])
+	}
+
+	closeConn(t, mock, c, []string{
+		"MKD", "PWD", "CWD", "CWD", // the existing directory is tolerated
+		"EPSV", "STOR", "MFMT",
+		"MKD",
+		"EPSV", "STOR",
+	})
+}
+
+func TestUploadFSDryRun(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	var done []DirProgress
+	err := c.UploadFS(uploadFS, "dir", DirWithDryRun(true), DirWithProgress(func(p DirProgress) {
+		done = append(done, p)
+	}))
+	assert.NoError(t, err)
+	if assert.Len(t, done, 3) {
+		assert.Equal(t, DirProgress{Remote: "dir/a.txt", Local: "a.txt", Size: int64(len(testData))}, done[0])
+	}
+
+	closeConn(t, mock, c, nil)
+}
+
+func TestDownloadDirUnsafeNames(t *testing.T) {
+	outside, err := ioutil.TempDir("", "ftp-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	s := ftptest.NewServer(ftptest.Script{
+		Listings: map[string]string{
+			"pub": "-rw-r--r-- 1 ftp ftp 14 Dec 02 2009 ../../evil\r\n" +
+				"-rw-r--r-- 1 ftp ftp 14 Dec 02 2009 ok.txt\r\n" +
+				"lrwxrwxrwx 1 ftp ftp 14 Dec 02 2009 d -> " + filepath.ToSlash(outside) + "\r\n" +
+				"drwxr-xr-x 1 ftp ftp 0 Dec 02 2009 d\r\n",
+			"pub/d": "-rw-r--r-- 1 ftp ftp 14 Dec 02 2009 f\r\n",
+		},
+		Files: map[string][]byte{
+			"../evil":    []byte(testData),
+			"pub/ok.txt": []byte(testData),
+			"pub/d/f":    []byte(testData),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	parent, err := ioutil.TempDir("", "ftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(parent)
+	local := filepath.Join(parent, "a", "b")
+
+	err = c.DownloadDir("pub", local, DirWithSymlinks(true))
+	assert.True(t, errors.Is(err, ErrUnsafeName), "%v", err)
+
+	content, err := ioutil.ReadFile(filepath.Join(local, "ok.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, testData, string(content))
+
+	_, err = os.Stat(filepath.Join(parent, "evil"))
+	assert.True(t, os.IsNotExist(err), "%v", err)
+	files, err := ioutil.ReadDir(outside)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestDownloadDirLocalErrors(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Listings: map[string]string{
+			"pub": "drwxr-xr-x 1 ftp ftp 0 Dec 02 2009 sub\r\n",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	local, err := ioutil.TempDir("", "ftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(local)
+	// A file stands where the directory is created
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(local, "sub"), nil, 0644))
+
+	err = c.DownloadDir("pub", local)
+	var errs Errors
+	if assert.True(t, errors.As(err, &errs)) && assert.Len(t, errs, 1) {
+		pathErr := errs[0].(*PathError)
+		assert.Equal(t, "mkdir", pathErr.Op)
+		assert.Equal(t, "pub/sub", pathErr.Path)
+
+		var localErr *fs.PathError
+		if assert.True(t, errors.As(pathErr.Err, &localErr)) {
+			assert.Equal(t, filepath.Join(local, "sub"), localErr.Path)
+		}
+	}
+
+	assert.NoError(t, c.Quit())
+}
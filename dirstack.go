@@ -0,0 +1,72 @@
+package ftp
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// ErrDirStackEmpty is returned by PopDir when no directory was pushed.
+var ErrDirStackEmpty = errors.New("directory stack is empty")
+
+// PushDir changes the working directory to dir, like ChangeDir, remembering
+// the previous one so that PopDir can go back to it. The stack is left
+// unchanged if the working directory can not be changed.
+func (c *ServerConn) PushDir(dir string) error {
+	cwd, err := c.CachedCurrentDir()
+	if err != nil {
+		return err
+	}
+	if err := c.ChangeDir(dir); err != nil {
+		return err
+	}
+	c.dirStack = append(c.dirStack, cwd)
+	return nil
+}
+
+// PopDir changes the working directory back to the one before the last call
+// to PushDir, and returns ErrDirStackEmpty if there is none. The directory
+// stays on the stack if the working directory can not be changed, so that
+// the stack keeps matching the state of the server.
+func (c *ServerConn) PopDir() error {
+	if len(c.dirStack) == 0 {
+		return ErrDirStackEmpty
+	}
+	if err := c.ChangeDir(c.dirStack[len(c.dirStack)-1]); err != nil {
+		return err
+	}
+	c.dirStack = c.dirStack[:len(c.dirStack)-1]
+	return nil
+}
+
+// CachedCurrentDir returns the working directory like CurrentDir, without
+// asking the server again when it is already known. It is tracked along
+// the changes of directory made by ChangeDir, and asked to the server with
+// PWD when a change can not be followed, e.g. to a relative path of a server
+// whose paths are not UNIX-like.
+func (c *ServerConn) CachedCurrentDir() (string, error) {
+	if c.cwd != "" {
+		return c.cwd, nil
+	}
+	return c.CurrentDir()
+}
+
+// trackDir updates the cached working directory after a CWD command to dir
+// which ended with err.
+func (c *ServerConn) trackDir(dir string, err error) {
+	switch {
+	case err != nil:
+		// The working directory is unchanged when the server refuses the
+		// command, but it can not be told after a network error
+		var pathErr *PathError
+		if !errors.As(err, &pathErr) {
+			c.cwd = ""
+		}
+	case strings.HasPrefix(dir, "/"):
+		c.cwd = path.Clean(dir)
+	case strings.HasPrefix(c.cwd, "/") && !strings.ContainsAny(dir, "'\\"):
+		c.cwd = path.Join(c.cwd, dir)
+	default:
+		c.cwd = ""
+	}
+}
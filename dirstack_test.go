@@ -0,0 +1,87 @@
+package ftp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func countCommands(s *ftptest.Server, verb string) int {
+	n := 0
+	for _, cmd := range s.Commands() {
+		if cmd == verb || strings.HasPrefix(cmd, verb+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPushDir(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"CWD /missing": "550 No such file or directory",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	assert.Equal(t, ErrDirStackEmpty, c.PopDir())
+
+	assert.NoError(t, c.PushDir("/pub"))
+	assert.NoError(t, c.PushDir("incoming"))
+	dir, err := c.CachedCurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/pub/incoming", dir)
+
+	// A failed change leaves the stack as is
+	assert.Error(t, c.PushDir("/missing"))
+	dir, err = c.CurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/pub/incoming", dir)
+
+	assert.NoError(t, c.PopDir())
+	dir, err = c.CachedCurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/pub", dir)
+
+	assert.NoError(t, c.PopDir())
+	dir, err = c.CurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/", dir)
+	assert.True(t, errors.Is(c.PopDir(), ErrDirStackEmpty))
+
+	// The directory was only asked to the server once, and checked twice
+	assert.Equal(t, 3, countCommands(s, "PWD"))
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestCachedCurrentDirInvalidated(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	assert.NoError(t, c.ChangeDir("/pub/incoming"))
+	dir, err := c.CachedCurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/pub/incoming", dir)
+	assert.Equal(t, 0, countCommands(s, "PWD"))
+
+	assert.NoError(t, c.ChangeDirToParent())
+	dir, err = c.CachedCurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/pub", dir)
+	assert.Equal(t, 1, countCommands(s, "PWD"))
+
+	_, _, err = c.Cmd(-1, "CWD /")
+	assert.NoError(t, err)
+	dir, err = c.CachedCurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/", dir)
+	assert.Equal(t, 2, countCommands(s, "PWD"))
+
+	assert.NoError(t, c.Quit())
+}
@@ -0,0 +1,49 @@
+package ftp
+
+import (
+	"io"
+	"net"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// SetDataConnTranslation sets the code pages a z/OS server translates the
+// data of TYPE A transfers between, with a SITE SBDATACONN command, e.g.
+// "IBM-1047,ISO8859-1" for the EBCDIC code page of the datasets and the
+// ASCII one of the client. The surrounding parentheses are optional.
+func (c *ServerConn) SetDataConnTranslation(codepagePair string) error {
+	if !strings.HasPrefix(codepagePair, "(") {
+		codepagePair = "(" + codepagePair + ")"
+	}
+	_, _, err := c.Site("SBDATACONN=" + codepagePair)
+	return err
+}
+
+// TransferWithEncoding returns a TransferOption that converts the data of a
+// single transfer on the client, between UTF-8 and the character set enc:
+// downloads are decoded, and uploads are encoded.
+//
+// It is meant for the z/OS servers which transfer the datasets as is, with
+// TransferTypeBinary or TransferTypeEBCDIC. charmap.CodePage037 and
+// charmap.CodePage1047 from golang.org/x/text/encoding/charmap are the
+// common EBCDIC code pages. Only the characters are converted: records are
+// not delimited by line endings.
+//
+// Offsets given to RetrFrom and StorFrom count the bytes as sent by the
+// server.
+func TransferWithEncoding(enc encoding.Encoding) TransferOption {
+	return TransferOption{func(to *transferOptions) {
+		to.encoding = enc
+	}}
+}
+
+// transformConn reads a data connection through a decoder
+type transformConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *transformConn) Read(buf []byte) (int, error) {
+	return c.r.Read(buf)
+}
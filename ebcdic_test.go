@@ -0,0 +1,51 @@
+package ftp
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestTransferWithEncoding(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	tests := []struct {
+		enc    *charmap.Charmap
+		ebcdic []byte
+	}{
+		{charmap.CodePage1047, []byte{0xad, 0xc8, 0x85, 0x93, 0x93, 0x96, 0xbd}},
+		{charmap.CodePage037, []byte{0xba, 0xc8, 0x85, 0x93, 0x93, 0x96, 0xbb}},
+	}
+	for _, test := range tests {
+		err := c.Stor("'HLQ.DATA'", strings.NewReader("[Hello]"), TransferWithEncoding(test.enc), TransferWithType(TransferTypeEBCDIC))
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.ebcdic, mock.fileCont.Bytes())
+		}
+
+		r, err := c.Retr("'HLQ.DATA'", TransferWithEncoding(test.enc), TransferWithType(TransferTypeEBCDIC))
+		if assert.NoError(t, err) {
+			buf, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "[Hello]", string(buf))
+			assert.NoError(t, r.Close())
+		}
+	}
+	assert.Equal(t, TransferTypeEBCDIC, c.currentType)
+
+	closeConn(t, mock, c, []string{"TYPE", "EPSV", "STOR", "EPSV", "RETR", "EPSV", "STOR", "EPSV", "RETR"})
+}
+
+func TestSetDataConnTranslation(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	assert.NoError(t, c.SetDataConnTranslation("IBM-1047,ISO8859-1"))
+	assert.Equal(t, "SITE SBDATACONN=(IBM-1047,ISO8859-1)", mock.lastFull)
+
+	assert.NoError(t, c.SetDataConnTranslation("(IBM-037,UTF-8)"))
+	assert.Equal(t, "SITE SBDATACONN=(IBM-037,UTF-8)", mock.lastFull)
+
+	closeConn(t, mock, c, []string{"SITE", "SITE"})
+}
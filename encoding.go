@@ -0,0 +1,53 @@
+package ftp
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding"
+)
+
+// DialWithEncoding returns a DialOption that sets the character encoding of
+// servers which do not use UTF-8, e.g. charmap.ISO8859_1 or
+// simplifiedchinese.GBK from golang.org/x/text/encoding.
+//
+// The replies and the listings are decoded to UTF-8, and the arguments of
+// the commands are encoded back, so that a name returned by List can be
+// passed as is to Retr. Commands with characters that the encoding can not
+// represent fail without being sent. OPTS UTF8 ON is not sent.
+func DialWithEncoding(enc encoding.Encoding) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.encoding = enc
+	}}
+}
+
+// sendCmd sends a command on the control connection, encoding it if needed.
+func (c *ServerConn) sendCmd(format string, args ...interface{}) error {
+	line := fmt.Sprintf(format, args...)
+	if c.options.encoding != nil {
+		encoded, err := c.options.encoding.NewEncoder().String(line)
+		if err != nil {
+			return fmt.Errorf("can not encode command %q: %w", line, err)
+		}
+		line = encoded
+	}
+
+	_, err := c.conn.Cmd("%s", line)
+	return err
+}
+
+// decodingConn decodes what is read from the control connection
+type decodingConn struct {
+	io.ReadWriteCloser
+	r io.Reader
+}
+
+func (c *decodingConn) Read(buf []byte) (int, error) {
+	return c.r.Read(buf)
+}
+
+// decodingStream decodes a listing
+type decodingStream struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,47 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestEncodingLatin1(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "latin1", DialWithEncoding(charmap.ISO8859_1))
+
+	dir, err := c.CurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/café", dir)
+
+	entries, err := c.List("latin1")
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, "café", entries[0].Name)
+
+		assert.NoError(t, c.Delete(entries[0].Name))
+		assert.Equal(t, "DELE caf\xe9", mock.lastFull)
+	}
+
+	// characters which can not be encoded are not sent
+	assert.Error(t, c.Delete("中文"))
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+	assert.NotContains(t, mock.commands, "OPTS")
+}
+
+func TestEncodingGBK(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithEncoding(simplifiedchinese.GBK))
+
+	entries, err := c.List("gbk")
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, "中文.txt", entries[0].Name)
+
+		assert.NoError(t, c.Delete(entries[0].Name))
+		assert.Equal(t, "DELE \xd6\xd0\xce\xc4.txt", mock.lastFull)
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
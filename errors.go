@@ -0,0 +1,96 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errorsShown is how many messages the Error method of Errors includes
+const errorsShown = 3
+
+// Errors is returned by the operations which go on after a failure to report
+// all of them: RemoveDirRecur, DownloadDir, UploadDir, UploadFS and
+// DownloadSegmented.
+//
+// Each error is a *PathError carrying the remote path it relates to, so that
+// exactly the failed subset can be retried. Its Op is the FTP command which
+// failed, or "mkdir" and "symlink" for the local directories and links of
+// DownloadDir, whose Err then carries the local path. errors.Is and errors.As
+// match any of the errors.
+type Errors []error
+
+// Error summarizes the number of errors and the first messages.
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors occurred", len(e))
+	for i, err := range e {
+		if i == errorsShown {
+			fmt.Fprintf(&b, "; and %d more", len(e)-i)
+			break
+		}
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		b.WriteString(sep)
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors, for errors.Is and errors.As.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the errors matches target. Unwrap is enough from
+// Go 1.20 on.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors which matches target. Unwrap is enough
+// from Go 1.20 on.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends err, unless it is nil, as a *PathError of op on path. The
+// *PathError of another path are kept as is, and the errors of an Errors are
+// added one by one.
+func (e *Errors) add(op, path string, err error) {
+	switch err := err.(type) {
+	case nil:
+	case Errors:
+		for _, err := range err {
+			e.add(op, path, err)
+		}
+	case *PathError:
+		*e = append(*e, err)
+	default:
+		*e = append(*e, &PathError{Op: op, Path: path, Err: err})
+	}
+}
+
+// errorOrNil returns e, or nil if it is empty.
+func (e Errors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
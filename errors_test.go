@@ -0,0 +1,70 @@
+package ftp
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	assert.NoError(t, errs.errorOrNil())
+
+	errs.add("RETR", "/a", nil)
+	errs.add("RETR", "/a", errors.New("a failed"))
+	assert.Equal(t, "RETR /a: a failed", errs.Error())
+
+	errs.add("RETR", "/b", Errors{
+		errors.New("b failed"),
+		&MaxSizeError{Limit: 5},
+		&PathError{Op: "DELE", Path: "/c", Err: errors.New("c failed")},
+	})
+	errs.add("RETR", "/d", errors.New("d failed"))
+	if assert.Len(t, errs, 5) {
+		assert.Equal(t, &PathError{Op: "RETR", Path: "/b", Err: errors.New("b failed")}, errs[1])
+		assert.Equal(t, "/c", errs[3].(*PathError).Path)
+	}
+	assert.Equal(t, "5 errors occurred: RETR /a: a failed; RETR /b: b failed; "+
+		"RETR /b: "+(&MaxSizeError{Limit: 5}).Error()+"; and 2 more", errs.Error())
+
+	err := errs.errorOrNil()
+	assert.True(t, errors.Is(err, ErrMaxSizeExceeded))
+	var maxSizeErr *MaxSizeError
+	if assert.True(t, errors.As(err, &maxSizeErr)) {
+		assert.Equal(t, int64(5), maxSizeErr.Limit)
+	}
+}
+
+func TestRemoveDirRecurErrors(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"DELE /dir/a":     "550 Permission denied",
+			"DELE /dir/sub/c": "550 Permission denied",
+		},
+		Listings: map[string]string{
+			"/dir": "drwxr-xr-x 1 user group 0 Jan 01  2020 sub\r\n" +
+				"-rw-r--r-- 1 user group 1 Jan 01  2020 a\r\n",
+			"/dir/sub": "-rw-r--r-- 1 user group 1 Jan 01  2020 b\r\n" +
+				"-rw-r--r-- 1 user group 1 Jan 01  2020 c\r\n",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	err := c.RemoveDirRecur("/dir")
+	var errs Errors
+	if assert.True(t, errors.As(err, &errs)) && assert.Len(t, errs, 2) {
+		assert.Equal(t, "/dir/sub/c", errs[0].(*PathError).Path)
+		assert.Equal(t, "/dir/a", errs[1].(*PathError).Path)
+	}
+	assert.True(t, errors.Is(err, fs.ErrPermission))
+
+	// The other files are deleted, but not the directories
+	assert.Contains(t, s.Commands(), "DELE /dir/sub/b")
+	assert.NotContains(t, s.Commands(), "RMD /dir")
+
+	assert.NoError(t, c.Quit())
+}
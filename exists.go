@@ -0,0 +1,109 @@
+package ftp
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FileExists reports whether path is an existing file, which is not a
+// directory. Depending on what the server supports, it is checked with an
+// MLST command, a SIZE command, or by listing the parent directory.
+//
+// It returns false and a nil error if the file does not exist, and an error
+// if the server could not tell.
+func (c *ServerConn) FileExists(path string) (bool, error) {
+	if c.mlstSupported {
+		entry, err := c.mlst(path)
+		if err != nil {
+			return notFound(err)
+		}
+		return entry.Type != EntryTypeFolder, nil
+	}
+
+	if _, ok := c.features["SIZE"]; ok {
+		// SIZE also fails on directories
+		if _, err := c.FileSize(path); err != nil {
+			return notFound(err)
+		}
+		return true, nil
+	}
+
+	entry, err := c.listEntry(path)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	return entry.Type != EntryTypeFolder, nil
+}
+
+// DirExists reports whether path is an existing directory. It is checked
+// with an MLST command when the server supports it, or by changing the
+// working directory to path, restoring it afterwards.
+//
+// It returns false and a nil error if the directory does not exist, and an
+// error if the server could not tell.
+func (c *ServerConn) DirExists(path string) (bool, error) {
+	if c.mlstSupported {
+		entry, err := c.mlst(path)
+		if err != nil {
+			return notFound(err)
+		}
+		return entry.Type == EntryTypeFolder, nil
+	}
+
+	cwd, err := c.CurrentDir()
+	if err != nil {
+		return false, err
+	}
+	if err := c.ChangeDir(path); err != nil {
+		return notFound(err)
+	}
+	if err := c.ChangeDir(cwd); err != nil {
+		return true, fmt.Errorf("can not restore the working directory %q: %w", cwd, err)
+	}
+	return true, nil
+}
+
+// mlst issues an MLST FTP command to get the facts of a single file.
+func (c *ServerConn) mlst(path string) (*Entry, error) {
+	_, msg, err := c.cmd(StatusRequestedFileActionOK, "MLST %s", path)
+	if err != nil {
+		return nil, pathError("MLST", path, err)
+	}
+
+	// The facts are on the second line of the reply, after a space
+	lines := strings.Split(msg, "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("invalid MLST response: %q", msg)
+	}
+	return parseRFC3659ListLine(strings.TrimLeft(lines[1], " "), c.now(), c.options.location)
+}
+
+// listEntry finds the entry of name by listing its parent directory. It
+// returns nil if there is none.
+func (c *ServerConn) listEntry(name string) (*Entry, error) {
+	entries, err := c.List(path.Dir(name))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	base := path.Base(name)
+	for _, entry := range entries {
+		if !entry.Pseudo && entry.FileInfo().Name() == base {
+			return entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// notFound turns the errors telling that a file does not exist into a nil
+// error.
+func notFound(err error) (bool, error) {
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
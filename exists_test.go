@@ -0,0 +1,84 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExistsMLST(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "mlst")
+
+	tests := []struct {
+		path      string
+		file, dir bool
+	}{
+		{"magic-file", true, false},
+		{"some/dir", false, true},
+		{"missing-file", false, false},
+		{"some/missing-dir", false, false},
+	}
+
+	for _, test := range tests {
+		exists, err := c.FileExists(test.path)
+		assert.NoError(t, err, test.path)
+		assert.Equal(t, test.file, exists, test.path)
+
+		exists, err = c.DirExists(test.path)
+		assert.NoError(t, err, test.path)
+		assert.Equal(t, test.dir, exists, test.path)
+	}
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestFileExistsSize(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	exists, err := c.FileExists("magic-file")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = c.FileExists("missing-file")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	closeConn(t, mock, c, []string{"SIZE", "SIZE"})
+}
+
+func TestFileExistsList(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+	delete(c.features, "SIZE")
+
+	exists, err := c.FileExists("unix/a.txt")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = c.FileExists("unix/pub")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = c.FileExists("denied/a.txt")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestDirExistsCwd(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	exists, err := c.DirExists("some-dir")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	// the working directory is restored
+	assert.Equal(t, "CWD /incoming", mock.lastFull)
+
+	exists, err = c.DirExists("missing-dir")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	closeConn(t, mock, c, []string{"PWD", "CWD", "CWD", "PWD", "CWD"})
+}
@@ -0,0 +1,139 @@
+package ftp
+
+import (
+	"errors"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// wantedFacts are the MLST facts requested with OPTS MLST when the server
+// supports them, in the order of the command
+var wantedFacts = []string{"type", "size", "modify", "perm", "unix.mode", "unix.owner", "unix.group"}
+
+// parseFacts parses a list of MLST facts, such as "type*;size*;modify;",
+// into the names of the facts in lower case, and whether they are enabled.
+func parseFacts(list string) map[string]bool {
+	facts := make(map[string]bool)
+	for _, fact := range strings.Split(list, ";") {
+		fact = strings.ToLower(strings.TrimSpace(fact))
+		if fact == "" {
+			continue
+		}
+		enabled := strings.HasSuffix(fact, "*")
+		facts[strings.TrimSuffix(fact, "*")] = enabled
+	}
+	return facts
+}
+
+// negotiateFacts selects the wanted MLST facts which are supported by the
+// server but not enabled yet, as RFC 3659 allows with an OPTS MLST command.
+// The facts left out of the command are turned off, so the ones enabled by
+// default are kept in it. The refusal of the command is not an error: the
+// facts enabled by default are used.
+func (c *ServerConn) negotiateFacts() error {
+	advertised := parseFacts(c.features["MLST"])
+	c.mlstFacts = make(map[string]bool)
+	for fact, enabled := range advertised {
+		if enabled {
+			c.mlstFacts[fact] = true
+		}
+	}
+
+	var wanted []string
+	missing := false
+	for _, fact := range wantedFacts {
+		if _, ok := advertised[fact]; ok {
+			wanted = append(wanted, fact)
+			missing = missing || !advertised[fact]
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	var enabled []string
+	for fact, on := range advertised {
+		if on && !isWantedFact(fact) {
+			enabled = append(enabled, fact)
+		}
+	}
+	sort.Strings(enabled)
+	wanted = append(wanted, enabled...)
+
+	list := strings.Join(wanted, ";") + ";"
+	_, msg, err := c.cmd(StatusCommandOK, "OPTS MLST %s", list)
+	if err != nil {
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) {
+			return nil
+		}
+		return err
+	}
+
+	// The reply tells the selected facts, e.g. "MLST OPTS type;size;", but
+	// not every server repeats them
+	if i := strings.Index(strings.ToUpper(msg), "MLST OPTS"); i >= 0 {
+		list = msg[i+len("MLST OPTS"):]
+	}
+	c.mlstFacts = make(map[string]bool)
+	for fact := range parseFacts(list) {
+		c.mlstFacts[fact] = true
+	}
+	return nil
+}
+
+// MLSTFacts returns the facts of the MLST and MLSD listings of the server, in
+// lower case, e.g. "modify": the fields of the entries which come from other
+// facts are not set, and are meaningless. The fields set by each fact are:
+//
+//	type        Type, Target and Pseudo
+//	size        Size
+//	modify      Time and TimeResolution
+//	create      CreateTime
+//	perm        Perm
+//	unique      Unique
+//	unix.mode   the permissions of Mode, see HasMode
+//	unix.owner  Owner
+//	unix.group  Group
+//
+// Without the type fact in particular, every entry is an EntryTypeFile. The
+// other facts are kept in Facts.
+//
+// The wanted facts are selected with an OPTS MLST command on login, when the
+// server supports them without sending them by default. It returns nil when
+// the server does not support MLST, or does not tell its facts.
+func (c *ServerConn) MLSTFacts() []string {
+	if !c.mlstSupported || len(c.mlstFacts) == 0 {
+		return nil
+	}
+
+	var facts, others []string
+	for _, fact := range wantedFacts {
+		if c.mlstFacts[fact] {
+			facts = append(facts, fact)
+		}
+	}
+	for fact, enabled := range c.mlstFacts {
+		if enabled && !isWantedFact(fact) {
+			others = append(others, fact)
+		}
+	}
+	sort.Strings(others)
+	return append(facts, others...)
+}
+
+func isWantedFact(fact string) bool {
+	for _, wanted := range wantedFacts {
+		if fact == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFact reports whether the MLST listings have the fact, assuming that
+// they do when the server does not tell.
+func (c *ServerConn) hasFact(fact string) bool {
+	return len(c.mlstFacts) == 0 || c.mlstFacts[fact]
+}
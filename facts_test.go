@@ -0,0 +1,106 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateFacts(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		mlst    string // advertised with FEAT
+		reply   string // to OPTS MLST
+		command string // OPTS MLST sent, if any
+		facts   []string
+		precise bool
+	}{
+		{
+			name:    "selected",
+			mlst:    "type*;size;modify;perm;UNIX.mode;media-type;",
+			reply:   "200 MLST OPTS type;size;modify;unix.mode;",
+			command: "OPTS MLST type;size;modify;perm;unix.mode;",
+			facts:   []string{"type", "size", "modify", "unix.mode"},
+			precise: true,
+		},
+		{
+			name:    "not repeated",
+			mlst:    "type*;size*;modify;",
+			reply:   "200 OK",
+			command: "OPTS MLST type;size;modify;",
+			facts:   []string{"type", "size", "modify"},
+			precise: true,
+		},
+		{
+			name:    "kept enabled",
+			mlst:    "type*;size*;modify*;create*;unique*;perm;",
+			reply:   "200 MLST OPTS type;size;modify;perm;create;unique;",
+			command: "OPTS MLST type;size;modify;perm;create;unique;",
+			facts:   []string{"type", "size", "modify", "perm", "create", "unique"},
+			precise: true,
+		},
+		{
+			name:    "refused",
+			mlst:    "type*;size;modify;",
+			reply:   "501 Invalid MLST options",
+			command: "OPTS MLST type;size;modify;",
+			facts:   []string{"type"},
+		},
+		{
+			name:    "enabled",
+			mlst:    "type*;size*;modify*;media-type*;",
+			facts:   []string{"type", "size", "modify", "media-type"},
+			precise: true,
+		},
+		{
+			name:    "unknown",
+			precise: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := ftptest.NewServer(ftptest.Script{
+				Replies: map[string]string{
+					"FEAT":       "211-Features:\r\n EPSV\r\n MLST " + test.mlst + "\r\n211 End",
+					test.command: test.reply,
+				},
+			})
+			defer s.Close()
+			c := dialScript(t, s)
+
+			if test.command != "" {
+				assert.Contains(t, s.Commands(), test.command)
+			} else {
+				assert.Zero(t, countCommands(s, "OPTS MLST"))
+			}
+			assert.Equal(t, test.facts, c.MLSTFacts())
+			assert.Equal(t, test.precise, c.IsTimePreciseInList())
+
+			assert.NoError(t, c.Quit())
+		})
+	}
+}
+
+func TestNegotiateFactsKeepsEnabled(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT": "211-Features:\r\n EPSV\r\n MLST type*;size*;modify*;create*;unique*;perm;\r\n211 End",
+			"OPTS MLST type;size;modify;perm;create;unique;": "200 OK",
+		},
+		Listings: map[string]string{
+			"MLSD /": "type=file;size=4;modify=20240102150405;create=20230101000000;perm=r;unique=801U5; a.txt\r\n",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	assert.Contains(t, s.Commands(), "OPTS MLST type;size;modify;perm;create;unique;")
+	entries, err := c.List("/")
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), entries[0].CreateTime)
+		assert.Equal(t, "801U5", entries[0].Unique)
+	}
+
+	assert.NoError(t, c.Quit())
+}
@@ -0,0 +1,243 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// FileOption represents an option for RetrToFile and StorFromFile
+type FileOption struct {
+	setup func(fo *fileOptions)
+}
+
+// fileOptions contains all the options set by FileOption.setup
+type fileOptions struct {
+	modTime         bool
+	keep            bool
+	progress        func(transferred, total int64)
+	transferOptions []TransferOption
+}
+
+// FileWithModTime returns a FileOption that gives the destination file the
+// modification time of the source one, when the server supports it: with
+// MDTM for RetrToFile, and with MFMT or MDTM for StorFromFile.
+func FileWithModTime(enabled bool) FileOption {
+	return FileOption{func(fo *fileOptions) {
+		fo.modTime = enabled
+	}}
+}
+
+// FileWithOverwrite returns a FileOption that tells whether an existing
+// destination file is replaced, which is the default, or makes the transfer
+// fail with an error matching fs.ErrExist.
+func FileWithOverwrite(overwrite bool) FileOption {
+	return FileOption{func(fo *fileOptions) {
+		fo.keep = !overwrite
+	}}
+}
+
+// FileWithProgress returns a FileOption calling fn after each read with the
+// number of bytes transferred so far, and the size of the file, or -1 when
+// the server can not tell it.
+func FileWithProgress(fn func(transferred, total int64)) FileOption {
+	return FileOption{func(fo *fileOptions) {
+		fo.progress = fn
+	}}
+}
+
+// FileWithTransferOptions returns a FileOption applying options to the
+// transfer.
+func FileWithTransferOptions(options ...TransferOption) FileOption {
+	return FileOption{func(fo *fileOptions) {
+		fo.transferOptions = append(fo.transferOptions, options...)
+	}}
+}
+
+func newFileOptions(options []FileOption) *fileOptions {
+	fo := &fileOptions{}
+	for _, option := range options {
+		option.setup(fo)
+	}
+	return fo
+}
+
+// transfer returns the options of the transfer of a file of size bytes
+func (fo *fileOptions) transfer(size int64) []TransferOption {
+	if fo.progress == nil {
+		return fo.transferOptions
+	}
+	progress := TransferWithProgress(func(transferred int64) {
+		fo.progress(transferred, size)
+	}, 0)
+	return append(fo.transferOptions[:len(fo.transferOptions):len(fo.transferOptions)], progress)
+}
+
+// RetrToFile downloads the remote file to the local one.
+//
+// The data is first written to a temporary file of the local directory,
+// which is synced to the disk and only then renamed to local: a download
+// which fails or is interrupted never leaves a truncated file behind. The
+// directory is synced as well once the file is in place.
+//
+// The file gets the permissions of the file it replaces, or else the ones of
+// a new file: 0666 minus the umask.
+func (c *ServerConn) RetrToFile(remote, local string, options ...FileOption) (err error) {
+	fo := newFileOptions(options)
+
+	// Checked early not to download for nothing, and again when the file is
+	// put in place
+	if fo.keep {
+		if _, err := os.Lstat(local); err == nil {
+			return &fs.PathError{Op: "create", Path: local, Err: fs.ErrExist}
+		}
+	}
+
+	size := int64(-1)
+	if fo.progress != nil {
+		if _, ok := c.features["SIZE"]; ok {
+			if n, err := c.FileSize(remote); err == nil {
+				size = n
+			}
+		}
+	}
+
+	tmp, err := createPart(local)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if !fo.keep {
+		if info, err := os.Stat(local); err == nil && info.Mode().IsRegular() {
+			if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+
+	r, err := c.Retr(remote, fo.transfer(size)...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		return r.stop(err)
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if fo.modTime && c.mdtmSupported {
+		t, err := c.GetTime(remote)
+		if err != nil {
+			return err
+		}
+		if err := os.Chtimes(tmp.Name(), t, t); err != nil {
+			return err
+		}
+	}
+
+	if !fo.keep {
+		if err := os.Rename(tmp.Name(), local); err != nil {
+			return err
+		}
+		return syncDir(filepath.Dir(local))
+	}
+
+	// Unlike a rename, a link fails if the file was created meanwhile
+	if err := os.Link(tmp.Name(), local); err != nil {
+		return err
+	}
+	if err := os.Remove(tmp.Name()); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(local))
+}
+
+// createPart creates the temporary file of a download to local, in the same
+// directory. Unlike os.CreateTemp, which creates it with mode 0600, the
+// permissions are the ones of a new file.
+func createPart(local string) (*os.File, error) {
+	dir, base := filepath.Dir(local), filepath.Base(local)
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+".part")
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !errors.Is(err, fs.ErrExist) {
+			return f, err
+		}
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: local, Err: fs.ErrExist}
+}
+
+// syncDir syncs the directory dir to the disk, so that the files renamed in
+// it survive a crash. Directories can not be synced on Windows.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if errClose := d.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// StorFromFile uploads the local file to the remote one.
+//
+// The file is passed to Stor as an *os.File, so that its size is known: it
+// can be announced with TransferWithAllocation(-1), and sent with sendfile
+// when the platform allows it. Refusing to overwrite the remote file relies
+// on FileExists, so it can not be atomic.
+func (c *ServerConn) StorFromFile(local, remote string, options ...FileOption) error {
+	fo := newFileOptions(options)
+
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fo.keep {
+		exists, err := c.FileExists(remote)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return &fs.PathError{Op: "STOR", Path: remote, Err: fs.ErrExist}
+		}
+	}
+
+	if err := c.Stor(remote, f, fo.transfer(info.Size())...); err != nil {
+		return err
+	}
+
+	if fo.modTime && c.IsSetTimeSupported() {
+		return c.SetTime(remote, info.ModTime())
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package ftp
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrToFile(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT":         "211-Features:\r\n EPSV\r\n SIZE\r\n MDTM\r\n211 End",
+			"MDTM /remote": "213 20200102030405",
+		},
+		Files: map[string][]byte{
+			"/remote": []byte("hello"),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local")
+
+	var transferred, total int64
+	err := c.RetrToFile("/remote", local, FileWithModTime(true), FileWithProgress(func(n, size int64) {
+		transferred, total = n, size
+	}))
+	if assert.NoError(t, err) {
+		data, err := os.ReadFile(local)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+
+		info, err := os.Stat(local)
+		if assert.NoError(t, err) {
+			assert.Equal(t, time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC), info.ModTime().UTC())
+		}
+	}
+	assert.Equal(t, int64(5), transferred)
+	assert.Equal(t, int64(5), total)
+
+	// The existing file is kept
+	err = c.RetrToFile("/remote", local, FileWithOverwrite(false))
+	assert.True(t, errors.Is(err, fs.ErrExist))
+
+	// A failed download leaves nothing behind
+	err = c.RetrToFile("/missing", filepath.Join(dir, "missing"))
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	names, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestRetrToFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no UNIX permissions")
+	}
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{
+			"/remote": []byte("hello"),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local")
+
+	// The mode of a new file, with the umask applied
+	reference := filepath.Join(dir, "reference")
+	f, err := os.OpenFile(reference, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	expected, err := os.Stat(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.NoError(t, c.RetrToFile("/remote", local)) {
+		info, err := os.Stat(local)
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected.Mode(), info.Mode())
+		}
+	}
+
+	// The mode of a replaced file is kept
+	assert.NoError(t, os.Chmod(local, 0640))
+	if assert.NoError(t, c.RetrToFile("/remote", local)) {
+		info, err := os.Stat(local)
+		if assert.NoError(t, err) {
+			assert.Equal(t, os.FileMode(0640), info.Mode())
+		}
+	}
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestStorFromFile(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT": "211-Features:\r\n EPSV\r\n SIZE\r\n MFMT\r\n211 End",
+			"MFMT": "213 Modify=20200102030405; /remote",
+		},
+		Files: map[string][]byte{
+			"/existing": []byte("old"),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	local := filepath.Join(t.TempDir(), "local")
+	assert.NoError(t, os.WriteFile(local, []byte("hello"), 0o600))
+	modTime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(local, modTime, modTime))
+
+	assert.NoError(t, c.StorFromFile(local, "/remote", FileWithModTime(true)))
+	data, ok := s.File("/remote")
+	assert.True(t, ok)
+	assert.Equal(t, "hello", string(data))
+	assert.Contains(t, s.Commands(), "MFMT 20200102030405 /remote")
+
+	err := c.StorFromFile(local, "/existing", FileWithOverwrite(false))
+	assert.True(t, errors.Is(err, fs.ErrExist))
+	data, _ = s.File("/existing")
+	assert.Equal(t, "old", string(data))
+
+	assert.NoError(t, c.Quit())
+}
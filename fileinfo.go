@@ -0,0 +1,82 @@
+package ftp
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// FileInfo returns a description of the entry implementing fs.FileInfo.
+//
+// Entry can't implement the interface itself, as its Name and Size fields
+// would clash with the methods of fs.FileInfo.
+func (e *Entry) FileInfo() fs.FileInfo {
+	return entryInfo{e}
+}
+
+// DirEntry returns a description of the entry implementing fs.DirEntry.
+func (e *Entry) DirEntry() fs.DirEntry {
+	return entryInfo{e}
+}
+
+// mode returns the type bits of an fs.FileMode for the entry type.
+func (t EntryType) mode() fs.FileMode {
+	switch t {
+	case EntryTypeFolder:
+		return fs.ModeDir
+	case EntryTypeLink:
+		return fs.ModeSymlink
+	case EntryTypeDevice:
+		return fs.ModeDevice
+	case EntryTypePipe:
+		return fs.ModeNamedPipe
+	case EntryTypeSocket:
+		return fs.ModeSocket
+	}
+	return 0
+}
+
+// entryInfo implements fs.FileInfo and fs.DirEntry for an Entry.
+type entryInfo struct {
+	e *Entry
+}
+
+// Name returns the base name of the entry, as some servers list entries
+// with their full path.
+func (i entryInfo) Name() string {
+	name := strings.TrimRight(i.e.Name, "/")
+	if name == "" {
+		return i.e.Name
+	}
+	return path.Base(name)
+}
+
+func (i entryInfo) Size() int64 {
+	return int64(i.e.Size)
+}
+
+func (i entryInfo) Mode() fs.FileMode {
+	return i.e.Mode()
+}
+
+func (i entryInfo) ModTime() time.Time {
+	return i.e.Time
+}
+
+func (i entryInfo) IsDir() bool {
+	return i.e.Type == EntryTypeFolder
+}
+
+// Sys returns the underlying *Entry.
+func (i entryInfo) Sys() interface{} {
+	return i.e
+}
+
+func (i entryInfo) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+func (i entryInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
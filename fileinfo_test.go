@@ -0,0 +1,70 @@
+package ftp
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryFileInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	entry, err := parseListLine("drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub", now, time.UTC)
+	if !assert.NoError(err) {
+		return
+	}
+
+	info := entry.FileInfo()
+	assert.Equal("pub", info.Name())
+	assert.Equal(int64(0), info.Size())
+	assert.Equal(fs.ModeDir|0755, info.Mode())
+	assert.Equal(newTime(2009, time.December, 2), info.ModTime())
+	assert.True(info.IsDir())
+	assert.Same(entry, info.Sys())
+
+	dirEntry := entry.DirEntry()
+	assert.Equal("pub", dirEntry.Name())
+	assert.True(dirEntry.IsDir())
+	assert.Equal(fs.ModeDir, dirEntry.Type())
+	dirInfo, err := dirEntry.Info()
+	assert.NoError(err)
+	assert.Equal(info, dirInfo)
+}
+
+func TestEntryFileInfoName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"file.txt", "file.txt"},
+		{"/pub/file.txt", "file.txt"},
+		{"pub/incoming/", "incoming"},
+		{"/", "/"},
+	}
+
+	for _, test := range tests {
+		entry := &Entry{Name: test.name}
+		assert.Equal(t, test.expected, entry.FileInfo().Name())
+	}
+}
+
+func TestEntryFileInfoType(t *testing.T) {
+	tests := []struct {
+		entryType EntryType
+		mode      fs.FileMode
+	}{
+		{EntryTypeFile, 0},
+		{EntryTypeFolder, fs.ModeDir},
+		{EntryTypeLink, fs.ModeSymlink},
+		{EntryTypeDevice, fs.ModeDevice},
+		{EntryTypePipe, fs.ModeNamedPipe},
+		{EntryTypeSocket, fs.ModeSocket},
+	}
+
+	for _, test := range tests {
+		entry := &Entry{Type: test.entryType}
+		assert.Equal(t, test.mode, entry.DirEntry().Type(), test.entryType.String())
+	}
+}
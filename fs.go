@@ -0,0 +1,220 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+// FS provides access to the files of an FTP server through the io/fs
+// interfaces. It implements fs.FS, fs.ReadDirFS and fs.StatFS.
+//
+// Names are resolved against the working directory of the connection at the
+// time NewFS was called, so changing directory afterwards does not affect
+// the FS.
+//
+// As a ServerConn can only run one transfer at a time, a file opened for
+// reading holds the connection until it is closed: other operations of the
+// FS block meanwhile, so a goroutine must not use the FS while it has a file
+// open. Directories are listed when opened and do not hold the connection.
+type FS struct {
+	c    *ServerConn
+	root string
+	mu   sync.Mutex
+}
+
+// NewFS returns an FS over the connection, rooted at its current directory.
+func NewFS(c *ServerConn) (*FS, error) {
+	root, err := c.CurrentDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FS{c: c, root: root}, nil
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	f.mu.Lock()
+	entry, err := f.stat(name)
+	if err != nil {
+		f.mu.Unlock()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if entry.Type == EntryTypeFolder {
+		entries, err := f.readDir(name)
+		f.mu.Unlock()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &fsDir{name: name, entry: entry, entries: entries}, nil
+	}
+
+	r, err := f.c.Retr(f.path(name))
+	if err != nil {
+		f.mu.Unlock()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	// The lock is released when the file is closed
+	return &fsFile{fs: f, name: name, entry: entry, r: r}, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+// Stat returns a description of the named file or directory.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, err := f.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return entry.FileInfo(), nil
+}
+
+// path returns the path on the server of the named file.
+func (f *FS) path(name string) string {
+	return path.Join(f.root, name)
+}
+
+// stat looks for the named file in the listing of its parent directory.
+func (f *FS) stat(name string) (*Entry, error) {
+	if name == "." {
+		return &Entry{Name: path.Base(f.root), Type: EntryTypeFolder}, nil
+	}
+
+	entries, err := f.c.List(f.path(path.Dir(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	base := path.Base(name)
+	for _, entry := range entries {
+		if !entry.Pseudo && entry.FileInfo().Name() == base {
+			return entry, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// readDir lists the named directory.
+func (f *FS) readDir(name string) ([]fs.DirEntry, error) {
+	entries, err := f.c.List(f.path(name))
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Pseudo {
+			dirEntries = append(dirEntries, entry.DirEntry())
+		}
+	}
+
+	sort.Slice(dirEntries, func(i, j int) bool {
+		return dirEntries[i].Name() < dirEntries[j].Name()
+	})
+	return dirEntries, nil
+}
+
+// fsFile is a file of an FS being downloaded.
+type fsFile struct {
+	fs     *FS
+	name   string
+	entry  *Entry
+	r      *Response
+	closed bool
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.entry.FileInfo(), nil
+}
+
+func (f *fsFile) Read(buf []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	return f.r.Read(buf)
+}
+
+// Close ends the transfer, aborting it when the file was not read until the
+// end, see Response.Close, and releases the connection.
+func (f *fsFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	defer f.fs.mu.Unlock()
+
+	return f.r.Close()
+}
+
+// fsDir is a directory of an FS, listed when opened.
+type fsDir struct {
+	name    string
+	entry   *Entry
+	entries []fs.DirEntry
+	offset  int
+}
+
+var errIsDir = errors.New("is a directory")
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.entry.FileInfo(), nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries of the directory, or all the remaining
+// ones if n <= 0.
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
@@ -0,0 +1,80 @@
+package ftp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFS(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	err := c.Stor("lo", bytes.NewBufferString(testData))
+	assert.NoError(t, err)
+
+	fsys, err := NewFS(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var _ fs.ReadDirFS = fsys
+	var _ fs.StatFS = fsys
+
+	entries, err := fs.ReadDir(fsys, "unix")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "a.txt", entries[0].Name())
+		assert.Equal(t, "b.txt", entries[1].Name())
+		assert.Equal(t, "pub", entries[2].Name())
+		assert.True(t, entries[2].IsDir())
+	}
+
+	info, err := fs.Stat(fsys, "unix/b.txt")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1024), info.Size())
+		assert.False(t, info.IsDir())
+	}
+
+	_, err = fs.Stat(fsys, "unix/missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = fsys.Open("../etc/passwd")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	content, err := fs.ReadFile(fsys, "lo")
+	assert.NoError(t, err)
+	assert.Equal(t, testData, string(content))
+
+	dir, err := fsys.Open(".")
+	if assert.NoError(t, err) {
+		readDir := dir.(fs.ReadDirFile)
+		first, err := readDir.ReadDir(1)
+		assert.NoError(t, err)
+		if assert.Len(t, first, 1) {
+			assert.Equal(t, "lo", first[0].Name())
+		}
+		_, err = readDir.ReadDir(1)
+		assert.Equal(t, io.EOF, err)
+		assert.NoError(t, dir.Close())
+	}
+
+	// a file closed before being read completely releases the connection
+	file, err := fsys.Open("lo")
+	if assert.NoError(t, err) {
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(file, buf)
+		assert.NoError(t, err)
+		assert.NoError(t, file.Close())
+	}
+
+	content, err = fs.ReadFile(fsys, "lo")
+	assert.NoError(t, err)
+	assert.Equal(t, testData, string(content))
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
@@ -5,17 +5,26 @@ package ftp
 
 import (
 	"bufio"
+	"compress/zlib"
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/textproto"
+	"os"
+	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
 // EntryType describes the different types of an Entry.
@@ -26,28 +35,81 @@ const (
 	EntryTypeFile EntryType = iota
 	EntryTypeFolder
 	EntryTypeLink
+	EntryTypeDevice // character or block device
+	EntryTypePipe   // named pipe (FIFO)
+	EntryTypeSocket
 )
 
 // Time format used by the MDTM and MFMT commands
 const timeFormat = "20060102150405"
 
+// defaultQuitTimeout is how long Quit waits for the server by default
+const defaultQuitTimeout = 2 * time.Second
+
 // ServerConn represents the connection to a remote FTP server.
 // A single connection only supports one in-flight data connection.
-// It is not safe to be called concurrently.
+//
+// It is meant to be used by a single goroutine at a time. The connection is
+// busy while a command waits for its reply, and while a transfer is in
+// progress, i.e. from the transfer command until the Response is closed or
+// the upload completes. An operation started by another goroutine meanwhile
+// fails with ErrConcurrentUse rather than mixing up the replies, or waits
+// with DialWithSerialization.
 type ServerConn struct {
 	options *dialOptions
 	conn    *textproto.Conn // connection wrapper for text protocol
+	busy    chan struct{}   // holds a value while the connection is busy
+	closed  bool            // Quit was called
 	netConn net.Conn        // underlying network connection
 	host    string
+	welcome string // greeting of the server
+	system  string // cached reply to SYST
+
+	language string // of the replies, see Language
+
+	// Cached inventories of HELP and SITE HELP, nil until known
+	help     []string
+	siteHelp []string
+
+	// closedErr is set once the server closed the connection with a 421
+	// reply, see ErrServerClosed
+	closedErr error
 
 	// Server capabilities discovered at runtime
 	features      map[string]string
 	skipEPSV      bool
 	mlstSupported bool
+	mlstFacts     map[string]bool // facts of the MLST listings, in lower case
 	mfmtSupported bool
 	mdtmSupported bool
 	mdtmCanWrite  bool
 	usePRET       bool
+	modeZ         bool // data is compressed with MODE Z
+
+	currentType TransferType // type set with the last TYPE command
+
+	timezone         time.Duration // offset found by DetectTimezone
+	timezoneDetected bool
+
+	listParsers []parseFunc // parsers tried in turn for LIST lines
+
+	downloaded int64 // number of bytes retrieved by Retr
+
+	transferPending bool     // a Response of Retr is not closed yet
+	afterTransfer   bool     // the last reply completed a transfer
+	transfer        net.Conn // data connection of the transfer in progress
+
+	limiter *rateLimiter // throughput limit of the data connections
+	buffers *sync.Pool   // buffers of the transfers, see DialWithBufferSize
+
+	cwd      string   // working directory, empty when unknown
+	dirStack []string // directories pushed by PushDir
+
+	listCache listCache // listings of ListCached
+
+	lastUsed      time.Time     // end of the last exchange, while not busy
+	keepAliveStop chan struct{} // closed to stop the keepalive
+	keepAliveDone chan struct{} // closed once the keepalive stopped
 }
 
 // DialOption represents an option to start a new connection with Dial
@@ -57,38 +119,132 @@ type DialOption struct {
 
 // dialOptions contains all the options set by DialOption.setup
 type dialOptions struct {
-	context     context.Context
-	dialer      net.Dialer
-	tlsConfig   *tls.Config
-	explicitTLS bool
-	conn        net.Conn
-	disableEPSV bool
-	disableUTF8 bool
-	disableMLSD bool
-	writingMDTM bool
-	location    *time.Location
-	debugOutput io.Writer
-	dialFunc    func(network, address string) (net.Conn, error)
-	shutTimeout time.Duration // time to wait for data connection closing status
+	context          context.Context
+	dialer           net.Dialer
+	tlsConfig        *tls.Config
+	explicitTLS      bool
+	conn             net.Conn
+	disableEPSV      bool
+	forceEPSV        bool
+	activeMode       bool
+	activeIP         net.IP
+	allowForeignData bool
+	allowLowDataPort bool
+	retryPolicy      *RetryPolicy
+	hooks            *Hooks
+	serialize        bool
+	disableUTF8      bool
+	language         string
+	disableLANG      bool
+	disableMLSD      bool
+	forcePRET        bool
+	disableRawLines  bool
+	writingMDTM      bool
+	strictList       bool
+	maxDownloadBytes int64
+	dayFirst         bool
+	rateLimit        int64
+	modeZ            bool
+	modeZLevel       int
+	transferType     TransferType
+	encoding         encoding.Encoding
+	detectTimezone   bool
+	serverGlob       bool
+	location         *time.Location
+	clock            func() time.Time
+	debugOutput      io.Writer
+	dialFunc         func(network, address string) (net.Conn, error)
+	shutTimeout      time.Duration // time to wait for data connection closing status
+	quitTimeout      time.Duration // time to wait for the reply to QUIT
+	bufferSize       int
+	keepAlive        time.Duration // idle time before a NOOP is sent
+
+	// lookup resolves the name of the server, instead of the resolver of
+	// the dialer
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
 }
 
 // Entry describes a file and is returned by List().
+//
+// The fields the listing does not provide keep their zero value, which can
+// not be told from an actual one. With MLST and MLSD, they depend on the
+// facts sent by the server, see MLSTFacts: without the type fact, every
+// entry has the EntryTypeFile type, and without the size fact, every Size
+// is 0.
 type Entry struct {
 	Name   string
 	Target string // target of symbolic link
 	Type   EntryType
 	Size   uint64
 	Time   time.Time
+	Owner  string // owner name or numeric id, empty if not provided
+	Group  string // group name or numeric id, empty if not provided
+
+	// TimeResolution is the precision of Time in the listing, e.g.
+	// time.Second for MLSD, time.Minute for the ls lines with a time of day,
+	// and 24 hours for the ones with a year instead. It is zero when the
+	// listing had no time.
+	TimeResolution time.Duration
+
+	// Pseudo is set on the entries standing for the listed directory itself
+	// and for its parent: "." and "..", or the cdir and pdir MLSD types.
+	Pseudo bool
+
+	// Facts from RFC 3659 listings
+	CreateTime time.Time         // from the create fact
+	Perm       string            // from the perm fact, e.g. "adfr"
+	Unique     string            // from the unique fact, identifies the file on the server
+	Facts      map[string]string // facts which are not decoded into other fields
+
+	// Major and Minor are the device numbers of EntryTypeDevice entries
+	Major uint32
+	Minor uint32
+
+	// Permissions is the raw permission field of UNIX listings,
+	// e.g. "-rwxr-xr-x". See Mode for its decoded form.
+	Permissions string
+
+	// Raw is the listing line the entry was parsed from, unless disabled
+	// with DialWithDisabledRawLines.
+	Raw string
+
+	unixMode    os.FileMode // from the UNIX.mode MLSD fact
+	hasUnixMode bool
 }
 
+// ErrTransferPending is returned by Cmd when the reply to a data transfer is
+// still expected, before the Response of Retr is closed.
+var ErrTransferPending = errors.New("data transfer in progress")
+
 // Response represents a data-connection
 type Response struct {
+	read int64 // accessed atomically, first for 64-bit alignment
+
 	conn   net.Conn
 	c      *ServerConn
 	closed bool
+
+	download bool  // counts against the connection download limit
+	maxBytes int64 // per-transfer limit, 0 means unlimited
+	err      error // sticky error once a limit was exceeded
+	readErr  error // last error of the data connection, io.EOF at the end
+
+	progress *progress
+
+	path      string         // of the download, for the hooks
+	start     time.Time      // of the download
+	firstByte time.Duration  // from start to the first byte received
+	duration  time.Duration  // of the download, once closed
+	stats     *TransferStats // filled on Close, see TransferWithStats
 }
 
-// Dial connects to the specified address with optional options
+// Dial connects to the specified address with optional options.
+//
+// When the name of the server resolves to several addresses, they are tried
+// in turn, the next one starting 250ms after the previous one unless it
+// failed before, until one of them connects: the timeout of DialWithTimeout
+// is the budget of the whole dial. The error on failure is the one of each
+// address. The data connections then use the address which connected.
 func Dial(addr string, options ...DialOption) (*ServerConn, error) {
 	do := &dialOptions{}
 	for _, option := range options {
@@ -105,16 +261,8 @@ func Dial(addr string, options ...DialOption) (*ServerConn, error) {
 
 		if do.dialFunc != nil {
 			tconn, err = do.dialFunc("tcp", addr)
-		} else if do.tlsConfig != nil && !do.explicitTLS {
-			tconn, err = tls.DialWithDialer(&do.dialer, "tcp", addr, do.tlsConfig)
 		} else {
-			ctx := do.context
-
-			if ctx == nil {
-				ctx = context.Background()
-			}
-
-			tconn, err = do.dialer.DialContext(ctx, "tcp", addr)
+			tconn, err = do.dialControl(addr)
 		}
 
 		if err != nil {
@@ -127,18 +275,28 @@ func Dial(addr string, options ...DialOption) (*ServerConn, error) {
 	remoteAddr := tconn.RemoteAddr().(*net.TCPAddr)
 
 	c := &ServerConn{
-		options:  do,
-		features: make(map[string]string),
-		conn:     textproto.NewConn(do.wrapConn(tconn)),
-		netConn:  tconn,
-		host:     remoteAddr.IP.String(),
+		options:     do,
+		features:    make(map[string]string),
+		conn:        textproto.NewConn(do.wrapConn(tconn)),
+		busy:        make(chan struct{}, 1),
+		netConn:     tconn,
+		host:        remoteAddr.IP.String(),
+		listParsers: listLineParsers,
+		limiter:     newRateLimiter(do.rateLimit),
+		buffers:     bufferPool(do.bufferSize),
+		lastUsed:    time.Now(),
+	}
+
+	if do.dayFirst {
+		c.listParsers = newListLineParsers(true, "")
 	}
 
-	_, _, err := c.conn.ReadResponse(StatusReady)
+	_, welcome, err := c.conn.ReadResponse(StatusReady)
 	if err != nil {
 		_ = c.Quit()
 		return nil, err
 	}
+	c.welcome = welcome
 
 	if do.explicitTLS {
 		if err := c.authTLS(); err != nil {
@@ -149,6 +307,12 @@ func Dial(addr string, options ...DialOption) (*ServerConn, error) {
 		c.conn = textproto.NewConn(do.wrapConn(tconn))
 	}
 
+	if do.hooks != nil && do.hooks.OnConnect != nil {
+		do.hooks.OnConnect(tconn.RemoteAddr().String())
+	}
+	if do.keepAlive > 0 {
+		c.startKeepAlive(do.keepAlive)
+	}
 	return c, nil
 }
 
@@ -159,6 +323,14 @@ func DialWithTimeout(timeout time.Duration) DialOption {
 	}}
 }
 
+// DialWithQuitTimeout returns a DialOption that sets how long Quit waits for
+// the server before closing the connection, 2 seconds by default.
+func DialWithQuitTimeout(timeout time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.quitTimeout = timeout
+	}}
+}
+
 // DialWithShutTimeout returns a DialOption that configures the ServerConn with
 // maximum time to wait for the data closing status on control connection
 // and nudging the control connection deadline before reading status.
@@ -190,6 +362,36 @@ func DialWithDisabledEPSV(disabled bool) DialOption {
 	}}
 }
 
+// DialWithForcedEPSV returns a DialOption that makes the data connections
+// only use EPSV, which is needed over IPv6. By default, the connection
+// switches to PASV for good the first time EPSV fails.
+func DialWithForcedEPSV(forced bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.forceEPSV = forced
+	}}
+}
+
+// DialWithActiveMode returns a DialOption that makes the server connect to
+// the client for the data connections, which is known as active mode. The
+// client listens on the address of the control connection, announced to the
+// server with PORT, or EPRT over IPv6.
+func DialWithActiveMode(enabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.activeMode = enabled
+	}}
+}
+
+// DialWithActiveIP returns a DialOption that makes active mode listen on ip,
+// and announce it to the server, instead of the local address of the control
+// connection. It is needed when the client has several interfaces and the
+// server can not reach the one of the control connection. It has no effect
+// without DialWithActiveMode.
+func DialWithActiveIP(ip net.IP) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.activeIP = ip
+	}}
+}
+
 // DialWithDisabledUTF8 returns a DialOption that configures the ServerConn with UTF8 option disabled
 func DialWithDisabledUTF8(disabled bool) DialOption {
 	return DialOption{func(do *dialOptions) {
@@ -197,6 +399,16 @@ func DialWithDisabledUTF8(disabled bool) DialOption {
 	}}
 }
 
+// DialWithDisabledRawLines returns a DialOption that configures the ServerConn
+// to not keep the listing lines in Entry.Raw and DataSetEntry.Raw
+//
+// This saves memory when listing huge directories.
+func DialWithDisabledRawLines(disabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.disableRawLines = disabled
+	}}
+}
+
 // DialWithDisabledMLSD returns a DialOption that configures the ServerConn with MLSD option disabled
 //
 // This is useful for servers which advertise MLSD (eg some versions
@@ -207,6 +419,49 @@ func DialWithDisabledMLSD(disabled bool) DialOption {
 	}}
 }
 
+// DialWithPRET returns a DialOption that sends a PRET command before the
+// data transfers even if the server does not advertise it. PRET tells
+// distributed servers, e.g. DrFTPD, which node must handle the data
+// connection. It is sent by default when the server advertises it.
+func DialWithPRET(enabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.forcePRET = enabled
+	}}
+}
+
+// DialWithStrictList returns a DialOption that makes List fail when the server
+// sends a listing line which none of the parsers understand.
+//
+// By default such lines are skipped. ListFiltered can be used to retrieve
+// them along with the entries which were parsed successfully.
+func DialWithStrictList(strict bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.strictList = strict
+	}}
+}
+
+// DialWithMaxDownloadBytes returns a DialOption limiting the cumulative number
+// of bytes all downloads of the ServerConn may retrieve. This is useful on
+// metered links. A download crossing the limit is aborted and fails with a
+// *MaxSizeError. Zero means unlimited.
+func DialWithMaxDownloadBytes(n int64) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.maxDownloadBytes = n
+	}}
+}
+
+// DialWithDayFirstDates returns a DialOption declaring that the server puts
+// the day before the month in the numeric dates of its listings, as with
+// DIR listings like "25-12-2021  15:04" produced with some regional settings.
+//
+// Without it, such dates are read month first, falling back to day first
+// only when they would be invalid otherwise.
+func DialWithDayFirstDates(enabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.dayFirst = enabled
+	}}
+}
+
 // DialWithWritingMDTM returns a DialOption making ServerConn use MDTM to set file time
 //
 // This option addresses a quirk in the VsFtpd server which doesn't support
@@ -227,6 +482,16 @@ func DialWithLocation(location *time.Location) DialOption {
 	}}
 }
 
+// DialWithClock returns a DialOption that makes the ServerConn call now
+// instead of time.Now for the reference time of the listings: the year of
+// the ls lines which only have a month, a day and a time of day is inferred
+// from it. This makes the parsed listings reproducible, e.g. in tests.
+func DialWithClock(now func() time.Time) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.clock = now
+	}}
+}
+
 // DialWithContext returns a DialOption that configures the ServerConn with specified context
 // The context will be used for the initial connection setup
 func DialWithContext(ctx context.Context) DialOption {
@@ -276,19 +541,26 @@ func DialWithDialFunc(f func(network, address string) (net.Conn, error)) DialOpt
 }
 
 func (o *dialOptions) wrapConn(netConn net.Conn) io.ReadWriteCloser {
-	if o.debugOutput == nil {
-		return netConn
+	var conn io.ReadWriteCloser = netConn
+	if o.debugOutput != nil {
+		conn = newDebugWrapper(conn, o.debugOutput)
+	}
+	if o.encoding != nil {
+		conn = &decodingConn{ReadWriteCloser: conn, r: o.encoding.NewDecoder().Reader(conn)}
 	}
 
-	return newDebugWrapper(netConn, o.debugOutput)
+	return conn
 }
 
 func (o *dialOptions) wrapStream(rd io.ReadCloser) io.ReadCloser {
-	if o.debugOutput == nil {
-		return rd
+	if o.debugOutput != nil {
+		rd = newStreamDebugWrapper(rd, o.debugOutput)
+	}
+	if o.encoding != nil {
+		rd = &decodingStream{Reader: o.encoding.NewDecoder().Reader(rd), Closer: rd}
 	}
 
-	return newStreamDebugWrapper(rd, o.debugOutput)
+	return rd
 }
 
 // Connect is an alias to Dial, for backward compatibility
@@ -307,8 +579,20 @@ func DialTimeout(addr string, timeout time.Duration) (*ServerConn, error) {
 // Login authenticates the client with specified user and password.
 //
 // "anonymous"/"anonymous" is a common user/password scheme for FTP servers
-// that allows anonymous read-only accounts.
+// that allows anonymous read-only accounts, see AnonymousLogin.
 func (c *ServerConn) Login(user, password string) error {
+	return c.LoginWithAccount(user, password, "")
+}
+
+// AnonymousLogin authenticates the client as the "anonymous" user, with
+// "anonymous@" as password.
+func (c *ServerConn) AnonymousLogin() error {
+	return c.Login("anonymous", "anonymous@")
+}
+
+// LoginWithAccount authenticates the client like Login, and sends account
+// with an ACCT command to the servers which require it after the password.
+func (c *ServerConn) LoginWithAccount(user, password, account string) error {
 	code, message, err := c.cmd(-1, "USER %s", user)
 	if err != nil {
 		return err
@@ -317,23 +601,43 @@ func (c *ServerConn) Login(user, password string) error {
 	switch code {
 	case StatusLoggedIn:
 	case StatusUserOK:
-		_, _, err = c.cmd(StatusLoggedIn, "PASS %s", password)
+		code, message, err = c.cmd(-1, "PASS %s", password)
 		if err != nil {
 			return err
 		}
+		if code == StatusLoginNeedAccount {
+			if account == "" {
+				return errors.New("the server requires an account: " + message)
+			}
+			code, message, err = c.cmd(-1, "ACCT %s", account)
+			if err != nil {
+				return err
+			}
+		}
+		if code != StatusLoggedIn {
+			return &textproto.Error{Code: code, Msg: message}
+		}
 	default:
 		return errors.New(message)
 	}
+	c.skipLoggedIn()
 
 	// Probe features
 	err = c.feat()
 	if err != nil {
 		return err
 	}
+	if err := c.setLanguage(); err != nil {
+		return err
+	}
 	if _, mlstSupported := c.features["MLST"]; mlstSupported && !c.options.disableMLSD {
 		c.mlstSupported = true
+		if err := c.negotiateFacts(); err != nil {
+			return err
+		}
 	}
 	_, c.usePRET = c.features["PRET"]
+	c.usePRET = c.usePRET || c.options.forcePRET
 
 	_, c.mfmtSupported = c.features["MFMT"]
 	_, c.mdtmSupported = c.features["MDTM"]
@@ -345,7 +649,7 @@ func (c *ServerConn) Login(user, password string) error {
 	}
 
 	// Switch to UTF-8
-	if !c.options.disableUTF8 {
+	if !c.options.disableUTF8 && c.options.encoding == nil {
 		err = c.setUTF8()
 	}
 
@@ -359,9 +663,34 @@ func (c *ServerConn) Login(user, password string) error {
 		}
 	}
 
+	if err == nil && c.options.modeZ {
+		err = c.setModeZ()
+	}
+
+	if err == nil && c.options.detectTimezone && c.mdtmSupported {
+		// Failures keep the configured location. Nothing is uploaded
+		// without the consent of the caller.
+		_, _ = c.detectTimezone("", false)
+	}
+
 	return err
 }
 
+// skipLoggedIn reads the extra 230 replies some servers send after the first
+// one, as separate replies rather than a multiline one, which would
+// otherwise be taken as the replies of the next commands.
+func (c *ServerConn) skipLoggedIn() {
+	for c.conn.R.Buffered() >= 4 {
+		prefix, err := c.conn.R.Peek(4)
+		if err != nil || (string(prefix) != "230 " && string(prefix) != "230-") {
+			return
+		}
+		if _, _, err := c.conn.ReadResponse(StatusLoggedIn); err != nil {
+			return
+		}
+	}
+}
+
 // authTLS upgrades the connection to use TLS
 func (c *ServerConn) authTLS() error {
 	_, _, err := c.cmd(StatusAuthOK, "AUTH TLS")
@@ -405,6 +734,46 @@ func (c *ServerConn) feat() error {
 	return nil
 }
 
+// Welcome returns the greeting sent by the server when connecting, without
+// the status code. Its lines are separated by newlines.
+func (c *ServerConn) Welcome() string {
+	return c.welcome
+}
+
+// LocalAddr returns the local address of the control connection, i.e. the
+// interface and port it is bound to.
+func (c *ServerConn) LocalAddr() net.Addr {
+	return c.netConn.LocalAddr()
+}
+
+// RemoteAddr returns the address of the server the control connection is
+// connected to, which was resolved when the name of the server has several
+// addresses.
+func (c *ServerConn) RemoteAddr() net.Addr {
+	return c.netConn.RemoteAddr()
+}
+
+// System issues a SYST FTP command to identify the operating system of the
+// server, e.g. "UNIX Type: L8" or "Windows_NT". The result is cached, so that
+// the command is only sent once.
+//
+// Once it is known, the listings are parsed trying first the format that the
+// system most likely uses.
+func (c *ServerConn) System() (string, error) {
+	if c.system != "" {
+		return c.system, nil
+	}
+
+	_, msg, err := c.cmd(StatusName, "SYST")
+	if err != nil {
+		return "", err
+	}
+
+	c.system = msg
+	c.listParsers = newListLineParsers(c.options.dayFirst, msg)
+	return msg, nil
+}
+
 // setUTF8 issues an "OPTS UTF8 ON" command.
 func (c *ServerConn) setUTF8() error {
 	if _, ok := c.features["UTF8"]; !ok {
@@ -437,82 +806,177 @@ func (c *ServerConn) setUTF8() error {
 
 // epsv issues an "EPSV" command to get a port number for a data connection.
 func (c *ServerConn) epsv() (port int, err error) {
-	_, line, err := c.cmd(StatusExtendedPassiveMode, "EPSV")
+	_, line, err := c.exchange(StatusExtendedPassiveMode, "EPSV")
 	if err != nil {
 		return 0, err
 	}
+	return parseEPSVReply(line)
+}
 
-	start := strings.Index(line, "|||")
-	end := strings.LastIndex(line, "|")
-	if start == -1 || end == -1 {
+// parseEPSVReply returns the port of the reply to EPSV, e.g. "Entering
+// Extended Passive Mode (|||6446|)". Any delimiter is accepted instead of
+// '|', as RFC 2428 allows, as well as spaces around the port.
+func parseEPSVReply(line string) (int, error) {
+	start := strings.Index(line, "(")
+	end := strings.LastIndex(line, ")")
+	if start == -1 || end < start {
 		return 0, errors.New("invalid EPSV response format")
 	}
-	port, err = strconv.Atoi(line[start+3 : end])
-	return port, err
+
+	fields := strings.TrimSpace(line[start+1 : end])
+	if len(fields) < 5 {
+		return 0, errors.New("invalid EPSV response format")
+	}
+	d := fields[0]
+	if fields[1] != d || fields[2] != d || fields[len(fields)-1] != d {
+		return 0, errors.New("invalid EPSV response format")
+	}
+
+	port, err := strconv.Atoi(strings.TrimSpace(fields[3 : len(fields)-1]))
+	if err != nil {
+		return 0, err
+	}
+	if port < 1 || port > 65535 {
+		return 0, errors.New("invalid EPSV port " + strconv.Itoa(port))
+	}
+	return port, nil
 }
 
 // pasv issues a "PASV" command to get a port number for a data connection.
 func (c *ServerConn) pasv() (host string, port int, err error) {
-	_, line, err := c.cmd(StatusPassiveMode, "PASV")
+	return c.passive("PASV")
+}
+
+// passive issues a PASV FTP command, or the CPSV variant, and returns the
+// address announced by the server.
+func (c *ServerConn) passive(cmd string) (host string, port int, err error) {
+	_, line, err := c.exchange(StatusPassiveMode, cmd)
 	if err != nil {
 		return "", 0, err
 	}
 
-	// PASV response format : 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2).
-	start := strings.Index(line, "(")
-	end := strings.LastIndex(line, ")")
-	if start == -1 || end == -1 {
-		return "", 0, errors.New("invalid PASV response format")
-	}
-
-	// We have to split the response string
-	pasvData := strings.Split(line[start+1:end], ",")
+	return parsePASVReply(line)
+}
 
-	if len(pasvData) < 6 {
-		return "", 0, errors.New("invalid PASV response format")
+// parsePASVReply returns the address of the reply to PASV, e.g. "Entering
+// Passive Mode (h1,h2,h3,h4,p1,p2)". The six numbers are looked for
+// anywhere in the reply, as some servers omit the parentheses, add spaces
+// after the commas, or send them on a continuation line.
+func parsePASVReply(line string) (host string, port int, err error) {
+	for i := 0; i < len(line); i++ {
+		if !isDigit(line[i]) || (i > 0 && isDigit(line[i-1])) {
+			continue
+		}
+		fields, ok := scanPASVFields(line[i:])
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			if field > 255 {
+				return "", 0, fmt.Errorf("invalid PASV reply %q: %d out of range", line, field)
+			}
+		}
+		port = fields[4]<<8 | fields[5]
+		if port == 0 {
+			return "", 0, fmt.Errorf("invalid PASV reply %q: port 0", line)
+		}
+		host = fmt.Sprintf("%d.%d.%d.%d", fields[0], fields[1], fields[2], fields[3])
+		return host, port, nil
 	}
+	return "", 0, fmt.Errorf("invalid PASV reply %q", line)
+}
 
-	// Let's compute the port number
-	portPart1, err := strconv.Atoi(pasvData[4])
-	if err != nil {
-		return "", 0, err
-	}
+// scanPASVFields reads six comma separated numbers at the start of s,
+// allowing spaces around the commas.
+func scanPASVFields(s string) (fields [6]int, ok bool) {
+	for n := range fields {
+		if n > 0 {
+			s = strings.TrimLeft(s, " ")
+			if s == "" || s[0] != ',' {
+				return fields, false
+			}
+			s = strings.TrimLeft(s[1:], " ")
+		}
 
-	portPart2, err := strconv.Atoi(pasvData[5])
-	if err != nil {
-		return "", 0, err
+		i := 0
+		for ; i < len(s) && isDigit(s[i]); i++ {
+			// Stop growing once out of range, not to overflow
+			if fields[n] <= 255 {
+				fields[n] = fields[n]*10 + int(s[i]-'0')
+			}
+		}
+		if i == 0 {
+			return fields, false
+		}
+		s = s[i:]
 	}
+	return fields, true
+}
 
-	// Recompose port
-	port = portPart1*256 + portPart2
-
-	// Make the IP address to connect to
-	host = strings.Join(pasvData[0:4], ".")
-	return host, port, nil
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
 }
 
 // getDataConnPort returns a host, port for a new data connection
-// it uses the best available method to do so
-func (c *ServerConn) getDataConnPort() (string, int, error) {
+// it uses the best available method to do so, and tells whether it is EPSV.
+func (c *ServerConn) getDataConnPort() (string, int, bool, error) {
 	if !c.options.disableEPSV && !c.skipEPSV {
-		if port, err := c.epsv(); err == nil {
-			return c.host, port, nil
+		port, err := c.epsv()
+		if err == nil {
+			return c.host, port, true, nil
+		}
+		if c.options.forceEPSV {
+			return "", 0, false, err
 		}
 
 		// if there is an error, skip EPSV for the next attempts
 		c.skipEPSV = true
 	}
 
-	return c.pasv()
+	host, port, err := c.pasv()
+	return host, port, false, err
 }
 
-// openDataConn creates a new FTP data connection.
+// openDataConn creates a new FTP data connection. In active mode, the
+// connection is only made by the server once the transfer command is sent,
+// see activeDataConn.
 func (c *ServerConn) openDataConn() (net.Conn, error) {
-	host, port, err := c.getDataConnPort()
+	if c.options.activeMode {
+		return c.listenActive()
+	}
+	return c.dialDataConn()
+}
+
+// dialDataConn connects to the data port announced by the server, once
+// checked by dataAddr. When the port announced by EPSV can not be reached,
+// e.g. because of a firewall, EPSV is skipped for this attempt and the next
+// ones.
+func (c *ServerConn) dialDataConn() (net.Conn, error) {
+	host, port, epsv, err := c.getDataConnPort()
 	if err != nil {
 		return nil, err
 	}
 
+	if host, err = c.dataAddr(host, port); err != nil {
+		return nil, err
+	}
+
+	conn, err := c.dialDataAddr(host, port)
+	if err != nil && epsv && !c.options.forceEPSV {
+		c.skipEPSV = true
+		if host, port, err = c.pasv(); err != nil {
+			return nil, err
+		}
+		if host, err = c.dataAddr(host, port); err != nil {
+			return nil, err
+		}
+		return c.dialDataAddr(host, port)
+	}
+	return conn, err
+}
+
+// dialDataAddr connects to a data port
+func (c *ServerConn) dialDataAddr(host string, port int) (net.Conn, error) {
 	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	if c.options.dialFunc != nil {
 		return c.options.dialFunc("tcp", addr)
@@ -528,46 +992,110 @@ func (c *ServerConn) openDataConn() (net.Conn, error) {
 // cmd is a helper function to execute a command and check for the expected FTP
 // return code
 func (c *ServerConn) cmd(expected int, format string, args ...interface{}) (int, string, error) {
-	_, err := c.conn.Cmd(format, args...)
+	if err := c.acquire(); err != nil {
+		return 0, "", err
+	}
+	defer c.release()
+
+	return c.exchange(expected, format, args...)
+}
+
+// exchange is like cmd, for the callers which already made the connection
+// busy.
+func (c *ServerConn) exchange(expected int, format string, args ...interface{}) (int, string, error) {
+	if c.options.hooks != nil {
+		start := time.Now()
+		code, msg, err := c.readCmd(expected, format, args...)
+		c.options.hooks.onCommand(start, code, format, args...)
+		return code, msg, err
+	}
+	return c.readCmd(expected, format, args...)
+}
+
+// readCmd sends a command and reads its reply
+func (c *ServerConn) readCmd(expected int, format string, args ...interface{}) (int, string, error) {
+	afterTransfer := c.afterTransfer
+	c.afterTransfer = false
+
+	err := c.sendCmd(format, args...)
 	if err != nil {
 		return 0, "", err
 	}
 
-	return c.conn.ReadResponse(expected)
+	code, msg, err := c.readResponse(expected)
+	// Some servers, e.g. wu-ftpd, send the completion reply of a transfer
+	// twice: the second one is not the reply to the command, unless it is
+	// ABOR
+	if afterTransfer && code == StatusClosingDataConnection && !strings.HasPrefix(format, "ABOR") {
+		return c.readResponse(expected)
+	}
+	return code, msg, err
+}
+
+// Cmd sends a command which is not otherwise supported by the package, e.g.
+// "SITE HELP", and returns the code and the lines of the reply of the
+// server. A multiline reply is returned as several lines, without their
+// code. The reply must have the code expectCode, with the meaning of
+// textproto.Reader.ReadResponse: a single digit only checks the class of the
+// reply, e.g. 2 for success, and -1 accepts any reply.
+//
+// Cmd must not start a data transfer, and returns ErrTransferPending while
+// the Response of Retr or RetrFrom is not closed.
+func (c *ServerConn) Cmd(expectCode int, format string, args ...interface{}) (code int, lines []string, err error) {
+	if c.transferPending {
+		return 0, nil, ErrTransferPending
+	}
+
+	// The command may change the working directory
+	c.cwd = ""
+
+	code, msg, err := c.cmd(expectCode, format, args...)
+	if msg != "" {
+		lines = strings.Split(msg, "\n")
+	}
+	return code, lines, err
 }
 
 // cmdDataConnFrom executes a command which require a FTP data connection.
 // Issues a REST FTP command to specify the number of bytes to skip for the transfer.
-func (c *ServerConn) cmdDataConnFrom(offset uint64, format string, args ...interface{}) (net.Conn, error) {
+func (c *ServerConn) cmdDataConnFrom(offset uint64, format string, args ...interface{}) (conn net.Conn, err error) {
+	if err := c.acquire(); err != nil {
+		return nil, err
+	}
+	// The connection stays busy until the end of the transfer on success
+	defer func() {
+		if err != nil {
+			c.release()
+		}
+	}()
+
 	// If server requires PRET send the PRET command to warm it up
 	// See: https://tools.ietf.org/html/draft-dd-pret-00
 	if c.usePRET {
-		_, _, err := c.cmd(-1, "PRET "+format, args...)
+		code, _, err := c.exchange(-1, "PRET "+format, args...)
 		if err != nil {
 			return nil, err
 		}
+		// A server refusing PRET does not need it
+		if code >= 500 {
+			c.usePRET = false
+		}
 	}
 
-	conn, err := c.openDataConn()
+	conn, err = c.openDataConn()
 	if err != nil {
 		return nil, err
 	}
 
 	if offset != 0 {
-		_, _, err = c.cmd(StatusRequestFilePending, "REST %d", offset)
+		_, _, err = c.exchange(StatusRequestFilePending, "REST %d", offset)
 		if err != nil {
 			_ = conn.Close()
 			return nil, err
 		}
 	}
 
-	_, err = c.conn.Cmd(format, args...)
-	if err != nil {
-		_ = conn.Close()
-		return nil, err
-	}
-
-	code, msg, err := c.conn.ReadResponse(-1)
+	code, msg, err := c.exchange(-1, format, args...)
 	if err != nil {
 		_ = conn.Close()
 		return nil, err
@@ -577,41 +1105,114 @@ func (c *ServerConn) cmdDataConnFrom(offset uint64, format string, args ...inter
 		return nil, &textproto.Error{Code: code, Msg: msg}
 	}
 
+	if active, ok := conn.(*activeDataConn); ok {
+		if err := active.accept(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	conn = &throttledConn{Conn: conn, limiter: c.limiter, buffers: c.buffers}
+	c.transfer = conn
+
+	if c.modeZ {
+		return &inflateConn{Conn: conn}, nil
+	}
 	return conn, nil
 }
 
 // NameList issues an NLST FTP command.
 func (c *ServerConn) NameList(path string) (entries []string, err error) {
+	err = c.NameListEach(path, func(entry string) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// NameListEach issues an NLST FTP command like NameList, but calls fn for
+// each name as soon as it is received, in the order of the server.
+//
+// If fn returns an error, the transfer is aborted and the error is returned.
+func (c *ServerConn) NameListEach(path string, fn func(string) error) error {
 	space := " "
 	if path == "" {
 		space = ""
 	}
 	conn, err := c.cmdDataConnFrom(0, "NLST%s%s", space, path)
 	if err != nil {
-		return nil, err
+		return pathError("NLST", path, err)
 	}
 
-	var errs *multierror.Error
-
 	r := &Response{conn: conn, c: c}
 
 	scanner := bufio.NewScanner(c.options.wrapStream(r))
 	for scanner.Scan() {
-		entries = append(entries, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		errs = multierror.Append(errs, err)
-	}
-	if err := r.Close(); err != nil {
-		errs = multierror.Append(errs, err)
+		if err := fn(scanner.Text()); err != nil {
+			return r.stop(err)
+		}
 	}
 
-	return entries, errs.ErrorOrNil()
+	return r.closeScanned(scanner)
 }
 
 // List issues a LIST FTP command.
+//
+// Lines which can not be parsed are skipped, unless the connection was
+// established with DialWithStrictList, in which case the first one is
+// returned as a *ListLineError.
 func (c *ServerConn) List(path string) (entries []*Entry, err error) {
+	var skipped []*ListLineError
+	err = c.retry(func() error {
+		entries, skipped, err = c.list(path)
+		return err
+	})
+	if err == nil && c.options.strictList && len(skipped) > 0 {
+		return entries, skipped[0]
+	}
+	return entries, err
+}
+
+// ListFiltered issues a LIST FTP command like List, but never fails because
+// of lines which can not be parsed. Such lines are returned in skipped,
+// regardless of the DialWithStrictList option.
+func (c *ServerConn) ListFiltered(path string) (entries []*Entry, skipped []*ListLineError, err error) {
+	return c.list(path)
+}
+
+// ListEach issues a LIST FTP command like List, but calls fn for each entry
+// as soon as it is received, in the order of the server, instead of keeping
+// the whole listing in memory.
+//
+// If fn returns an error, the transfer is aborted and the error is returned.
+// Lines which can not be parsed are skipped, unless the connection was
+// established with DialWithStrictList, in which case the transfer is
+// aborted at the first one, returned as a *ListLineError.
+func (c *ServerConn) ListEach(path string, fn func(*Entry) error) error {
+	return c.listEach(path, fn, func(skipped *ListLineError) error {
+		if c.options.strictList {
+			return skipped
+		}
+		return nil
+	})
+}
+
+// list retrieves and parses a directory listing, collecting the lines which
+// could not be parsed.
+func (c *ServerConn) list(path string) (entries []*Entry, skipped []*ListLineError, err error) {
+	err = c.listEach(path, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}, func(line *ListLineError) error {
+		skipped = append(skipped, line)
+		return nil
+	})
+	return entries, skipped, err
+}
+
+// listEach retrieves and parses a directory listing, calling fn for each
+// entry and skip for each line which could not be parsed. The transfer is
+// aborted if one of them returns an error.
+func (c *ServerConn) listEach(path string, fn func(*Entry) error, skip func(*ListLineError) error) error {
 	var cmd string
 	var parser parseFunc
 
@@ -620,7 +1221,7 @@ func (c *ServerConn) List(path string) (entries []*Entry, err error) {
 		parser = parseRFC3659ListLine
 	} else {
 		cmd = "LIST"
-		parser = parseListLine
+		parser = c.parseListLine
 	}
 
 	space := " "
@@ -629,42 +1230,193 @@ func (c *ServerConn) List(path string) (entries []*Entry, err error) {
 	}
 	conn, err := c.cmdDataConnFrom(0, "%s%s%s", cmd, space, path)
 	if err != nil {
-		return nil, err
+		return pathError(cmd, path, err)
 	}
 
-	var errs *multierror.Error
-
 	r := &Response{conn: conn, c: c}
 
 	scanner := bufio.NewScanner(c.options.wrapStream(r))
-	now := time.Now()
+	if err := c.parseLinesEach(scanner, parser, fn, skip); err != nil {
+		return r.stop(err)
+	}
+
+	return r.closeScanned(scanner)
+}
+
+// parseLines parses the lines of a listing, joining the lines of entries
+// which span several lines. The lines which can't be parsed are returned
+// apart.
+func (c *ServerConn) parseLines(scanner *bufio.Scanner, parser parseFunc) (entries []*Entry, skipped []*ListLineError) {
+	_ = c.parseLinesEach(scanner, parser, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}, func(line *ListLineError) error {
+		skipped = append(skipped, line)
+		return nil
+	})
+	return entries, skipped
+}
+
+// parseLinesEach parses the lines of a listing like parseLines, calling fn
+// for each entry and skip for each line which can't be parsed. It stops at
+// the first error they return.
+func (c *ServerConn) parseLinesEach(scanner *bufio.Scanner, parser parseFunc, fn func(*Entry) error, skip func(*ListLineError) error) error {
+	now := c.now()
+	var pending string
 	for scanner.Scan() {
-		entry, errParse := parser(scanner.Text(), now, c.options.location)
-		if errParse == nil {
-			entries = append(entries, entry)
+		line := scanner.Text()
+		if pending == "" && isListHeader(line) {
+			continue
 		}
+		if pending != "" {
+			line = pending + " " + line
+			pending = ""
+		}
+		entry, errParse := parser(line, now, c.options.location)
+		if errParse == errIncompleteListLine {
+			pending = line
+			continue
+		}
+		if errParse != nil {
+			if err := skip(&ListLineError{Line: line, Err: errParse}); err != nil {
+				return err
+			}
+			continue
+		}
+		if c.options.disableRawLines {
+			entry.Raw = ""
+		} else {
+			entry.Raw = line
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	if pending != "" {
+		return skip(&ListLineError{Line: pending, Err: errUnsupportedListLine})
 	}
 
-	if err := scanner.Err(); err != nil {
-		errs = multierror.Append(errs, err)
+	return nil
+}
+
+// StatList issues a STAT FTP command to list the specified directory on the
+// control connection, which avoids opening a data connection.
+//
+// The listing is parsed like the one of List, regardless of MLSD support.
+func (c *ServerConn) StatList(path string) (entries []*Entry, err error) {
+	lines, err := c.stat(path)
+	if err != nil {
+		return nil, err
 	}
-	if err := r.Close(); err != nil {
-		errs = multierror.Append(errs, err)
+	if len(lines) < 3 {
+		return nil, nil
+	}
+
+	entries, skipped := c.parseStatLines(lines[1 : len(lines)-1])
+	if c.options.strictList && len(skipped) > 0 {
+		return entries, skipped[0]
+	}
+	return entries, nil
+}
+
+// ErrStatDirectory is returned by StatFile when the path is a directory, as
+// the server lists its content: StatList returns it.
+var ErrStatDirectory = errors.New("STAT listed a directory, see StatList")
+
+// StatFile issues a STAT FTP command to get the entry of the specified file
+// from the single listing line most servers reply with, on the control
+// connection. It is a cheap way to stat a file on servers without MLST or
+// SIZE, which avoids opening a data connection.
+//
+// If the server lists the content of a directory instead, a *PathError
+// matching ErrStatDirectory is returned.
+func (c *ServerConn) StatFile(filePath string) (*Entry, error) {
+	lines, err := c.stat(filePath)
+	if err != nil {
+		return nil, pathError("STAT", filePath, err)
+	}
+
+	var listing []string
+	switch {
+	case len(lines) > 2:
+		listing = lines[1 : len(lines)-1]
+	case len(lines) == 1:
+		// Some servers reply with the listing line alone
+		listing = lines
+	}
+
+	entries, skipped := c.parseStatLines(listing)
+	if len(entries) == 0 {
+		if len(skipped) > 0 && len(lines) > 2 {
+			return nil, skipped[0]
+		}
+		return nil, &PathError{Op: "STAT", Path: filePath, Err: fs.ErrNotExist}
+	}
+
+	// The content of a directory with a single entry is told apart by its
+	// name
+	entry := entries[0]
+	if len(entries) > 1 || path.Base(entry.Name) != path.Base(filePath) {
+		return nil, &PathError{Op: "STAT", Path: filePath, Err: ErrStatDirectory}
 	}
+	entry.Name = path.Base(entry.Name)
+	return entry, nil
+}
+
+// stat issues a STAT FTP command with a path, and returns the lines of the
+// reply, without the reply code or the indentation some servers put at the
+// beginning of each line.
+func (c *ServerConn) stat(path string) ([]string, error) {
+	code, msg, err := c.cmd(-1, "STAT %s", path)
+	if err != nil {
+		return nil, err
+	}
+	if code != StatusSystem && code != StatusDirectory && code != StatusFile {
+		return nil, &textproto.Error{Code: code, Msg: msg}
+	}
+
+	lines := strings.Split(msg, "\n")
+	prefix := strconv.Itoa(code) + "-"
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(strings.TrimPrefix(line, prefix), " ")
+	}
+	return lines, nil
+}
+
+// parseStatLines parses the listing lines of a reply to STAT.
+func (c *ServerConn) parseStatLines(lines []string) (entries []*Entry, skipped []*ListLineError) {
+	scanner := bufio.NewScanner(strings.NewReader(strings.Join(lines, "\n")))
+	return c.parseLines(scanner, c.parseListLine)
+}
 
-	return entries, errs.ErrorOrNil()
+// now returns the reference time of the listings, see DialWithClock.
+func (c *ServerConn) now() time.Time {
+	if c.options.clock != nil {
+		return c.options.clock()
+	}
+	return time.Now()
+}
+
+// parseListLine parses a LIST line with the parsers of the connection.
+func (c *ServerConn) parseListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	return parseListLineWith(c.listParsers, line, now, loc)
 }
 
 // IsTimePreciseInList returns true if client and server support the MLSD
 // command so List can return time with 1-second precision for all files.
+// Otherwise, the precision of each entry is given by its TimeResolution.
 func (c *ServerConn) IsTimePreciseInList() bool {
-	return c.mlstSupported
+	return c.mlstSupported && c.hasFact("modify")
 }
 
 // ChangeDir issues a CWD FTP command, which changes the current directory to
 // the specified path.
 func (c *ServerConn) ChangeDir(path string) error {
-	_, _, err := c.cmd(StatusRequestedFileActionOK, "CWD %s", path)
+	err := c.retry(func() error {
+		_, _, err := c.cmd(StatusRequestedFileActionOK, "CWD %s", path)
+		return pathError("CWD", path, err)
+	})
+	c.trackDir(path, err)
 	return err
 }
 
@@ -673,6 +1425,7 @@ func (c *ServerConn) ChangeDir(path string) error {
 // with a path set to "..".
 func (c *ServerConn) ChangeDirToParent() error {
 	_, _, err := c.cmd(StatusRequestedFileActionOK, "CDUP")
+	c.cwd = ""
 	return err
 }
 
@@ -691,12 +1444,26 @@ func (c *ServerConn) CurrentDir() (string, error) {
 		return "", errors.New("unsuported PWD response format")
 	}
 
-	return msg[start+1 : end], nil
+	c.cwd = msg[start+1 : end]
+	return c.cwd, nil
 }
 
 // FileSize issues a SIZE FTP command, which Returns the size of the file
+//
+// The size is the one of a binary transfer: the transfer type is switched
+// back to binary with a TYPE I command if needed.
 func (c *ServerConn) FileSize(path string) (int64, error) {
-	_, msg, err := c.cmd(StatusFile, "SIZE %s", path)
+	// The size of a file transferred in ASCII mode depends on its content,
+	// which is why some servers refuse SIZE in this mode.
+	if err := c.setType(TransferTypeBinary); err != nil {
+		return 0, err
+	}
+
+	var msg string
+	err := c.retry(func() (err error) {
+		_, msg, err = c.cmd(StatusFile, "SIZE %s", path)
+		return pathError("SIZE", path, err)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -711,11 +1478,15 @@ func (c *ServerConn) GetTime(path string) (time.Time, error) {
 	if !c.mdtmSupported {
 		return t, errors.New("GetTime is not supported")
 	}
-	_, msg, err := c.cmd(StatusFile, "MDTM %s", path)
+	var msg string
+	err := c.retry(func() (err error) {
+		_, msg, err = c.cmd(StatusFile, "MDTM %s", path)
+		return pathError("MDTM", path, err)
+	})
 	if err != nil {
 		return t, err
 	}
-	return time.ParseInLocation(timeFormat, msg, time.UTC)
+	return parseTimeVal(msg)
 }
 
 // IsGetTimeSupported allows library callers to check in advance that they
@@ -733,8 +1504,10 @@ func (c *ServerConn) SetTime(path string, t time.Time) (err error) {
 	switch {
 	case c.mfmtSupported:
 		_, _, err = c.cmd(StatusFile, "MFMT %s %s", utime, path)
+		err = pathError("MFMT", path, err)
 	case c.mdtmCanWrite:
 		_, _, err = c.cmd(StatusFile, "MDTM %s %s", utime, path)
+		err = pathError("MDTM", path, err)
 	default:
 		err = errors.New("SetTime is not supported")
 	}
@@ -751,29 +1524,62 @@ func (c *ServerConn) IsSetTimeSupported() bool {
 // FTP server.
 //
 // The returned ReadCloser must be closed to cleanup the FTP data connection.
-func (c *ServerConn) Retr(path string) (*Response, error) {
-	return c.RetrFrom(path, 0)
+func (c *ServerConn) Retr(path string, options ...TransferOption) (*Response, error) {
+	return c.RetrFrom(path, 0, options...)
 }
 
 // RetrFrom issues a RETR FTP command to fetch the specified file from the remote
 // FTP server, the server will not send the offset first bytes of the file.
 //
 // The returned ReadCloser must be closed to cleanup the FTP data connection.
-func (c *ServerConn) RetrFrom(path string, offset uint64) (*Response, error) {
-	conn, err := c.cmdDataConnFrom(offset, "RETR %s", path)
+func (c *ServerConn) RetrFrom(path string, offset uint64, options ...TransferOption) (*Response, error) {
+	to := newTransferOptions(options)
+
+	if err := c.checkDownloadSize(path, offset, to.maxBytes); err != nil {
+		return nil, err
+	}
+
+	transferType := c.transferType(to)
+	if err := c.setType(transferType); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if c.options.hooks != nil {
+		c.options.hooks.onTransferStart(TransferDownload, path)
+	}
+
+	var conn net.Conn
+	err := c.retry(func() (err error) {
+		conn, err = c.cmdDataConnFrom(offset, "RETR %s", path)
+		return pathError("RETR", path, err)
+	})
 	if err != nil {
+		if c.options.hooks != nil {
+			c.options.hooks.onTransferEnd(TransferDownload, path, 0, start, err)
+		}
+		if to.stats != nil {
+			*to.stats = TransferStats{Duration: time.Since(start)}
+		}
 		return nil, err
 	}
+	if transferType == TransferTypeASCII {
+		conn = &asciiConn{Conn: conn}
+	}
+	if to.encoding != nil {
+		conn = &transformConn{Conn: conn, r: to.encoding.NewDecoder().Reader(conn)}
+	}
 
-	return &Response{conn: conn, c: c}, nil
+	c.transferPending = true
+	return &Response{conn: conn, c: c, download: true, maxBytes: to.maxBytes, progress: to.newProgress(), path: path, start: start, stats: to.stats}, nil
 }
 
 // Stor issues a STOR FTP command to store a file to the remote FTP server.
 // Stor creates the specified file with the content of the io.Reader.
 //
 // Hint: io.Pipe() can be used if an io.Writer is required.
-func (c *ServerConn) Stor(path string, r io.Reader) error {
-	return c.StorFrom(path, r, 0)
+func (c *ServerConn) Stor(path string, r io.Reader, options ...TransferOption) error {
+	return c.StorFrom(path, r, 0, options...)
 }
 
 // checkDataShut reads the "closing data connection" status from the
@@ -784,14 +1590,40 @@ func (c *ServerConn) Stor(path string, r io.Reader) error {
 // The ShutTimeout dial option will rescue here. It will nudge the control
 // connection deadline right before checking the data closing status.
 func (c *ServerConn) checkDataShut() error {
+	_, err := c.readDataShut()
+	return err
+}
+
+// readDataShut is like checkDataShut, returning the message of the reply.
+// Besides 226, the 250 reply is accepted, as sent by z/OS servers.
+func (c *ServerConn) readDataShut() (string, error) {
 	if c.options.shutTimeout != 0 {
 		shutDeadline := time.Now().Add(c.options.shutTimeout)
 		if err := c.netConn.SetDeadline(shutDeadline); err != nil {
-			return err
+			return "", err
+		}
+	}
+	code, msg, err := c.readCompletion()
+	if err != nil {
+		return "", err
+	}
+	if code != StatusClosingDataConnection && code != StatusRequestedFileActionOK {
+		return "", &textproto.Error{Code: code, Msg: msg}
+	}
+	c.afterTransfer = true
+	return msg, nil
+}
+
+// readCompletion reads the reply ending a transfer, skipping the
+// preliminary replies some servers send after the first one, e.g. "150
+// Accepted data connection".
+func (c *ServerConn) readCompletion() (int, string, error) {
+	for {
+		code, msg, err := c.readResponse(-1)
+		if err != nil || code >= 200 {
+			return code, msg, err
 		}
 	}
-	_, _, err := c.conn.ReadResponse(StatusClosingDataConnection)
-	return err
 }
 
 // StorFrom issues a STOR FTP command to store a file to the remote FTP server.
@@ -799,31 +1631,17 @@ func (c *ServerConn) checkDataShut() error {
 // on the server will start at the given file offset.
 //
 // Hint: io.Pipe() can be used if an io.Writer is required.
-func (c *ServerConn) StorFrom(path string, r io.Reader, offset uint64) error {
-	conn, err := c.cmdDataConnFrom(offset, "STOR %s", path)
-	if err != nil {
+func (c *ServerConn) StorFrom(path string, r io.Reader, offset uint64, options ...TransferOption) error {
+	to := newTransferOptions(options)
+	upload := func() error {
+		_, err := c.upload("STOR", path, r, offset, to)
 		return err
 	}
 
-	var errs *multierror.Error
-
-	// if the upload fails we still need to try to read the server
-	// response otherwise if the failure is not due to a connection problem,
-	// for example the server denied the upload for quota limits, we miss
-	// the response and we cannot use the connection to send other commands.
-	if _, err := io.Copy(conn, r); err != nil {
-		errs = multierror.Append(errs, err)
-	}
-
-	if err := conn.Close(); err != nil {
-		errs = multierror.Append(errs, err)
-	}
-
-	if err := c.checkDataShut(); err != nil {
-		errs = multierror.Append(errs, err)
+	if to.retry {
+		return c.retryReader(r, upload)
 	}
-
-	return errs.ErrorOrNil()
+	return upload()
 }
 
 // Append issues a APPE FTP command to store a file to the remote FTP server.
@@ -831,102 +1649,212 @@ func (c *ServerConn) StorFrom(path string, r io.Reader, offset uint64) error {
 // io.Reader is appended. Otherwise, a new file is created with that content.
 //
 // Hint: io.Pipe() can be used if an io.Writer is required.
-func (c *ServerConn) Append(path string, r io.Reader) error {
-	conn, err := c.cmdDataConnFrom(0, "APPE %s", path)
+func (c *ServerConn) Append(path string, r io.Reader, options ...TransferOption) error {
+	_, err := c.upload("APPE", path, r, 0, newTransferOptions(options))
+	return err
+}
+
+// upload sends the content of r with the STOR or APPE command cmd. It returns
+// the message of the completion reply.
+func (c *ServerConn) upload(cmd, path string, r io.Reader, offset uint64, to *transferOptions) (msg string, err error) {
+	defer c.invalidateParent(path)
+
+	var n int64
+	var ready time.Duration
+	start := time.Now()
+	if h := c.options.hooks; h != nil {
+		h.onTransferStart(TransferUpload, path)
+		defer func() {
+			h.onTransferEnd(TransferUpload, path, n, start, err)
+		}()
+	}
+	if to.stats != nil {
+		defer func() {
+			*to.stats = TransferStats{Bytes: n, Duration: time.Since(start), TimeToFirstByte: ready}
+		}()
+	}
+
+	transferType := c.transferType(to)
+	if err := c.setType(transferType); err != nil {
+		return "", err
+	}
+	if err := c.allocate(r, to); err != nil {
+		return "", err
+	}
+
+	conn, err := c.cmdDataConnFrom(offset, "%s %s", cmd, path)
 	if err != nil {
-		return err
+		return "", pathError(cmd, path, err)
 	}
+	defer c.release()
+	ready = time.Since(start)
 
 	var errs *multierror.Error
 
-	if _, err := io.Copy(conn, r); err != nil {
+	if p := to.newProgress(); p != nil {
+		r = &progressReader{r: r, progress: p}
+		defer p.finish()
+	}
+
+	var w io.Writer = conn
+	var zw *zlib.Writer
+	if c.modeZ {
+		zw, _ = zlib.NewWriterLevel(conn, c.options.compressLevel())
+		w = zw
+	}
+	if transferType == TransferTypeASCII {
+		w = &asciiWriter{w: w}
+	}
+	var ew *transform.Writer
+	if to.encoding != nil {
+		ew = transform.NewWriter(w, to.encoding.NewEncoder())
+		w = ew
+	}
+
+	// if the upload fails we still need to try to read the server
+	// response otherwise if the failure is not due to a connection problem,
+	// for example the server denied the upload for quota limits, we miss
+	// the response and we cannot use the connection to send other commands.
+	if n, err = copyBuffer(c.buffers, w, r); err != nil {
 		errs = multierror.Append(errs, err)
 	}
 
+	// The encoder and the compressor may hold the last bytes until closed
+	if ew != nil {
+		if err := ew.Close(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
 	if err := conn.Close(); err != nil {
 		errs = multierror.Append(errs, err)
 	}
 
-	if err := c.checkDataShut(); err != nil {
-		errs = multierror.Append(errs, err)
+	msg, err = c.readDataShut()
+	if err != nil {
+		errs = multierror.Append(errs, pathError(cmd, path, err))
 	}
 
-	return errs.ErrorOrNil()
+	return msg, errs.ErrorOrNil()
 }
 
-// Rename renames a file on the remote FTP server.
+// Rename renames a file on the remote FTP server. It can also move the file
+// to another existing directory, see Move otherwise.
+//
+// Failures are reported as a *RenameError, telling whether the source was
+// refused by RNFR or the destination by RNTO.
 func (c *ServerConn) Rename(from, to string) error {
+	defer func() {
+		c.invalidateTree(from)
+		c.invalidateParent(from)
+		c.invalidateParent(to)
+	}()
+
 	_, _, err := c.cmd(StatusRequestFilePending, "RNFR %s", from)
 	if err != nil {
-		return err
+		return &RenameError{Op: "RNFR", From: from, To: to, Err: err}
 	}
 
 	_, _, err = c.cmd(StatusRequestedFileActionOK, "RNTO %s", to)
-	return err
+	if err != nil {
+		return &RenameError{Op: "RNTO", From: from, To: to, Err: err}
+	}
+	return nil
 }
 
 // Delete issues a DELE FTP command to delete the specified file from the
 // remote FTP server.
 func (c *ServerConn) Delete(path string) error {
+	defer c.invalidateParent(path)
+
 	_, _, err := c.cmd(StatusRequestedFileActionOK, "DELE %s", path)
-	return err
+	return pathError("DELE", path, err)
 }
 
 // RemoveDirRecur deletes a non-empty folder recursively using
 // RemoveDir and Delete
-func (c *ServerConn) RemoveDirRecur(path string) error {
-	err := c.ChangeDir(path)
-	if err != nil {
-		return err
-	}
-	currentDir, err := c.CurrentDir()
-	if err != nil {
-		return err
-	}
+//
+// Symbolic links are deleted, never followed. Failing to delete an entry does
+// not stop the deletion of the others: all the errors are returned together
+// as Errors.
+func (c *ServerConn) RemoveDirRecur(dir string) error {
+	dir = path.Clean(dir)
 
-	entries, err := c.List(currentDir)
+	entries, err := c.List(dir)
 	if err != nil {
 		return err
 	}
 
+	var errs Errors
 	for _, entry := range entries {
-		if entry.Name != ".." && entry.Name != "." {
-			if entry.Type == EntryTypeFolder {
-				err = c.RemoveDirRecur(currentDir + "/" + entry.Name)
-				if err != nil {
-					return err
-				}
-			} else {
-				err = c.Delete(entry.Name)
-				if err != nil {
-					return err
-				}
-			}
+		if entry.Pseudo {
+			continue
+		}
+
+		entryPath := path.Join(dir, entry.FileInfo().Name())
+		if entry.Type == EntryTypeFolder {
+			errs.add("RMD", entryPath, c.RemoveDirRecur(entryPath))
+		} else {
+			errs.add("DELE", entryPath, c.Delete(entryPath))
 		}
 	}
-	err = c.ChangeDirToParent()
-	if err != nil {
-		return err
+
+	// The directory can't be removed if it is not empty
+	if len(errs) > 0 {
+		return errs
 	}
-	err = c.RemoveDir(currentDir)
-	return err
+	return c.RemoveDir(dir)
 }
 
 // MakeDir issues a MKD FTP command to create the specified directory on the
 // remote FTP server.
 func (c *ServerConn) MakeDir(path string) error {
+	defer c.invalidateParent(path)
+
 	_, _, err := c.cmd(StatusPathCreated, "MKD %s", path)
-	return err
+	return pathError("MKD", path, err)
+}
+
+// MakeDirAll creates a directory along with any necessary parents, like
+// os.MkdirAll. Directories which already exist are not an error, whatever the
+// reply of the server to the MKD FTP command: their existence is confirmed by
+// changing directory to them. The working directory is left unchanged.
+func (c *ServerConn) MakeDirAll(dir string) error {
+	dir = path.Clean(dir)
+	if dir == "." || dir == "/" {
+		return nil
+	}
+
+	// Create each component in turn, from the top
+	for i := 1; i <= len(dir); i++ {
+		if i < len(dir) && dir[i] != '/' {
+			continue
+		}
+		if err := c.makeDir(dir[:i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // RemoveDir issues a RMD FTP command to remove the specified directory from
 // the remote FTP server.
 func (c *ServerConn) RemoveDir(path string) error {
+	defer func() {
+		c.invalidateTree(path)
+		c.invalidateParent(path)
+	}()
+
 	_, _, err := c.cmd(StatusRequestedFileActionOK, "RMD %s", path)
-	return err
+	return pathError("RMD", path, err)
 }
 
-//Walk prepares the internal walk function so that the caller can begin traversing the directory
+// Walk prepares the internal walk function so that the caller can begin traversing the directory
 func (c *ServerConn) Walk(root string) *Walker {
 	w := new(Walker)
 	w.serverConn = c
@@ -952,57 +1880,271 @@ func (c *ServerConn) NoOp() error {
 // Logout issues a REIN FTP command to logout the current user.
 func (c *ServerConn) Logout() error {
 	_, _, err := c.cmd(StatusReady, "REIN")
+	c.cwd = ""
 	return err
 }
 
 // Quit issues a QUIT FTP command to properly close the connection from the
 // remote FTP server.
+//
+// The connection is closed even if the server does not answer within the
+// timeout set with DialWithQuitTimeout, 2 seconds by default. A transfer in
+// progress, e.g. of a Response which is not closed, is ended first and its
+// error is returned. Calling Quit again has no effect, so that it can be
+// deferred even if an operation already failed, and neither has calling it
+// once the server closed the connection, see ErrServerClosed.
 func (c *ServerConn) Quit() error {
+	if c.closed {
+		return nil
+	}
+	c.stopKeepAlive()
+	c.closed = true
+
+	if c.closedErr != nil {
+		// The server already closed the connection
+		if c.options.hooks != nil && c.options.hooks.OnDisconnect != nil {
+			c.options.hooks.OnDisconnect(c.netConn.RemoteAddr().String(), c.closedErr)
+		}
+		return nil
+	}
+
 	var errs *multierror.Error
 
-	if _, err := c.conn.Cmd("QUIT"); err != nil {
+	timeout := c.options.quitTimeout
+	if timeout == 0 {
+		timeout = defaultQuitTimeout
+	}
+	if err := c.netConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	if c.transfer != nil {
+		_ = c.transfer.Close()
+		if err := c.checkDataShut(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	if err := c.sendCmd("QUIT"); err != nil {
 		errs = multierror.Append(errs, err)
+	} else {
+		// The connection is closed anyway: the reply only lets the server
+		// end the session cleanly
+		_, _, _ = c.conn.ReadResponse(StatusClosing)
 	}
 
 	if err := c.conn.Close(); err != nil {
 		errs = multierror.Append(errs, err)
 	}
 
+	if c.options.hooks != nil && c.options.hooks.OnDisconnect != nil {
+		c.options.hooks.OnDisconnect(c.netConn.RemoteAddr().String(), errs.ErrorOrNil())
+	}
 	return errs.ErrorOrNil()
 }
 
+// Close is an alias of Quit, which makes ServerConn an io.Closer.
+func (c *ServerConn) Close() error {
+	return c.Quit()
+}
+
 // Read implements the io.Reader interface on a FTP data connection.
 func (r *Response) Read(buf []byte) (int, error) {
-	return r.conn.Read(buf)
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	remaining, limit := int64(-1), int64(-1)
+	if r.download {
+		remaining, limit = r.c.downloadLimit(r.maxBytes, r.BytesRead())
+	}
+
+	// Read one byte more than allowed to detect a file exceeding the limit
+	if remaining >= 0 && int64(len(buf)) > remaining+1 {
+		buf = buf[:remaining+1]
+	}
+
+	n, err := r.conn.Read(buf)
+	if err != nil {
+		r.readErr = err
+	}
+	if remaining >= 0 && int64(n) > remaining {
+		n = int(remaining)
+		r.err = &MaxSizeError{Limit: limit, Written: r.BytesRead() + int64(n)}
+		err = r.err
+	}
+
+	r.count(int64(n))
+	if r.progress != nil {
+		r.progress.add(int64(n))
+		if err != nil {
+			r.progress.finish()
+		}
+	}
+
+	return n, err
+}
+
+// count accounts for n bytes read from the data connection
+func (r *Response) count(n int64) {
+	if r.firstByte == 0 && n > 0 {
+		r.firstByte = time.Since(r.start)
+	}
+	atomic.AddInt64(&r.read, n)
+	if r.download {
+		r.c.downloaded += n
+	}
 }
 
 // Close implements the io.Closer interface on a FTP data connection.
 // After the first call, Close will do nothing and return nil.
+//
+// If the transfer was stopped early, e.g. because it exceeded a size limit,
+// it is aborted on the server with an ABOR FTP command. If the data was
+// simply not read to the end, a small remainder is drained so that the
+// transfer completes normally, or else the data connection is closed and
+// the reply telling that the server aborted the transfer is consumed: the
+// control connection is then ready for the next command either way.
 func (r *Response) Close() error {
 	if r.closed {
 		return nil
 	}
+	if r.c.closed {
+		// Quit already ended the transfer
+		r.closed = true
+		return nil
+	}
+
+	if r.progress != nil {
+		r.progress.finish()
+	}
 
 	var errs *multierror.Error
 
+	if r.err == nil && r.readErr == nil {
+		r.drain()
+	}
+
 	if err := r.conn.Close(); err != nil {
 		errs = multierror.Append(errs, err)
 	}
 
-	if err := r.c.checkDataShut(); err != nil {
-		errs = multierror.Append(errs, err)
+	switch {
+	case r.err != nil:
+		if err := r.c.abortTransfer(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	case r.readErr == io.EOF:
+		if err := r.c.checkDataShut(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	default:
+		// The server may or may not have noticed that the data connection
+		// was closed before the end
+		if _, err := r.c.readDataShut(); err != nil && !isAbortReply(err) {
+			errs = multierror.Append(errs, err)
+		}
 	}
 
 	r.closed = true
+	if r.download {
+		r.duration = time.Since(r.start)
+		if r.stats != nil {
+			*r.stats = r.Stats()
+		}
+		r.c.transferPending = false
+		if r.c.options.hooks != nil {
+			err := r.err
+			if err == nil {
+				err = errs.ErrorOrNil()
+			}
+			r.c.options.hooks.onTransferEnd(TransferDownload, r.path, r.BytesRead(), r.start, err)
+		}
+	}
+	r.c.release()
+	return errs.ErrorOrNil()
+}
+
+// stop aborts the transfer because of err, which is returned along with the
+// errors of Close.
+func (r *Response) stop(err error) error {
+	r.err = err
+	if errClose := r.Close(); errClose != nil {
+		return multierror.Append(err, errClose)
+	}
+	return err
+}
+
+// closeScanned closes a Response read by scanner, returning the errors of
+// both.
+func (r *Response) closeScanned(scanner *bufio.Scanner) error {
+	var errs *multierror.Error
+
+	if err := scanner.Err(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if err := r.Close(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
 	return errs.ErrorOrNil()
 }
 
 // SetDeadline sets the deadlines associated with the connection.
+// Reading a stalled transfer fails with a timeout once the deadline passes.
 func (r *Response) SetDeadline(t time.Time) error {
 	return r.conn.SetDeadline(t)
 }
 
-// String returns the string representation of EntryType t.
+// BytesRead returns the number of bytes read from the data connection so
+// far. It may be called concurrently with Read, e.g. to display progress.
+func (r *Response) BytesRead() int64 {
+	return atomic.LoadInt64(&r.read)
+}
+
+// entryTypeNames are the string representations of the entry types
+var entryTypeNames = [...]string{"file", "folder", "link", "device", "pipe", "socket"}
+
+// String returns the string representation of EntryType t, or "unknown(n)"
+// for a value which is not one of the constants.
 func (t EntryType) String() string {
-	return [...]string{"file", "folder", "link"}[t]
+	if t < 0 || int(t) >= len(entryTypeNames) {
+		return "unknown(" + strconv.Itoa(int(t)) + ")"
+	}
+	return entryTypeNames[t]
+}
+
+// ParseEntryType returns the EntryType represented by s, as returned by
+// String. "fifo" is accepted as well as "pipe".
+func ParseEntryType(s string) (EntryType, error) {
+	for i, name := range entryTypeNames {
+		if s == name {
+			return EntryType(i), nil
+		}
+	}
+	if s == "fifo" {
+		return EntryTypePipe, nil
+	}
+	if strings.HasPrefix(s, "unknown(") && strings.HasSuffix(s, ")") {
+		if n, err := strconv.Atoi(s[len("unknown(") : len(s)-1]); err == nil {
+			return EntryType(n), nil
+		}
+	}
+	return 0, errors.New("invalid entry type " + strconv.Quote(s))
+}
+
+// MarshalText implements encoding.TextMarshaler, so that EntryType t is
+// encoded by its string representation, e.g. in JSON.
+func (t EntryType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, see ParseEntryType.
+func (t *EntryType) UnmarshalText(text []byte) error {
+	parsed, err := ParseEntryType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
 }
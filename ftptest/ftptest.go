@@ -0,0 +1,549 @@
+// Package ftptest provides a scripted FTP server for the tests of code using
+// an FTP client, in the spirit of net/http/httptest.
+//
+// The server is not a real FTP server: it replies to the commands as told by
+// a Script, serves canned listings and files over passive data connections,
+// and records the commands it receives.
+package ftptest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dataTimeout bounds the wait for the client to open a data connection
+const dataTimeout = 5 * time.Second
+
+// Script describes how a Server replies to the commands.
+//
+// The commands which are not scripted get a sensible default reply: the login
+// succeeds, listings and files are served from Listings and Files, uploaded
+// files are added to Files, REST restarts the next RETR or STOR at an
+// offset, the working directory follows CWD and CDUP, and unknown commands
+// are refused with 502.
+type Script struct {
+	// Replies maps commands to their reply, e.g. "550 Permission denied".
+	// A key is either a full command, such as "DELE secret.txt", or a verb,
+	// such as "DELE", the full command taking precedence. Multiline replies
+	// are separated by "\r\n". A scripted transfer command is replied to
+	// without opening the data connection, to exercise the error paths.
+	Replies map[string]string
+
+	// RepliesOnce is like Replies for the first matching command only,
+	// e.g. to make the first STOR fail with "450 File busy". It takes
+	// precedence over Replies, and its entries are removed once used.
+	RepliesOnce map[string]string
+
+	// Listings maps the arguments of LIST, NLST and MLSD to their output.
+	// A key is either a path, or a verb followed by a path, such as
+	// "NLST /pub", the latter taking precedence. Paths which are not listed
+	// produce an empty listing.
+	Listings map[string]string
+
+	// Files maps paths to the content served by RETR and SIZE. Files
+	// uploaded with STOR and APPE are added, and RNFR/RNTO renames them.
+	Files map[string][]byte
+
+	// Transfers maps the transfer commands to the replies sent around
+	// their data connection, to mimic the servers which send unusual ones.
+	// A key is either a full command or a verb, like for Replies.
+	Transfers map[string]TransferReplies
+}
+
+// TransferReplies are the replies of a transfer command, see
+// Script.Transfers. Several replies are separated by "\r\n", like the
+// lines of a multiline reply.
+type TransferReplies struct {
+	Start string // before the transfer, "150 Opening data connection" if empty
+	End   string // after a successful one, "226 Transfer complete" if empty
+
+	// AbortAfter, if positive, makes the first transfer of the command
+	// fail with a 426 reply after as many bytes, as when the data
+	// connection drops. The bytes received by an upload are kept.
+	AbortAfter int
+}
+
+// Server is an FTP server listening on the loopback interface, for tests.
+type Server struct {
+	// Addr is the address of the server, e.g. "127.0.0.1:2121"
+	Addr string
+
+	listener net.Listener
+	tls      *tls.Config
+
+	mu       sync.Mutex
+	script   Script
+	commands []string
+	aborted  map[string]bool // transfers aborted with AbortAfter
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer starts and returns a Server replying according to script. The
+// caller should call Close when finished, to shut it down.
+func NewServer(script Script) *Server {
+	return newServer(script, nil)
+}
+
+// NewTLSServer starts and returns a Server using implicit TLS with config,
+// for the clients dialed with ftp.DialWithTLS. The data connections are
+// protected too once the client sent PROT P.
+func NewTLSServer(script Script, config *tls.Config) *Server {
+	return newServer(script, config)
+}
+
+func newServer(script Script, config *tls.Config) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("ftptest: failed to listen: %v", err))
+	}
+
+	if script.Files == nil {
+		script.Files = make(map[string][]byte)
+	}
+	s := &Server{
+		Addr:     l.Addr().String(),
+		listener: l,
+		tls:      config,
+		script:   script,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close shuts down the server and closes its connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Commands returns the commands received by the server so far, in order,
+// e.g. "USER anonymous".
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// File returns the content of the file at path, uploaded or scripted.
+func (s *Server) File(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.script.Files[path]
+	return data, ok
+}
+
+// replyOnce returns and removes the reply of RepliesOnce to the command, if
+// any. It must be called with s.mu held.
+func (s *Server) replyOnce(verb, arg string) (string, bool) {
+	for _, key := range []string{verb + " " + arg, verb} {
+		if reply, ok := s.script.RepliesOnce[key]; ok {
+			delete(s.script.RepliesOnce, key)
+			return reply, true
+		}
+	}
+	return "", false
+}
+
+// serve accepts the connections until the server is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		raw, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := raw
+		if s.tls != nil {
+			conn = tls.Server(raw, s.tls)
+		}
+
+		s.mu.Lock()
+		s.conns[raw] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			(&session{s: s, proto: textproto.NewConn(conn), cwd: "/"}).serve()
+
+			s.mu.Lock()
+			delete(s.conns, raw)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// session is a client connection to a Server
+type session struct {
+	s          *Server
+	proto      *textproto.Conn
+	data       *dataListener // passive listener, until the next transfer
+	prot       bool          // PROT P is in effect
+	cwd        string        // working directory
+	renameFrom string
+	rest       int // offset of the next transfer, set by REST
+}
+
+func (ss *session) serve() {
+	defer func() {
+		ss.closeData()
+		_ = ss.proto.Close()
+	}()
+
+	ss.reply("220 ftptest ready")
+	for {
+		line, err := ss.proto.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], line[i+1:]
+		}
+		verb = strings.ToUpper(verb)
+
+		ss.s.mu.Lock()
+		ss.s.commands = append(ss.s.commands, line)
+		reply, scripted := ss.s.replyOnce(verb, arg)
+		if !scripted {
+			reply, scripted = ss.s.script.Replies[verb+" "+arg]
+		}
+		if !scripted {
+			reply, scripted = ss.s.script.Replies[verb]
+		}
+		ss.s.mu.Unlock()
+
+		if scripted {
+			if isTransfer(verb) {
+				ss.closeData()
+			}
+			ss.reply(reply)
+			continue
+		}
+
+		if verb == "QUIT" {
+			ss.reply("221 Goodbye")
+			return
+		}
+		ss.handle(verb, arg)
+	}
+}
+
+// handle replies to the commands which are not scripted
+func (ss *session) handle(verb, arg string) {
+	switch verb {
+	case "USER":
+		ss.reply("331 Password required")
+	case "PASS":
+		ss.reply("230 Logged in")
+	case "FEAT":
+		ss.reply("211-Features:\r\n EPSV\r\n PASV\r\n SIZE\r\n UTF8\r\n211 End")
+	case "TYPE", "OPTS", "NOOP", "PBSZ", "MODE", "STRU":
+		ss.reply("200 OK")
+	case "PROT":
+		ss.prot = strings.EqualFold(arg, "P")
+		ss.reply("200 OK")
+	case "SYST":
+		ss.reply("215 UNIX Type: L8")
+	case "PWD":
+		ss.reply(fmt.Sprintf("257 %q is the current directory", ss.cwd))
+	case "CWD":
+		if strings.HasPrefix(arg, "/") {
+			ss.cwd = path.Clean(arg)
+		} else {
+			ss.cwd = path.Join(ss.cwd, arg)
+		}
+		ss.reply("250 OK")
+	case "CDUP":
+		ss.cwd = path.Dir(ss.cwd)
+		ss.reply("250 OK")
+	case "RMD", "DELE":
+		ss.reply("250 OK")
+	case "MKD":
+		ss.reply(fmt.Sprintf("257 %q created", arg))
+	case "REST":
+		rest, err := strconv.Atoi(arg)
+		if err != nil || rest < 0 {
+			ss.reply("501 Invalid offset")
+			return
+		}
+		ss.rest = rest
+		ss.reply("350 Restarting")
+	case "SIZE":
+		if data, ok := ss.s.File(arg); ok {
+			ss.reply("213 " + strconv.Itoa(len(data)))
+		} else {
+			ss.reply("550 No such file")
+		}
+	case "RNFR":
+		if _, ok := ss.s.File(arg); ok {
+			ss.renameFrom = arg
+			ss.reply("350 Ready for the destination")
+		} else {
+			ss.reply("550 No such file")
+		}
+	case "RNTO":
+		ss.s.mu.Lock()
+		files := ss.s.script.Files
+		files[arg] = files[ss.renameFrom]
+		delete(files, ss.renameFrom)
+		ss.s.mu.Unlock()
+		ss.reply("250 Renamed")
+	case "EPSV", "PASV":
+		ss.passive(verb)
+	case "LIST", "NLST", "MLSD":
+		ss.s.mu.Lock()
+		listing, ok := ss.s.script.Listings[verb+" "+stripListFlags(arg)]
+		if !ok {
+			listing = ss.s.script.Listings[stripListFlags(arg)]
+		}
+		ss.s.mu.Unlock()
+		ss.transfer(verb, arg, func(conn net.Conn) error {
+			_, err := io.WriteString(conn, listing)
+			return err
+		})
+	case "RETR":
+		data, ok := ss.s.File(arg)
+		if !ok {
+			ss.closeData()
+			ss.reply("550 No such file")
+			return
+		}
+		if ss.rest > len(data) {
+			ss.rest = len(data)
+		}
+		data = data[ss.rest:]
+		ss.transfer(verb, arg, func(conn net.Conn) error {
+			_, err := conn.Write(data)
+			return err
+		})
+	case "STOR", "APPE":
+		rest := ss.rest
+		ss.transfer(verb, arg, func(conn net.Conn) error {
+			var buf bytes.Buffer
+			_, err := io.Copy(&buf, conn)
+
+			ss.s.mu.Lock()
+			defer ss.s.mu.Unlock()
+			files := ss.s.script.Files
+			switch {
+			case verb == "APPE":
+				files[arg] = append(files[arg], buf.Bytes()...)
+			case rest > 0:
+				if rest > len(files[arg]) {
+					rest = len(files[arg])
+				}
+				files[arg] = append(files[arg][:rest:rest], buf.Bytes()...)
+			default:
+				files[arg] = buf.Bytes()
+			}
+			return err
+		})
+	default:
+		ss.reply("502 Command not implemented")
+	}
+}
+
+// reply writes the lines of a reply
+func (ss *session) reply(reply string) {
+	for _, line := range strings.Split(reply, "\n") {
+		_ = ss.proto.PrintfLine("%s", strings.TrimSuffix(line, "\r"))
+	}
+}
+
+// dataListener accepts a data connection in the background, so that its TLS
+// handshake completes before the client sends the transfer command.
+type dataListener struct {
+	net.Listener
+	conn chan net.Conn // nil on failure
+}
+
+// passive opens the listener of the next data connection
+func (ss *session) passive(verb string) {
+	ss.closeData()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		ss.reply("425 Can't open data connection")
+		return
+	}
+	ss.data = &dataListener{Listener: l, conn: make(chan net.Conn, 1)}
+	go ss.data.accept(ss.s.tls, ss.prot)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	if verb == "EPSV" {
+		ss.reply(fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)", port))
+	} else {
+		ss.reply(fmt.Sprintf("227 Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff))
+	}
+}
+
+func (l *dataListener) accept(config *tls.Config, prot bool) {
+	if tl, ok := l.Listener.(*net.TCPListener); ok {
+		_ = tl.SetDeadline(time.Now().Add(dataTimeout))
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		l.conn <- nil
+		return
+	}
+
+	if config != nil && prot {
+		tconn := tls.Server(conn, config)
+		_ = tconn.SetDeadline(time.Now().Add(dataTimeout))
+		if err := tconn.Handshake(); err != nil {
+			_ = conn.Close()
+			l.conn <- nil
+			return
+		}
+		_ = tconn.SetDeadline(time.Time{})
+		conn = tconn
+	}
+	l.conn <- conn
+}
+
+// transfer waits for the data connection of the command and calls fn with
+// it
+func (ss *session) transfer(verb, arg string, fn func(net.Conn) error) {
+	if ss.data == nil {
+		ss.reply("425 Use PASV or EPSV first")
+		return
+	}
+	l := ss.data
+	ss.data = nil
+	defer l.Close()
+
+	ss.rest = 0
+
+	ss.s.mu.Lock()
+	key := verb + " " + arg
+	replies, ok := ss.s.script.Transfers[key]
+	if !ok {
+		key = verb
+		replies = ss.s.script.Transfers[key]
+	}
+	abort := replies.AbortAfter > 0 && !ss.s.aborted[key]
+	if abort {
+		if ss.s.aborted == nil {
+			ss.s.aborted = make(map[string]bool)
+		}
+		ss.s.aborted[key] = true
+	}
+	ss.s.mu.Unlock()
+	if replies.Start == "" {
+		replies.Start = "150 Opening data connection"
+	}
+	if replies.End == "" {
+		replies.End = "226 Transfer complete"
+	}
+
+	ss.reply(replies.Start)
+
+	conn := <-l.conn
+	if conn == nil {
+		ss.reply("425 Can't open data connection")
+		return
+	}
+
+	if abort {
+		conn = &abortingConn{Conn: conn, left: replies.AbortAfter}
+	}
+	err := fn(conn)
+	if errClose := conn.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		ss.reply("426 Transfer aborted: " + err.Error())
+		return
+	}
+	ss.reply(replies.End)
+}
+
+// errAborted is the error of the transfers aborted with AbortAfter
+var errAborted = errors.New("connection dropped")
+
+// abortingConn is a data connection which fails after a number of bytes,
+// see TransferReplies.AbortAfter
+type abortingConn struct {
+	net.Conn
+	left int
+}
+
+func (c *abortingConn) Read(p []byte) (int, error) {
+	if c.left == 0 {
+		return 0, errAborted
+	}
+	if len(p) > c.left {
+		p = p[:c.left]
+	}
+	n, err := c.Conn.Read(p)
+	c.left -= n
+	return n, err
+}
+
+func (c *abortingConn) Write(p []byte) (int, error) {
+	if len(p) > c.left {
+		n, _ := c.Conn.Write(p[:c.left])
+		c.left -= n
+		return n, errAborted
+	}
+	n, err := c.Conn.Write(p)
+	c.left -= n
+	return n, err
+}
+
+// closeData closes the passive listener, if any
+func (ss *session) closeData() {
+	if ss.data != nil {
+		_ = ss.data.Close()
+		if conn := <-ss.data.conn; conn != nil {
+			_ = conn.Close()
+		}
+		ss.data = nil
+	}
+}
+
+// isTransfer reports whether verb uses a data connection
+func isTransfer(verb string) bool {
+	switch verb {
+	case "LIST", "NLST", "MLSD", "RETR", "STOR", "APPE":
+		return true
+	}
+	return false
+}
+
+// stripListFlags removes the ls flags, such as "-a", from the argument of a
+// listing command
+func stripListFlags(arg string) string {
+	for strings.HasPrefix(arg, "-") {
+		i := strings.IndexByte(arg, ' ')
+		if i < 0 {
+			return ""
+		}
+		arg = strings.TrimLeft(arg[i:], " ")
+	}
+	return arg
+}
@@ -0,0 +1,152 @@
+package ftptest_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+const listing = "-rw-r--r--    1 ftp      ftp            12 Dec 02  2009 hello.txt\r\n"
+
+func TestServer(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"DELE locked.txt": "550 Permission denied",
+		},
+		Listings: map[string]string{"/pub": listing},
+		Files:    map[string][]byte{"/pub/hello.txt": []byte("Hello world!")},
+	})
+	defer s.Close()
+
+	c, err := ftp.Dial(s.Addr, ftp.DialWithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, c.Login("user", "secret"))
+
+	entries, err := c.List("/pub")
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, "hello.txt", entries[0].Name)
+		assert.Equal(t, uint64(12), entries[0].Size)
+	}
+
+	r, err := c.Retr("/pub/hello.txt")
+	if assert.NoError(t, err) {
+		buf, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello world!", string(buf))
+		assert.NoError(t, r.Close())
+	}
+
+	assert.NoError(t, c.Stor("upload.txt", strings.NewReader("uploaded")))
+	assert.NoError(t, c.Rename("upload.txt", "renamed.txt"))
+	data, ok := s.File("renamed.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "uploaded", string(data))
+
+	err = c.Delete("locked.txt")
+	var protoErr *textproto.Error
+	if assert.True(t, errors.As(err, &protoErr)) {
+		assert.Equal(t, ftp.StatusFileUnavailable, protoErr.Code)
+	}
+	assert.NoError(t, c.Delete("renamed.txt"))
+
+	_, err = c.Retr("missing.txt")
+	assert.Error(t, err)
+
+	commands := s.Commands()
+	assert.Equal(t, "USER user", commands[0])
+	assert.Contains(t, commands, "RNTO renamed.txt")
+	assert.Equal(t, "RETR missing.txt", commands[len(commands)-1])
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestServerScriptedTransfer(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{"STOR": "452 Insufficient storage space"},
+	})
+	defer s.Close()
+
+	c, err := ftp.Dial(s.Addr, ftp.DialWithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, c.Login("anonymous", "anonymous"))
+	assert.Error(t, c.Stor("file", strings.NewReader("data")))
+
+	// The connection is still usable
+	assert.NoError(t, c.NoOp())
+	assert.NoError(t, c.Quit())
+}
+
+func TestTLSServer(t *testing.T) {
+	cert, pool := testCertificate(t)
+	s := ftptest.NewTLSServer(ftptest.Script{
+		Files: map[string][]byte{"secret.txt": []byte("classified")},
+	}, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer s.Close()
+
+	c, err := ftp.Dial(s.Addr, ftp.DialWithTimeout(5*time.Second), ftp.DialWithTLS(&tls.Config{RootCAs: pool}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, c.Login("user", "secret"))
+
+	r, err := c.Retr("secret.txt")
+	if assert.NoError(t, err) {
+		buf, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "classified", string(buf))
+		assert.NoError(t, r.Close())
+	}
+
+	assert.Contains(t, s.Commands(), "PROT P")
+	assert.NoError(t, c.Quit())
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1, and a pool
+// trusting it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
@@ -0,0 +1,58 @@
+//go:build go1.18
+// +build go1.18
+
+package ftp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzParseListLine(f *testing.F) {
+	for _, lt := range listTests {
+		f.Add(lt.line)
+	}
+	for _, lt := range listTestsFail {
+		f.Add(lt.line)
+	}
+	for _, lt := range listTestsSymlink {
+		f.Add(lt.line)
+	}
+	for _, l := range strings.Split(vmsListing+unixListing, "\r\n") {
+		f.Add(l)
+	}
+
+	dayFirst := newListLineParsers(true, "")
+	f.Fuzz(func(t *testing.T, line string) {
+		entry, err := parseListLine(line, now, time.UTC)
+		if err == nil && (entry == nil || entry.Name == "") {
+			t.Errorf("no name nor error for %q", line)
+		}
+		_, _ = parseListLineWith(dayFirst, line, now, time.UTC)
+		_ = isListHeader(line)
+	})
+}
+
+func FuzzParseDataSetListLine(f *testing.F) {
+	for _, l := range strings.Split(dataSetListing+gdgListing+pdsListing+jobDetailListing, "\r\n") {
+		f.Add(l)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		entry, err := parseDataSetListLine(line, time.UTC)
+		if err == nil {
+			if entry == nil {
+				t.Errorf("no entry nor error for %q", line)
+			} else {
+				entry.GDGGeneration()
+			}
+		}
+		_, _ = parsePDSMemberLine(line, time.UTC)
+		_, _ = parseJobLine(line)
+		_, _ = parseSpoolFileLine(line)
+		_ = isDataSetHeader(line)
+		_ = isPDSHeader(line)
+		_ = isJobListNoise(line)
+	})
+}
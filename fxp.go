@@ -0,0 +1,172 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrFXPRefused is matched by the errors returned when a server refuses to
+// take part in a server-to-server transfer.
+var ErrFXPRefused = errors.New("server-to-server transfer refused")
+
+// FXPRefusedError is returned by TransferBetween when the source server
+// refuses to connect to the address of the destination server, as many
+// servers do for security reasons. The file can then be relayed through the
+// client instead.
+type FXPRefusedError struct {
+	Addr string // address of the destination server
+	Err  error  // reply of the source server
+}
+
+func (e *FXPRefusedError) Error() string {
+	return fmt.Sprintf("%s: connecting to %s: %s", ErrFXPRefused, e.Addr, e.Err)
+}
+
+// Is reports whether target is ErrFXPRefused.
+func (e *FXPRefusedError) Is(target error) bool {
+	return target == ErrFXPRefused
+}
+
+// Unwrap returns the reply of the source server.
+func (e *FXPRefusedError) Unwrap() error {
+	return e.Err
+}
+
+// TransferBetween copies srcPath of the src server to dstPath of the dst
+// server directly between the servers, without the data going through the
+// client, which is known as FXP.
+//
+// The destination server is put in passive mode, and its address is given
+// to the source server with a PORT command. If the source server refuses
+// it, an *FXPRefusedError is returned. When both connections use TLS, the
+// source server is asked to act as the TLS client of the data connection
+// with SSCN, or else the destination server with CPSV. SSCN is turned off
+// again once the transfer is over.
+//
+// If the source server refuses the RETR command, the STOR command of the
+// destination server is aborted.
+func TransferBetween(src, dst *ServerConn, srcPath, dstPath string) (err error) {
+	if (src.options.tlsConfig == nil) != (dst.options.tlsConfig == nil) {
+		return errors.New("both servers or none must use TLS")
+	}
+	if src.modeZ != dst.modeZ {
+		return errors.New("both servers or none must use MODE Z")
+	}
+	for _, c := range []*ServerConn{src, dst} {
+		if err := c.setType(TransferTypeBinary); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range []*ServerConn{src, dst} {
+		if err := c.acquire(); err != nil {
+			return err
+		}
+		defer c.release()
+	}
+
+	pasv := "PASV"
+	if src.options.tlsConfig != nil {
+		_, sscn := src.features["SSCN"]
+		_, cpsv := dst.features["CPSV"]
+		switch {
+		case sscn:
+			if _, _, err := src.exchange(StatusCommandOK, "SSCN ON"); err != nil {
+				return &FXPRefusedError{Addr: dst.host, Err: err}
+			}
+			// The next transfers of src must not act as TLS client
+			defer func() {
+				if _, _, offErr := src.exchange(StatusCommandOK, "SSCN OFF"); offErr != nil && err == nil {
+					err = offErr
+				}
+			}()
+		case cpsv:
+			pasv = "CPSV"
+		default:
+			return &FXPRefusedError{Addr: dst.host, Err: errors.New("SSCN and CPSV are not supported")}
+		}
+	}
+
+	host, port, err := dst.passive(pasv)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = dst.host
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	if _, _, err := src.exchange(StatusCommandOK, "%s", portCommand(host, port)); err != nil {
+		return &FXPRefusedError{Addr: addr, Err: err}
+	}
+
+	// The destination server may only reply once the data connection is
+	// made, so both commands are sent before reading the replies.
+	defer dst.invalidateParent(dstPath)
+	if err := dst.sendCmd("STOR %s", dstPath); err != nil {
+		return err
+	}
+	if err := src.sendCmd("RETR %s", srcPath); err != nil {
+		return err
+	}
+
+	// The source server replies first: if it refuses the file, the
+	// destination server waits for a data connection which never comes.
+	code, msg, err := src.readResponse(-1)
+	if err == nil && code >= 400 {
+		err = &textproto.Error{Code: code, Msg: msg}
+	}
+	if err != nil {
+		errs := multierror.Append(nil, err)
+		if abortErr := dst.abortTransfer(); abortErr != nil {
+			errs = multierror.Append(errs, abortErr)
+		}
+		return errs.ErrorOrNil()
+	}
+
+	var errs *multierror.Error
+	if err := dst.readTransferReplies(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if code < 200 {
+		err = src.readTransferReplies()
+	} else if code != StatusClosingDataConnection && code != StatusRequestedFileActionOK {
+		err = &textproto.Error{Code: code, Msg: msg}
+	} else {
+		src.afterTransfer = true
+	}
+	if err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	return errs.ErrorOrNil()
+}
+
+// readTransferReplies reads the replies to a transfer command until the
+// transfer completes.
+func (c *ServerConn) readTransferReplies() error {
+	code, msg, err := c.readCompletion()
+	if err != nil {
+		return err
+	}
+	if code != StatusClosingDataConnection && code != StatusRequestedFileActionOK {
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+	c.afterTransfer = true
+	return nil
+}
+
+// portCommand returns the PORT FTP command for the address, or the EPRT one
+// of RFC 2428 for IPv6 addresses.
+func portCommand(host string, port int) string {
+	ip := net.ParseIP(host)
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("PORT %s,%d,%d", strings.ReplaceAll(ip4.String(), ".", ","), port/256, port%256)
+	}
+	return fmt.Sprintf("EPRT |2|%s|%d|", host, port)
+}
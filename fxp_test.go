@@ -0,0 +1,86 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net/textproto"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferBetween(t *testing.T) {
+	srcMock, src := openConn(t, "127.0.0.1")
+	dstMock, dst := openConn(t, "127.0.0.1")
+
+	assert.NoError(t, src.Stor("file", bytes.NewBufferString(testData)))
+
+	err := TransferBetween(src, dst, "file", "copy")
+	assert.NoError(t, err)
+	assert.Equal(t, testData, dstMock.fileCont.String())
+
+	closeConn(t, srcMock, src, []string{"EPSV", "STOR", "PORT", "RETR"})
+	closeConn(t, dstMock, dst, []string{"PASV", "STOR"})
+}
+
+func TestTransferBetweenRefused(t *testing.T) {
+	srcMock, src := openConnScenario(t, "127.0.0.1", "no-port")
+	dstMock, dst := openConn(t, "127.0.0.1")
+
+	err := TransferBetween(src, dst, "file", "copy")
+	var refused *FXPRefusedError
+	if assert.True(t, errors.As(err, &refused)) {
+		assert.Contains(t, refused.Addr, "127.0.0.1:")
+	}
+	assert.True(t, errors.Is(err, ErrFXPRefused))
+
+	closeConn(t, srcMock, src, []string{"PORT"})
+	closeConn(t, dstMock, dst, []string{"PASV"})
+}
+
+func TestTransferBetweenMissingFile(t *testing.T) {
+	srcServer := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT":     "211-Features:\r\n SSCN\r\n211 End",
+			"SSCN":     "200 SSCN:CLIENT METHOD",
+			"SSCN OFF": "200 SSCN:SERVER METHOD",
+			"PORT":     "200 PORT command successful",
+			"RETR":     "550 missing: No such file or directory",
+		},
+	})
+	defer srcServer.Close()
+	dstServer := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"STOR": "150 Ok to send data",
+			"ABOR": "426 Connection closed; transfer aborted\r\n226 ABOR successful",
+		},
+	})
+	defer dstServer.Close()
+	src := dialScript(t, srcServer)
+	dst := dialScript(t, dstServer)
+
+	// Both connections are treated as using TLS, so that SSCN is used
+	src.options.tlsConfig = &tls.Config{}
+	dst.options.tlsConfig = &tls.Config{}
+
+	err := TransferBetween(src, dst, "missing", "copy")
+	var textErr *textproto.Error
+	if assert.True(t, errors.As(err, &textErr), "%v", err) {
+		assert.Equal(t, StatusFileUnavailable, textErr.Code)
+	}
+	assert.Equal(t, 1, countCommands(dstServer, "ABOR"))
+	assert.Contains(t, srcServer.Commands(), "SSCN OFF")
+
+	// The replies were all read
+	assert.NoError(t, src.NoOp())
+	assert.NoError(t, dst.NoOp())
+	assert.NoError(t, src.Quit())
+	assert.NoError(t, dst.Quit())
+}
+
+func TestPortCommand(t *testing.T) {
+	assert.Equal(t, "PORT 192,168,0,1,4,1", portCommand("192.168.0.1", 1025))
+	assert.Equal(t, "EPRT |2|::1|1025|", portCommand("::1", 1025))
+}
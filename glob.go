@@ -0,0 +1,146 @@
+package ftp
+
+import (
+	"errors"
+	"net/textproto"
+	"path"
+	"strings"
+)
+
+// DialWithServerGlob returns a DialOption that makes Glob send the whole
+// pattern to the server as the argument of NLST, for servers known to expand
+// wildcards. A single command is then needed, but the entries returned by
+// Glob only have their Name set.
+func DialWithServerGlob(enabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.serverGlob = enabled
+	}}
+}
+
+// Glob returns the entries of the files matching pattern, with the syntax of
+// path.Match. The Name of the entries is their path, built like pattern:
+// relative to the working directory unless pattern is absolute.
+//
+// Each directory level of pattern containing wildcards is expanded by
+// listing the directories matched so far, e.g. "data/*/latest/*.csv". The
+// entries are sorted by name within each directory. Directories which can
+// not be listed because they do not exist are ignored, so that a pattern
+// without any match returns an empty slice. The only possible returned error
+// is path.ErrBadPattern, besides the errors of the server.
+func (c *ServerConn) Glob(pattern string) ([]*Entry, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	if c.options.serverGlob {
+		return c.serverGlob(pattern)
+	}
+
+	// dirs are the directories matched by the levels of pattern seen so far
+	dirs := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		dirs = []string{"/"}
+	}
+	levels := strings.FieldsFunc(pattern, func(r rune) bool { return r == '/' })
+	if len(levels) == 0 {
+		return []*Entry{}, nil
+	}
+
+	for _, level := range levels[:len(levels)-1] {
+		if !hasMeta(level) {
+			for i := range dirs {
+				dirs[i] = path.Join(dirs[i], level)
+			}
+			continue
+		}
+
+		var matched []string
+		for _, dir := range dirs {
+			entries, err := c.globDir(dir, level)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.Type == EntryTypeFolder || entry.Type == EntryTypeLink {
+					matched = append(matched, entry.Name)
+				}
+			}
+		}
+		dirs = matched
+	}
+
+	matches := []*Entry{}
+	for _, dir := range dirs {
+		entries, err := c.globDir(dir, levels[len(levels)-1])
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, entries...)
+	}
+	return matches, nil
+}
+
+// globDir lists dir and returns its entries matching pattern, sorted by
+// name, with their path as Name. A missing directory has no entries.
+func (c *ServerConn) globDir(dir, pattern string) ([]*Entry, error) {
+	entries, err := c.List(dir)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var matches []*Entry
+	for _, entry := range entries {
+		if entry.Pseudo {
+			continue
+		}
+		name := entry.FileInfo().Name()
+		if ok, _ := path.Match(pattern, name); ok {
+			entry.Name = path.Join(dir, name)
+			matches = append(matches, entry)
+		}
+	}
+
+	sortEntries(matches)
+	return matches, nil
+}
+
+// serverGlob expands pattern with an NLST FTP command.
+func (c *ServerConn) serverGlob(pattern string) ([]*Entry, error) {
+	names, err := c.NameList(pattern)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	// Some servers only send the base names of the matches, or ignore the
+	// pattern altogether
+	dir := path.Dir(pattern)
+	matches := []*Entry{}
+	for _, name := range names {
+		if !strings.Contains(name, "/") && dir != "." {
+			name = path.Join(dir, name)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, &Entry{Name: name})
+		}
+	}
+	return matches, nil
+}
+
+// hasMeta reports whether pattern contains any of the special characters of
+// path.Match
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// isNotFound reports whether err is the reply of the server to a command on
+// a file which does not exist
+func isNotFound(err error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return false
+	}
+	return protoErr.Code == StatusFileUnavailable || protoErr.Code == StatusFileActionIgnored
+}
@@ -0,0 +1,64 @@
+package ftp
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func globNames(entries []*Entry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestGlob(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"unix/*.txt", []string{"unix/a.txt", "unix/b.txt"}},
+		{"unix/b.txt", []string{"unix/b.txt"}},
+		{"/glob/*/?.txt", []string{"/glob/unix/a.txt", "/glob/unix/b.txt"}},
+		{"glob/*", []string{"glob/file.txt", "glob/unix", "glob/vms"}},
+		{"unix/*.csv", []string{}},
+		{"denied/*", []string{}},
+	}
+
+	for _, test := range tests {
+		entries, err := c.Glob(test.pattern)
+		if assert.NoError(t, err, test.pattern) {
+			assert.Equal(t, test.expected, globNames(entries), test.pattern)
+		}
+	}
+
+	entries, err := c.Glob("unix/a.txt")
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, uint64(2048), entries[0].Size)
+	}
+
+	_, err = c.Glob("unix/[")
+	assert.Equal(t, path.ErrBadPattern, err)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestGlobServer(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithServerGlob(true))
+
+	entries, err := c.Glob("/*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/incoming"}, globNames(entries))
+
+	entries, err = c.Glob("/*.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, globNames(entries))
+
+	closeConn(t, mock, c, []string{"EPSV", "NLST", "EPSV", "NLST"})
+}
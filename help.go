@@ -0,0 +1,117 @@
+package ftp
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+)
+
+// Help issues a HELP FTP command, and returns the names of the commands the
+// server implements, in upper case, e.g. "RETR". Unlike FEAT, the reply
+// lists the base commands. The commands marked with a star, which some
+// servers use for the ones which are recognized but not implemented, are
+// left out. The result is cached, and is then taken into account by the
+// operations using optional commands, such as Checksum.
+//
+// ErrCommandNotSupported is returned if the server does not implement HELP.
+func (c *ServerConn) Help() ([]string, error) {
+	if c.help == nil {
+		commands, err := c.readHelp("HELP")
+		if err != nil {
+			return nil, err
+		}
+		c.help = commands
+	}
+	return append([]string(nil), c.help...), nil
+}
+
+// SiteHelp is like Help for the subcommands of SITE, issuing a SITE HELP
+// command, e.g. "CHMOD". Its result is taken into account by Chmod.
+func (c *ServerConn) SiteHelp() ([]string, error) {
+	if c.siteHelp == nil {
+		commands, err := c.readHelp("SITE HELP")
+		if err != nil {
+			return nil, err
+		}
+		c.siteHelp = commands
+	}
+	return append([]string(nil), c.siteHelp...), nil
+}
+
+// readHelp issues the help command cmd and parses its reply
+func (c *ServerConn) readHelp(cmd string) ([]string, error) {
+	code, msg, err := c.cmd(-1, "%s", cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code >= 200 && code < 300:
+		return parseHelp(msg), nil
+	case code == StatusBadCommand || code == StatusNotImplemented:
+		return nil, fmt.Errorf("%s: %w", cmd, ErrCommandNotSupported)
+	}
+	return nil, &textproto.Error{Code: code, Msg: msg}
+}
+
+// parseHelp returns the commands listed by the reply to HELP, e.g.:
+//
+//	The following commands are recognized (* =>'s unimplemented):
+//	 CWD     XCWD    CDUP    XCUP    SMNT*   QUIT    PORT    PASV
+//	Direct comments to root.
+//
+// The lines of the inventory are the ones with only command names, which
+// are in upper case, while the other lines are sentences. It never returns
+// nil.
+func parseHelp(msg string) []string {
+	commands := []string{}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(msg, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		if len(fields) == 0 || !isHelpLine(fields) {
+			continue
+		}
+		for _, field := range fields {
+			if strings.HasSuffix(field, "*") || seen[field] {
+				continue
+			}
+			seen[field] = true
+			commands = append(commands, field)
+		}
+	}
+	return commands
+}
+
+// isHelpLine reports whether fields are command names, possibly marked with
+// a star
+func isHelpLine(fields []string) bool {
+	for _, field := range fields {
+		name := strings.TrimSuffix(field, "*")
+		if name == "" || name[0] < 'A' || name[0] > 'Z' {
+			return false
+		}
+		for _, r := range name {
+			if !(r >= 'A' && r <= 'Z') && !isDigit(byte(r)) && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// helpLists reports whether the cached inventory of a help command lists
+// command. known is false when the inventory is not known, e.g. before Help
+// is called.
+func helpLists(inventory []string, command string) (listed, known bool) {
+	if inventory == nil {
+		return false, false
+	}
+	for _, name := range inventory {
+		if name == command {
+			return true, true
+		}
+	}
+	return false, true
+}
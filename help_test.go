@@ -0,0 +1,103 @@
+package ftp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHelp(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		msg  string
+		want []string
+	}{
+		{
+			name: "proftpd",
+			msg: "The following commands are recognized (* =>'s unimplemented):\n" +
+				" CWD     XCWD    CDUP    XCUP    SMNT*   QUIT    PORT    PASV    \n" +
+				" EPRT    EPSV    ALLO*   RNFR    RNTO    DELE    MDTM    RMD     \n" +
+				"Direct comments to root@localhost",
+			want: []string{"CWD", "XCWD", "CDUP", "XCUP", "QUIT", "PORT", "PASV",
+				"EPRT", "EPSV", "RNFR", "RNTO", "DELE", "MDTM", "RMD"},
+		},
+		{
+			name: "vsftpd",
+			msg: "The following commands are recognized.\n" +
+				" ABOR ACCT ALLO APPE CDUP CWD  DELE EPRT EPSV FEAT HELP LIST MDTM MKD\n" +
+				"Help OK.",
+			want: []string{"ABOR", "ACCT", "ALLO", "APPE", "CDUP", "CWD", "DELE",
+				"EPRT", "EPSV", "FEAT", "HELP", "LIST", "MDTM", "MKD"},
+		},
+		{
+			name: "site",
+			msg:  "The following SITE commands are recognized\n CHMOD, UMASK, IDLE, HELP\nEnd",
+			want: []string{"CHMOD", "UMASK", "IDLE", "HELP"},
+		},
+		{
+			name: "no inventory",
+			msg:  "Help is not available.",
+			want: []string{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, parseHelp(test.msg))
+		})
+	}
+}
+
+func TestHelp(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"HELP": "214-The following commands are recognized.\r\n" +
+				" USER PASS RETR STOR XCRC XMD5* SITE\r\n" +
+				"214 Help OK.",
+			"SITE HELP": "214-The following SITE commands are recognized\r\n" +
+				" UMASK IDLE HELP\r\n" +
+				"214 End",
+			"XCRC file": "250 0ABC1234",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	// Not advertised with FEAT
+	_, err := c.Checksum("file", HashCRC32)
+	assert.True(t, errors.Is(err, ErrCommandNotSupported), "%v", err)
+
+	commands, err := c.Help()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"USER", "PASS", "RETR", "STOR", "XCRC", "SITE"}, commands)
+	}
+	_, err = c.Help()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countCommands(s, "HELP"))
+
+	digest, err := c.Checksum("file", HashCRC32)
+	assert.NoError(t, err)
+	assert.Equal(t, "0abc1234", digest)
+	_, err = c.Checksum("file", HashMD5)
+	assert.True(t, errors.Is(err, ErrCommandNotSupported), "%v", err)
+
+	commands, err = c.SiteHelp()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"UMASK", "IDLE", "HELP"}, commands)
+	}
+	err = c.Chmod("file", 0644)
+	assert.True(t, errors.Is(err, ErrCommandNotSupported), "%v", err)
+	assert.NotContains(t, s.Commands(), "SITE CHMOD 644 file")
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestHelpNotSupported(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	_, err := c.Help()
+	assert.True(t, errors.Is(err, ErrCommandNotSupported), "%v", err)
+	assert.NoError(t, c.Quit())
+}
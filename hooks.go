@@ -0,0 +1,91 @@
+package ftp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransferDirection tells whether a transfer is a download or an upload.
+type TransferDirection int
+
+// The directions of the transfers
+const (
+	TransferDownload TransferDirection = iota // Retr and RetrFrom
+	TransferUpload                            // Stor, StorFrom and Append
+)
+
+// String returns "download" or "upload".
+func (d TransferDirection) String() string {
+	if d == TransferUpload {
+		return "upload"
+	}
+	return "download"
+}
+
+// Hooks are callbacks observing the activity of a connection, e.g. to
+// export metrics, set with DialWithHooks. Any of them may be nil.
+//
+// The callbacks are called synchronously by the goroutine using the
+// connection, and must return quickly not to slow it down.
+type Hooks struct {
+	// OnConnect is called once connected to the server at addr, after its
+	// greeting.
+	OnConnect func(addr string)
+
+	// OnDisconnect is called by Quit, with its error, or with the error
+	// matching ErrServerClosed if the server closed the connection.
+	OnDisconnect func(addr string, err error)
+
+	// OnCommand is called after each command and its reply. The password
+	// of PASS and the account of ACCT are replaced with "***". The code is
+	// 0 when no reply could be read.
+	OnCommand func(cmd string, code int, dur time.Duration)
+
+	// OnTransferStart is called when a file transfer starts.
+	OnTransferStart func(direction TransferDirection, path string)
+
+	// OnTransferEnd is called when a file transfer completes or fails, with
+	// the number of bytes transferred.
+	OnTransferEnd func(direction TransferDirection, path string, bytes int64, dur time.Duration, err error)
+}
+
+// DialWithHooks returns a DialOption that calls the callbacks of hooks on
+// the activity of the connection.
+func DialWithHooks(hooks Hooks) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.hooks = &hooks
+	}}
+}
+
+// onCommand calls the OnCommand hook for the command sent at start.
+func (h *Hooks) onCommand(start time.Time, code int, format string, args ...interface{}) {
+	if h.OnCommand == nil {
+		return
+	}
+	h.OnCommand(redactCommand(fmt.Sprintf(format, args...)), code, time.Since(start))
+}
+
+// onTransferStart calls the OnTransferStart hook.
+func (h *Hooks) onTransferStart(direction TransferDirection, path string) {
+	if h.OnTransferStart != nil {
+		h.OnTransferStart(direction, path)
+	}
+}
+
+// onTransferEnd calls the OnTransferEnd hook for the transfer started at
+// start.
+func (h *Hooks) onTransferEnd(direction TransferDirection, path string, bytes int64, start time.Time, err error) {
+	if h.OnTransferEnd != nil {
+		h.OnTransferEnd(direction, path, bytes, time.Since(start), err)
+	}
+}
+
+// redactCommand hides the credentials of a command
+func redactCommand(cmd string) string {
+	verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0])
+	if verb == "PASS" || verb == "ACCT" {
+		return verb + " ***"
+	}
+	return cmd
+}
@@ -0,0 +1,91 @@
+package ftp
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hookTransfer struct {
+	direction TransferDirection
+	path      string
+	bytes     int64
+	err       error
+}
+
+func TestHooks(t *testing.T) {
+	var (
+		connected, disconnected int
+		commands                []string
+		started                 []string
+		transfers               []hookTransfer
+	)
+	hooks := Hooks{
+		OnConnect:    func(addr string) { connected++ },
+		OnDisconnect: func(addr string, err error) { disconnected++ },
+		OnCommand: func(cmd string, code int, dur time.Duration) {
+			commands = append(commands, cmd)
+		},
+		OnTransferStart: func(direction TransferDirection, path string) {
+			started = append(started, direction.String()+" "+path)
+		},
+		OnTransferEnd: func(direction TransferDirection, path string, bytes int64, dur time.Duration, err error) {
+			transfers = append(transfers, hookTransfer{direction, path, bytes, err})
+		},
+	}
+
+	mock, c := openConnExt(t, "127.0.0.1", "no-time", DialWithHooks(hooks))
+	assert.Equal(t, 1, connected)
+	assert.Contains(t, commands, "PASS ***")
+	assert.Contains(t, commands, "USER anonymous")
+
+	assert.NoError(t, c.Stor("file", strings.NewReader(testData)))
+
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		buf, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, string(buf))
+		assert.NoError(t, r.Close())
+	}
+
+	assert.Equal(t, []string{"upload file", "download file"}, started)
+	assert.Equal(t, []hookTransfer{
+		{TransferUpload, "file", int64(len(testData)), nil},
+		{TransferDownload, "file", int64(len(testData)), nil},
+	}, transfers)
+	assert.Contains(t, commands, "STOR file")
+	assert.Contains(t, commands, "RETR file")
+
+	closeConn(t, mock, c, []string{"EPSV", "STOR", "EPSV", "RETR"})
+	assert.Equal(t, 1, disconnected)
+}
+
+func TestHooksFailedTransfer(t *testing.T) {
+	var transfers []hookTransfer
+	hooks := Hooks{
+		OnTransferEnd: func(direction TransferDirection, path string, bytes int64, dur time.Duration, err error) {
+			transfers = append(transfers, hookTransfer{direction, path, bytes, err})
+		},
+	}
+
+	mock, c := openConnScenario(t, "127.0.0.1", "busy", DialWithHooks(hooks))
+
+	_, err := c.Retr("file")
+	assert.Error(t, err)
+	if assert.Len(t, transfers, 1) {
+		assert.Equal(t, TransferDownload, transfers[0].direction)
+		assert.Equal(t, err, transfers[0].err)
+	}
+
+	closeConn(t, mock, c, []string{"EPSV", "RETR"})
+}
+
+func TestRedactCommand(t *testing.T) {
+	assert.Equal(t, "PASS ***", redactCommand("PASS secret"))
+	assert.Equal(t, "ACCT ***", redactCommand("acct ACC123"))
+	assert.Equal(t, "PASSIVE x", redactCommand("PASSIVE x"))
+}
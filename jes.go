@@ -0,0 +1,268 @@
+package ftp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JobStatus is the status of a job of the JES spool of a z/OS server.
+type JobStatus int
+
+// The statuses of JES jobs
+const (
+	JobStatusUnknown JobStatus = iota
+	JobStatusInput             // waiting for execution
+	JobStatusActive            // running
+	JobStatusOutput            // ended, with its output on the spool
+)
+
+// jobStatuses are the statuses of the jobs, as listed
+var jobStatuses = map[string]JobStatus{
+	"INPUT":  JobStatusInput,
+	"ACTIVE": JobStatusActive,
+	"OUTPUT": JobStatusOutput,
+}
+
+// jobIDRegexp matches the ID of a submitted job in the completion reply,
+// e.g. "It is known to JES as JOB12345"
+var jobIDRegexp = regexp.MustCompile(`known to JES as (\S+)`)
+
+// JobEntry describes a job of the JES spool, as listed by ListJobs and Job.
+type JobEntry struct {
+	Name       string
+	ID         string // e.g. "JOB12345"
+	Owner      string
+	Status     JobStatus
+	Class      string
+	Info       string       // remaining columns, such as the return code
+	SpoolFiles []*SpoolFile // only listed by Job
+}
+
+// SpoolFile describes a spool file of the output of a JES job.
+type SpoolFile struct {
+	ID        int
+	StepName  string
+	ProcStep  string
+	Class     string
+	DDName    string
+	ByteCount uint64
+}
+
+// SubmitJob submits the JCL read from jcl to JES, and returns the ID the job
+// is known by.
+func (c *ServerConn) SubmitJob(jcl io.Reader) (jobID string, err error) {
+	err = c.withJES(func() error {
+		msg, err := c.upload("STOR", "JCL", jcl, 0, newTransferOptions([]TransferOption{TransferWithType(TransferTypeASCII)}))
+		if err != nil {
+			return err
+		}
+
+		m := jobIDRegexp.FindStringSubmatch(msg)
+		if m == nil {
+			return fmt.Errorf("no job ID in the reply: %q", msg)
+		}
+		jobID = m[1]
+		return nil
+	})
+	return jobID, err
+}
+
+// ListJobs lists the jobs of the JES spool of owner whose name starts with
+// prefix. Empty values list the jobs of all the owners, or with any name.
+func (c *ServerConn) ListJobs(owner, prefix string) (jobs []*JobEntry, err error) {
+	if owner == "" {
+		owner = "*"
+	}
+	prefix += "*"
+
+	err = c.withJES(func() error {
+		if _, _, err := c.Site(fmt.Sprintf("JESOWNER=%s JESJOBNAME=%s", owner, prefix)); err != nil {
+			return err
+		}
+		jobs, err = c.listJobs("LIST")
+		return err
+	})
+	return jobs, err
+}
+
+// Job returns the JES job with the given ID, along with its spool files.
+func (c *ServerConn) Job(jobID string) (job *JobEntry, err error) {
+	err = c.withJES(func() error {
+		jobs, err := c.listJobs("LIST " + jobID)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return fmt.Errorf("job %s not found", jobID)
+		}
+		job = jobs[0]
+		return nil
+	})
+	return job, err
+}
+
+// RetrieveJobOutput writes the output of a JES job to w: all its spool files
+// when jobID is the ID of the job, or a single one when jobID is followed by
+// its number, e.g. "JOB12345.2".
+func (c *ServerConn) RetrieveJobOutput(jobID string, w io.Writer) error {
+	if !strings.Contains(jobID, ".") {
+		jobID += ".X"
+	}
+
+	return c.withJES(func() error {
+		r, err := c.Retr(jobID, TransferWithType(TransferTypeASCII))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return r.stop(err)
+		}
+		return r.Close()
+	})
+}
+
+// withJES calls fn with the FILETYPE of the server set to JES, and sets it
+// back to SEQ afterwards so that the connection transfers datasets again.
+func (c *ServerConn) withJES(fn func() error) (err error) {
+	if _, _, err := c.Site("FILETYPE=JES"); err != nil {
+		return err
+	}
+	defer func() {
+		if _, _, errSeq := c.Site("FILETYPE=SEQ"); err == nil {
+			err = errSeq
+		}
+	}()
+
+	return fn()
+}
+
+// listJobs issues the listing command cmd in JES mode and parses its output.
+func (c *ServerConn) listJobs(cmd string) ([]*JobEntry, error) {
+	conn, err := c.cmdDataConnFrom(0, "%s", cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Response{conn: conn, c: c}
+
+	var jobs []*JobEntry
+	scanner := bufio.NewScanner(c.options.wrapStream(r))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isJobListNoise(line) {
+			continue
+		}
+
+		var err error
+		if line[0] == ' ' {
+			err = errUnsupportedListLine
+			if len(jobs) > 0 {
+				var file *SpoolFile
+				if file, err = parseSpoolFileLine(line); err == nil {
+					job := jobs[len(jobs)-1]
+					job.SpoolFiles = append(job.SpoolFiles, file)
+				}
+			}
+		} else {
+			var job *JobEntry
+			if job, err = parseJobLine(line); err == nil {
+				jobs = append(jobs, job)
+			}
+		}
+
+		if err != nil && c.options.strictList {
+			return nil, r.stop(&ListLineError{Line: line, Err: err})
+		}
+	}
+
+	return jobs, r.closeScanned(scanner)
+}
+
+// isJobListNoise reports whether line of a JES listing describes neither a
+// job nor a spool file:
+//
+//	JOBNAME  JOBID    OWNER    STATUS CLASS
+//	--------
+//	         ID  STEPNAME PROCSTEP C DDNAME   BYTE-COUNT
+//	3 spool files
+func isJobListNoise(line string) bool {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return true
+	case fields[0] == "JOBNAME" || fields[0] == "ID" || strings.HasPrefix(fields[0], "---"):
+		return true
+	case len(fields) == 3 && fields[1] == "spool" && fields[2] == "files":
+		return true
+	}
+	return false
+}
+
+// parseJobLine parses the line of a job of a JES listing:
+//
+//	IBMUSERA JOB00123 IBMUSER  OUTPUT A        RC=0000 3 spool files
+func parseJobLine(line string) (*JobEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil, errUnsupportedListLine
+	}
+
+	status, ok := jobStatuses[fields[3]]
+	if !ok {
+		return nil, errUnsupportedListLine
+	}
+
+	job := &JobEntry{
+		Name:   fields[0],
+		ID:     fields[1],
+		Owner:  fields[2],
+		Status: status,
+	}
+	if len(fields) > 4 {
+		job.Class = fields[4]
+		job.Info = strings.Join(fields[5:], " ")
+	}
+	return job, nil
+}
+
+// parseSpoolFileLine parses the line of a spool file of a JES listing, whose
+// procedure step is optional:
+//
+//	001 JESE              H JESMSGLG      1200
+//	004 STEP1    PROC1    A SYSPRINT       300
+func parseSpoolFileLine(line string) (*SpoolFile, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 && len(fields) != 6 {
+		return nil, errUnsupportedListLine
+	}
+
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseUint(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &SpoolFile{
+		ID:        id,
+		StepName:  fields[1],
+		ByteCount: count,
+	}
+	if len(fields) == 6 {
+		file.ProcStep = fields[2]
+	}
+	file.Class = fields[len(fields)-3]
+	file.DDName = fields[len(fields)-2]
+
+	if len(file.Class) != 1 {
+		return nil, errors.New("invalid spool file class: " + file.Class)
+	}
+	return file, nil
+}
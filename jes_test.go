@@ -0,0 +1,84 @@
+package ftp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitJob(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	jcl := "//IBMUSERA JOB\n//STEP1 EXEC PGM=IEFBR14\n"
+	jobID, err := c.SubmitJob(strings.NewReader(jcl))
+	assert.NoError(t, err)
+	assert.Equal(t, "JOB00123", jobID)
+	assert.Equal(t, "SITE FILETYPE=SEQ", mock.lastFull)
+
+	var buf bytes.Buffer
+	err = c.RetrieveJobOutput(jobID, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, jcl, buf.String())
+	assert.False(t, mock.jes)
+
+	err = c.RetrieveJobOutput("JOB00123.2", &buf)
+	assert.NoError(t, err)
+
+	closeConn(t, mock, c, []string{
+		"SITE", "TYPE", "EPSV", "STOR", "SITE",
+		"SITE", "EPSV", "RETR", "SITE",
+		"SITE", "EPSV", "RETR", "SITE",
+	})
+}
+
+func TestListJobs(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	jobs, err := c.ListJobs("", "IBMUSER")
+	assert.NoError(t, err)
+	if assert.Len(t, jobs, 3) {
+		assert.Equal(t, &JobEntry{
+			Name:   "IBMUSERA",
+			ID:     "JOB00123",
+			Owner:  "IBMUSER",
+			Status: JobStatusOutput,
+			Class:  "A",
+			Info:   "RC=0000 3 spool files",
+		}, jobs[0])
+		assert.Equal(t, JobStatusActive, jobs[1].Status)
+		assert.Equal(t, JobStatusInput, jobs[2].Status)
+	}
+
+	job, err := c.Job("JOB00123")
+	assert.NoError(t, err)
+	if assert.Len(t, job.SpoolFiles, 3) {
+		assert.Equal(t, &SpoolFile{ID: 1, StepName: "JESE", Class: "H", DDName: "JESMSGLG", ByteCount: 1200}, job.SpoolFiles[0])
+		assert.Equal(t, &SpoolFile{ID: 3, StepName: "STEP1", ProcStep: "PROC1", Class: "A", DDName: "SYSPRINT", ByteCount: 300}, job.SpoolFiles[2])
+	}
+
+	closeConn(t, mock, c, []string{
+		"SITE", "SITE", "EPSV", "LIST", "SITE",
+		"SITE", "EPSV", "LIST", "SITE",
+	})
+}
+
+func TestParseJobLine(t *testing.T) {
+	for _, line := range []string{
+		"IBMUSERA JOB00123 IBMUSER",
+		"IBMUSERA JOB00123 IBMUSER  UNKNOWN A",
+	} {
+		_, err := parseJobLine(line)
+		assert.Error(t, err, line)
+	}
+
+	for _, line := range []string{
+		"         001 JESE",
+		"         xxx JESE              H JESMSGLG      1200",
+		"         001 JESE              HH JESMSGLG     1200",
+	} {
+		_, err := parseSpoolFileLine(line)
+		assert.Error(t, err, line)
+	}
+}
@@ -0,0 +1,114 @@
+package ftp
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// entryJSON is the JSON schema of Entry
+type entryJSON struct {
+	Name           string            `json:"name"`
+	Type           EntryType         `json:"type"`
+	Size           uint64            `json:"size"`
+	Target         string            `json:"target,omitempty"`
+	Time           *time.Time        `json:"time,omitempty"`
+	TimeResolution time.Duration     `json:"time_resolution,omitempty"` // in nanoseconds
+	Owner          string            `json:"owner,omitempty"`
+	Group          string            `json:"group,omitempty"`
+	Permissions    string            `json:"permissions,omitempty"`
+	UnixMode       string            `json:"unix_mode,omitempty"` // octal, e.g. "0755"
+	Pseudo         bool              `json:"pseudo,omitempty"`
+	CreateTime     *time.Time        `json:"create_time,omitempty"`
+	Perm           string            `json:"perm,omitempty"`
+	Unique         string            `json:"unique,omitempty"`
+	Facts          map[string]string `json:"facts,omitempty"`
+	Major          uint32            `json:"major,omitempty"`
+	Minor          uint32            `json:"minor,omitempty"`
+	Raw            string            `json:"raw,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler with a stable schema, e.g.:
+//
+//	{"name":"a.txt","type":"file","size":42,"time":"2024-01-02T15:04:05Z",
+//	 "time_resolution":60000000000,"owner":"ftp","group":"ftp",
+//	 "permissions":"-rw-r--r--","raw":"-rw-r--r-- 1 ftp ftp 42 Jan 2 15:04 a.txt"}
+//
+// The type is the one of EntryType.String, and the times are in UTC,
+// formatted as described by RFC 3339. The time resolution is in nanoseconds.
+// The UNIX.mode MLSD fact is encoded as "unix_mode", in octal, and the other
+// facts as "create_time", "perm", "unique" and "facts". The device numbers
+// are "major" and "minor". The fields other than name, type and size are
+// omitted when they are empty.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	v := entryJSON{
+		Name:           e.Name,
+		Type:           e.Type,
+		Size:           e.Size,
+		Target:         e.Target,
+		TimeResolution: e.TimeResolution,
+		Owner:          e.Owner,
+		Group:          e.Group,
+		Permissions:    e.Permissions,
+		Pseudo:         e.Pseudo,
+		Perm:           e.Perm,
+		Unique:         e.Unique,
+		Facts:          e.Facts,
+		Major:          e.Major,
+		Minor:          e.Minor,
+		Raw:            e.Raw,
+	}
+	if !e.Time.IsZero() {
+		t := e.Time.UTC()
+		v.Time = &t
+	}
+	if !e.CreateTime.IsZero() {
+		t := e.CreateTime.UTC()
+		v.CreateTime = &t
+	}
+	if e.hasUnixMode {
+		v.UnixMode = formatUnixMode(e.unixMode)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, with the schema of MarshalJSON.
+// The times are in UTC.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var v entryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*e = Entry{
+		Name:           v.Name,
+		Type:           v.Type,
+		Size:           v.Size,
+		Target:         v.Target,
+		TimeResolution: v.TimeResolution,
+		Owner:          v.Owner,
+		Group:          v.Group,
+		Permissions:    v.Permissions,
+		Pseudo:         v.Pseudo,
+		Perm:           v.Perm,
+		Unique:         v.Unique,
+		Facts:          v.Facts,
+		Major:          v.Major,
+		Minor:          v.Minor,
+		Raw:            v.Raw,
+	}
+	if v.Time != nil {
+		e.Time = v.Time.UTC()
+	}
+	if v.CreateTime != nil {
+		e.CreateTime = v.CreateTime.UTC()
+	}
+	if v.UnixMode != "" {
+		mode, err := parseUnixMode(v.UnixMode)
+		if err != nil {
+			return err
+		}
+		e.unixMode = mode
+		e.hasUnixMode = true
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package ftp
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryJSON(t *testing.T) {
+	paris := time.FixedZone("CET", 3600)
+	entries := []*Entry{
+		{
+			Name:        "a.txt",
+			Type:        EntryTypeFile,
+			Size:        42,
+			Time:        time.Date(2024, 1, 2, 16, 4, 5, 0, paris),
+			Owner:       "ftp",
+			Group:       "users",
+			Permissions: "-rw-r--r--",
+			Raw:         "-rw-r--r-- 1 ftp users 42 Jan 2 16:04 a.txt",
+		},
+		{Name: "b", Type: EntryTypeLink, Target: "a.txt"},
+	}
+
+	data, err := json.Marshal(entries)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `[
+			{"name":"a.txt","type":"file","size":42,"time":"2024-01-02T15:04:05Z",
+			 "owner":"ftp","group":"users","permissions":"-rw-r--r--",
+			 "raw":"-rw-r--r-- 1 ftp users 42 Jan 2 16:04 a.txt"},
+			{"name":"b","type":"link","size":0,"target":"a.txt"}
+		]`, string(data))
+	}
+
+	var decoded []*Entry
+	if assert.NoError(t, json.Unmarshal(data, &decoded)) && assert.Len(t, decoded, 2) {
+		assert.Equal(t, "a.txt", decoded[0].Name)
+		assert.Equal(t, EntryTypeFile, decoded[0].Type)
+		assert.True(t, entries[0].Time.Equal(decoded[0].Time))
+		assert.Equal(t, time.UTC, decoded[0].Time.Location())
+		assert.Equal(t, "-rw-r--r--", decoded[0].Permissions)
+		assert.Equal(t, entries[0].Raw, decoded[0].Raw)
+		assert.Equal(t, *entries[1], *decoded[1])
+	}
+
+	// Values are encoded the same
+	data, err = json.Marshal(*entries[1])
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"name":"b","type":"link","size":0,"target":"a.txt"}`, string(data))
+	}
+
+	assert.Error(t, json.Unmarshal([]byte(`{"name":"c","type":"bogus"}`), &Entry{}))
+}
+
+func TestEntryJSONFacts(t *testing.T) {
+	entry, err := parseRFC3659ListLine("modify=20240102150405;create=20230101000000;perm=adfr;"+
+		"type=file;size=42;unique=801U5;UNIX.mode=4755;x.hash=abc; a.out", time.Now(), time.UTC)
+	if !assert.NoError(t, err) {
+		return
+	}
+	device := &Entry{Name: "tty", Type: EntryTypeDevice, Major: 4, Minor: 1, Pseudo: true}
+
+	data, err := json.Marshal([]*Entry{entry, device})
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `[
+			{"name":"a.out","type":"file","size":42,"time":"2024-01-02T15:04:05Z",
+			 "time_resolution":1000000000,"unix_mode":"4755",
+			 "create_time":"2023-01-01T00:00:00Z","perm":"adfr","unique":"801U5",
+			 "facts":{"x.hash":"abc"}},
+			{"name":"tty","type":"device","size":0,"pseudo":true,"major":4,"minor":1}
+		]`, string(data))
+	}
+
+	var decoded []*Entry
+	if assert.NoError(t, json.Unmarshal(data, &decoded)) && assert.Len(t, decoded, 2) {
+		assert.Equal(t, *entry, *decoded[0])
+		assert.Equal(t, *device, *decoded[1])
+		assert.True(t, decoded[0].HasMode())
+		assert.Equal(t, os.FileMode(0755)|os.ModeSetuid, decoded[0].Mode())
+	}
+}
+
+func TestDataSetEntryJSON(t *testing.T) {
+	referenced := time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC)
+	entries := []DataSetEntry{
+		{
+			Name:         "HLQ.DATA",
+			Volume:       "VOL001",
+			Unit:         "3390",
+			Time:         &referenced,
+			Extents:      1,
+			Used:         15,
+			RecordFormat: RecordFormatFB,
+			RecordLength: 80,
+			BlockSize:    27920,
+			Organization: Partitioned,
+		},
+		{Name: "HLQ.OLD", Status: DataSetStatusMigrated},
+	}
+
+	data, err := json.Marshal(entries)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `[
+			{"name":"HLQ.DATA","volume":"VOL001","unit":"3390","time":"2023-11-20T00:00:00Z",
+			 "extents":1,"used":15,"used_unknown":false,"record_format":"FB","record_length":80,
+			 "block_size":27920,"organization":"PO","status":"online","gdg_base":false},
+			{"name":"HLQ.OLD","volume":"","unit":"","time":null,"extents":0,"used":0,
+			 "used_unknown":false,"record_format":"","record_length":0,"block_size":0,
+			 "organization":"","status":"migrated","gdg_base":false}
+		]`, string(data))
+	}
+
+	var decoded []DataSetEntry
+	if assert.NoError(t, json.Unmarshal(data, &decoded)) {
+		assert.Equal(t, entries, decoded)
+	}
+	// Like EntryType, an invalid status is encoded anyway
+	data, err = json.Marshal(DataSetStatus(42))
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"unknown(42)"`, string(data))
+	}
+}
@@ -0,0 +1,99 @@
+package ftp
+
+import (
+	"time"
+)
+
+// pingTimeout is how long Ping waits for the reply of the server
+const pingTimeout = 5 * time.Second
+
+// DialWithKeepAlive returns a DialOption that sends a NOOP command whenever
+// the connection has been idle for interval, so that the server does not
+// drop it, e.g. while it waits in a pool.
+//
+// The command is only sent while no operation is running: the keepalive
+// never interleaves with the commands of the connection, and pauses during
+// the transfers. It stops on Quit, or at the first NOOP which fails.
+func DialWithKeepAlive(interval time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.keepAlive = interval
+	}}
+}
+
+// Ping sends a NOOP command to check that the control connection is still
+// usable, e.g. before reusing a connection which was idle for a while. It
+// fails if the server does not reply within 5 seconds, and with
+// ErrConcurrentUse while the connection is busy.
+func (c *ServerConn) Ping() error {
+	if err := c.acquire(); err != nil {
+		return err
+	}
+	defer c.release()
+
+	return c.ping()
+}
+
+// ping sends a NOOP command with a short deadline, the connection being
+// busy.
+func (c *ServerConn) ping() error {
+	if err := c.netConn.SetDeadline(time.Now().Add(pingTimeout)); err != nil {
+		return err
+	}
+	_, _, err := c.exchange(StatusCommandOK, "NOOP")
+	if errDeadline := c.netConn.SetDeadline(time.Time{}); err == nil {
+		err = errDeadline
+	}
+	return err
+}
+
+// startKeepAlive starts the goroutine sending NOOP commands when the
+// connection is idle for interval.
+func (c *ServerConn) startKeepAlive(interval time.Duration) {
+	c.keepAliveStop = make(chan struct{})
+	c.keepAliveDone = make(chan struct{})
+	go c.keepAlive(interval)
+}
+
+// stopKeepAlive stops the goroutine started by startKeepAlive, if any, and
+// waits for the NOOP command it may be sending.
+func (c *ServerConn) stopKeepAlive() {
+	if c.keepAliveStop == nil {
+		return
+	}
+	close(c.keepAliveStop)
+	<-c.keepAliveDone
+}
+
+func (c *ServerConn) keepAlive(interval time.Duration) {
+	defer close(c.keepAliveDone)
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-c.keepAliveStop:
+			return
+		}
+
+		// The connection is only used when it is available: a busy one is
+		// checked again after interval, which pauses during the transfers
+		next := interval
+		select {
+		case c.busy <- struct{}{}:
+			if idle := time.Since(c.lastUsed); idle < interval {
+				next = interval - idle
+				<-c.busy
+			} else {
+				err := c.ping()
+				c.release()
+				if err != nil {
+					return
+				}
+			}
+		default:
+		}
+		timer.Reset(next)
+	}
+}
@@ -0,0 +1,59 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, 1, countCommands(s, "NOOP"))
+
+	s.Close()
+	assert.Error(t, c.Ping())
+
+	// The server is gone, so QUIT fails
+	_ = c.Quit()
+	assert.True(t, errors.Is(c.Ping(), net.ErrClosed))
+}
+
+func TestKeepAlive(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{
+			"/file": []byte("hello"),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s, DialWithKeepAlive(10*time.Millisecond))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.NotZero(t, countCommands(s, "NOOP"))
+
+	// No NOOP is sent while the transfer is in progress
+	r, err := c.Retr("/file")
+	if assert.NoError(t, err) {
+		before := countCommands(s, "NOOP")
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, before, countCommands(s, "NOOP"))
+
+		_, err = io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+	}
+
+	// The keepalive is stopped by Quit
+	assert.NoError(t, c.Quit())
+	after := countCommands(s, "NOOP")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, after, countCommands(s, "NOOP"))
+}
@@ -0,0 +1,77 @@
+package ftp
+
+import (
+	"errors"
+	"net/textproto"
+	"strings"
+)
+
+// defaultLanguage is the language of the replies selected on login
+const defaultLanguage = "en"
+
+// DialWithLanguage returns a DialOption that sets the language of the replies
+// selected with a LANG command on login, as described by RFC 2640, when the
+// server advertises LANG. It is "en" by default, so that the messages of the
+// replies, e.g. of the errors, are predictable, and an empty lang does not
+// send LANG. The replies are decoded with the encoding of DialWithEncoding in
+// any case.
+func DialWithLanguage(lang string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.language = lang
+		do.disableLANG = lang == ""
+	}}
+}
+
+// parseLanguages parses the languages advertised with FEAT, such as
+// "EN*;FR", into their tags in lower case, and the current one, marked with
+// a star.
+func parseLanguages(list string) (langs []string, current string) {
+	for _, lang := range strings.Split(list, ";") {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" {
+			continue
+		}
+		if strings.HasSuffix(lang, "*") {
+			lang = strings.TrimSuffix(lang, "*")
+			current = lang
+		}
+		langs = append(langs, lang)
+	}
+	return langs, current
+}
+
+// setLanguage selects the language of the replies with a LANG command, unless
+// it is the current one already. The refusal of the command is not an error:
+// the current language is kept.
+func (c *ServerConn) setLanguage() error {
+	list, ok := c.features["LANG"]
+	if !ok {
+		return nil
+	}
+	_, c.language = parseLanguages(list)
+
+	lang := c.options.language
+	if lang == "" {
+		lang = defaultLanguage
+	}
+	if c.options.disableLANG || strings.EqualFold(lang, c.language) {
+		return nil
+	}
+
+	if _, _, err := c.cmd(StatusCommandOK, "LANG %s", lang); err != nil {
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) {
+			return nil
+		}
+		return err
+	}
+	c.language = strings.ToLower(lang)
+	return nil
+}
+
+// Language returns the language of the replies, in lower case, e.g. "en",
+// see DialWithLanguage. It is empty when the server does not support LANG,
+// or does not tell its current language.
+func (c *ServerConn) Language() string {
+	return c.language
+}
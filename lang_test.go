@@ -0,0 +1,101 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestSetLanguage(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		langs    string // advertised with FEAT
+		option   []DialOption
+		reply    string // to LANG
+		command  string // LANG sent, if any
+		language string
+	}{
+		{
+			name:     "default",
+			langs:    "FR*;EN",
+			command:  "LANG en",
+			language: "en",
+		},
+		{
+			name:     "current",
+			langs:    "EN*;FR",
+			language: "en",
+		},
+		{
+			name:     "configured",
+			langs:    "EN*;FR",
+			option:   []DialOption{DialWithLanguage("fr")},
+			command:  "LANG fr",
+			language: "fr",
+		},
+		{
+			name:     "disabled",
+			langs:    "FR*;EN",
+			option:   []DialOption{DialWithLanguage("")},
+			language: "fr",
+		},
+		{
+			name:     "refused",
+			langs:    "FR*;DE",
+			reply:    "504 Unsupported language",
+			command:  "LANG en",
+			language: "fr",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			replies := map[string]string{
+				"FEAT": "211-Features:\r\n LANG " + test.langs + "\r\n211 End",
+				"LANG": "200 Language changed",
+			}
+			if test.reply != "" {
+				replies["LANG"] = test.reply
+			}
+			s := ftptest.NewServer(ftptest.Script{Replies: replies})
+			defer s.Close()
+			c := dialScript(t, s, test.option...)
+
+			if test.command != "" {
+				assert.Contains(t, s.Commands(), test.command)
+			} else {
+				assert.Zero(t, countCommands(s, "LANG"))
+			}
+			assert.Equal(t, test.language, c.Language())
+			assert.NoError(t, c.Quit())
+		})
+	}
+}
+
+func TestLanguageNotSupported(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	assert.Zero(t, countCommands(s, "LANG"))
+	assert.Empty(t, c.Language())
+	assert.NoError(t, c.Quit())
+}
+
+func TestLocalizedReplies(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT":     "211-Features:\r\n LANG FR*\r\n211 End",
+			"LANG":     "504 Langue non support\xe9e",
+			"DELE /ro": "550 Fichier prot\xe9g\xe9",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s, DialWithEncoding(charmap.ISO8859_1))
+
+	err := c.Delete("/ro")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Fichier protégé")
+	}
+	assert.NoError(t, c.Quit())
+}
@@ -0,0 +1,116 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// maxLinkHops is how many symbolic links ResolveLink follows, as many as
+// Linux does
+const maxLinkHops = 40
+
+// ErrLinkLoop is matched by the error of ResolveLink when a chain of links
+// is too long to be resolved, as for a cycle.
+var ErrLinkLoop = errors.New("too many levels of symbolic links")
+
+// LinkError is returned by ResolveLink when a symbolic link can not be
+// resolved. It matches fs.ErrNotExist with errors.Is for a dangling link,
+// and ErrLinkLoop for a cycle.
+type LinkError struct {
+	Link   string // path of the link
+	Target string // path of the last target followed
+	Err    error
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("resolve link %s to %s: %s", e.Link, e.Target, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+// ResolveLink returns the entry of the file or directory the symbolic link
+// e of the listing of dir points at, following the chains of links. Entries
+// which are not links are returned as is.
+//
+// The target is looked up with an MLST command when the server supports it,
+// or else with SIZE for a file and by changing the working directory for a
+// directory, restoring it afterwards: the returned entry then only has its
+// Type, and its Size for a file. Its Name is the one of the target, and its
+// Target the path the link resolves to.
+func (c *ServerConn) ResolveLink(dir string, e *Entry) (*Entry, error) {
+	if e.Type != EntryTypeLink {
+		return e, nil
+	}
+
+	link := path.Join(dir, e.Name)
+	target := link
+	entry := e
+	for hops := 0; entry.Type == EntryTypeLink; hops++ {
+		if hops == maxLinkHops {
+			return nil, &LinkError{Link: link, Target: target, Err: ErrLinkLoop}
+		}
+
+		// Without a target, the lookup is left to the server, which
+		// follows the links
+		if entry.Target != "" {
+			target = linkTarget(path.Dir(target), entry.Target)
+		}
+
+		var err error
+		entry, err = c.statTarget(target, entry.Target != "")
+		if err != nil {
+			return nil, &LinkError{Link: link, Target: target, Err: err}
+		}
+	}
+
+	entry.Name = path.Base(target)
+	entry.Target = target
+	return entry, nil
+}
+
+// linkTarget returns the path of the target of a link in dir
+func linkTarget(dir, target string) string {
+	if path.IsAbs(target) {
+		return path.Clean(target)
+	}
+	return path.Join(dir, target)
+}
+
+// statTarget returns the entry of the target of a link, which may be a link
+// itself if mlst is true and the server supports MLST. It fails with
+// fs.ErrNotExist if there is none.
+func (c *ServerConn) statTarget(p string, mlst bool) (*Entry, error) {
+	if mlst && c.mlstSupported {
+		entry, err := c.mlst(p)
+		if err == nil {
+			return entry, nil
+		}
+		if isNotFound(err) {
+			return nil, fs.ErrNotExist
+		}
+		return nil, err
+	}
+
+	if _, ok := c.features["SIZE"]; ok {
+		size, err := c.FileSize(p)
+		if err == nil {
+			return &Entry{Type: EntryTypeFile, Size: uint64(size)}, nil
+		}
+		if err := refusedProbe(err); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.PushDir(p); err != nil {
+		if err := refusedProbe(err); err != nil {
+			return nil, err
+		}
+		return nil, fs.ErrNotExist
+	}
+	return &Entry{Type: EntryTypeFolder}, c.PopDir()
+}
@@ -0,0 +1,86 @@
+package ftp
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveLink(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT":      "211-Features:\r\n EPSV\r\n SIZE\r\n211 End",
+			"CWD /gone": "550 No such file or directory",
+		},
+		Files: map[string][]byte{
+			"/data/file": []byte(testData),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	entry, err := c.ResolveLink("/dir", &Entry{Name: "file", Type: EntryTypeLink, Target: "../data/file"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "file", entry.Name)
+		assert.Equal(t, "/data/file", entry.Target)
+		assert.Equal(t, EntryTypeFile, entry.Type)
+		assert.Equal(t, uint64(len(testData)), entry.Size)
+	}
+
+	entry, err = c.ResolveLink("/dir", &Entry{Name: "sub", Type: EntryTypeLink, Target: "/data"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/data", entry.Target)
+		assert.Equal(t, EntryTypeFolder, entry.Type)
+	}
+
+	_, err = c.ResolveLink("/dir", &Entry{Name: "dangling", Type: EntryTypeLink, Target: "/gone"})
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	var linkErr *LinkError
+	if assert.True(t, errors.As(err, &linkErr)) {
+		assert.Equal(t, "/dir/dangling", linkErr.Link)
+		assert.Equal(t, "/gone", linkErr.Target)
+	}
+
+	// Entries which are not links are returned as is
+	file := &Entry{Name: "file", Type: EntryTypeFile}
+	entry, err = c.ResolveLink("/dir", file)
+	assert.NoError(t, err)
+	assert.Equal(t, file, entry)
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestResolveLinkMLST(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT":            "211-Features:\r\n EPSV\r\n MLST type*;size*;modify*;\r\n211 End",
+			"MLST /dir/a":     "250-Listing\r\n type=OS.unix=slink:b; /dir/a\r\n250 End",
+			"MLST /dir/b":     "250-Listing\r\n type=OS.unix=slink:../dir/a; /dir/b\r\n250 End",
+			"MLST /dir/c":     "250-Listing\r\n type=OS.unix=slink:/data/file; /dir/c\r\n250 End",
+			"MLST /data/file": "250-Listing\r\n type=file;size=14; /data/file\r\n250 End",
+			"MLST /dir/gone":  "550 No such file or directory",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	// Chains of links are followed
+	entry, err := c.ResolveLink("/dir", &Entry{Name: "link", Type: EntryTypeLink, Target: "c"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/data/file", entry.Target)
+		assert.Equal(t, EntryTypeFile, entry.Type)
+		assert.Equal(t, uint64(14), entry.Size)
+	}
+
+	_, err = c.ResolveLink("/dir", &Entry{Name: "a", Type: EntryTypeLink, Target: "b"})
+	assert.True(t, errors.Is(err, ErrLinkLoop))
+	assert.Equal(t, maxLinkHops+2, countCommands(s, "MLST"))
+
+	_, err = c.ResolveLink("/dir", &Entry{Name: "missing", Type: EntryTypeLink, Target: "gone"})
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	assert.NoError(t, c.Quit())
+}
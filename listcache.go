@@ -0,0 +1,163 @@
+package ftp
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listCache holds the listings of ListCached, by absolute path
+type listCache struct {
+	mu       sync.Mutex
+	disabled bool
+	listings map[string]cachedListing
+}
+
+// cachedListing is a listing of ListCached, and when it was made
+type cachedListing struct {
+	entries []*Entry
+	time    time.Time
+}
+
+// ListCached returns the listing of path like List, from memory if it was
+// listed less than maxAge ago by the same connection. Otherwise, or if
+// maxAge is zero, the directory is listed again and the listing kept.
+//
+// The listing of a directory is dropped when Stor, Append, Delete, Rename,
+// MakeDir or RemoveDir are issued on its entries through the connection,
+// and with InvalidateCache. Renaming or removing a directory also drops the
+// listings of the directories below it. When such a change is made to a
+// relative path while the working directory is unknown, all the listings
+// are dropped. The changes made otherwise are only seen once the listing is
+// older than maxAge. The entries are shared between the calls, and must not
+// be modified.
+//
+// Relative paths are only cached while the working directory is known, see
+// CachedCurrentDir.
+func (c *ServerConn) ListCached(path string, maxAge time.Duration) ([]*Entry, error) {
+	key := c.cacheKey(path)
+	if key == "" {
+		return c.List(path)
+	}
+
+	if maxAge > 0 {
+		if entries, ok := c.listCache.get(key, maxAge); ok {
+			return entries, nil
+		}
+	}
+
+	start := time.Now()
+	entries, err := c.List(path)
+	if err != nil {
+		return nil, err
+	}
+	c.listCache.put(key, entries, start)
+	return entries, nil
+}
+
+// InvalidateCache drops the listing of path kept by ListCached, if any. All
+// the listings are dropped if path is relative and the working directory is
+// unknown.
+func (c *ServerConn) InvalidateCache(path string) {
+	if key := c.cacheKey(path); key != "" {
+		c.listCache.drop(key)
+	} else {
+		c.listCache.dropAll()
+	}
+}
+
+// SetListCache enables or disables the listing cache of ListCached, which is
+// enabled by default. Disabling it drops all the listings, and ListCached
+// then always lists the directories.
+func (c *ServerConn) SetListCache(enabled bool) {
+	c.listCache.mu.Lock()
+	defer c.listCache.mu.Unlock()
+
+	c.listCache.disabled = !enabled
+	if !enabled {
+		c.listCache.listings = nil
+	}
+}
+
+// cacheKey returns the absolute path of path, or an empty string if it can
+// not be told.
+func (c *ServerConn) cacheKey(p string) string {
+	switch {
+	case path.IsAbs(p):
+		return path.Clean(p)
+	case c.cwd != "" && path.IsAbs(c.cwd):
+		return path.Join(c.cwd, p)
+	}
+	return ""
+}
+
+// invalidateParent drops the listing of the directory holding path, after
+// it was changed.
+func (c *ServerConn) invalidateParent(p string) {
+	if key := c.cacheKey(p); key != "" {
+		c.listCache.drop(path.Dir(key))
+	} else {
+		c.listCache.dropAll()
+	}
+}
+
+// invalidateTree drops the listings of the directory path and of the
+// directories below it, after it was renamed or removed.
+func (c *ServerConn) invalidateTree(p string) {
+	if key := c.cacheKey(p); key != "" {
+		c.listCache.dropTree(key)
+	} else {
+		c.listCache.dropAll()
+	}
+}
+
+func (lc *listCache) get(key string, maxAge time.Duration) ([]*Entry, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	listing, ok := lc.listings[key]
+	if !ok || time.Since(listing.time) >= maxAge {
+		return nil, false
+	}
+	return listing.entries, true
+}
+
+func (lc *listCache) put(key string, entries []*Entry, t time.Time) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.disabled {
+		return
+	}
+	if lc.listings == nil {
+		lc.listings = make(map[string]cachedListing)
+	}
+	lc.listings[key] = cachedListing{entries: entries, time: t}
+}
+
+func (lc *listCache) drop(key string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	delete(lc.listings, key)
+}
+
+func (lc *listCache) dropTree(key string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	for k := range lc.listings {
+		if k == key || strings.HasPrefix(k, prefix) {
+			delete(lc.listings, k)
+		}
+	}
+}
+
+func (lc *listCache) dropAll() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.listings = nil
+}
@@ -0,0 +1,106 @@
+package ftp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListCached(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Listings: map[string]string{
+			"/dir": "-rw-r--r-- 1 user group 1 Jan 01  2020 a\r\n",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	list := func(path string, maxAge time.Duration) {
+		entries, err := c.ListCached(path, maxAge)
+		if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+			assert.Equal(t, "a", entries[0].Name)
+		}
+	}
+
+	list("/dir", time.Hour)
+	list("/dir/", time.Hour)
+	assert.Equal(t, 1, countCommands(s, "LIST"))
+
+	// The cache is never consulted without a maximum age
+	list("/dir", 0)
+	assert.Equal(t, 2, countCommands(s, "LIST"))
+
+	// Changes through the connection drop the listing
+	assert.NoError(t, c.Stor("/dir/b", bytes.NewBufferString(testData)))
+	list("/dir", time.Hour)
+	assert.Equal(t, 3, countCommands(s, "LIST"))
+	assert.NoError(t, c.Stor("/other/b", bytes.NewBufferString(testData)))
+	list("/dir", time.Hour)
+	assert.Equal(t, 3, countCommands(s, "LIST"))
+	assert.NoError(t, c.Rename("/other/b", "/dir/c"))
+	list("/dir", time.Hour)
+	assert.Equal(t, 4, countCommands(s, "LIST"))
+	assert.NoError(t, c.Delete("/dir/c"))
+	list("/dir", time.Hour)
+	assert.Equal(t, 5, countCommands(s, "LIST"))
+
+	// Relative paths are resolved from the working directory
+	assert.NoError(t, c.ChangeDir("/dir"))
+	assert.NoError(t, c.MakeDir("sub"))
+	list("/dir", time.Hour)
+	assert.Equal(t, 6, countCommands(s, "LIST"))
+
+	c.InvalidateCache("/dir")
+	list("/dir", time.Hour)
+	assert.Equal(t, 7, countCommands(s, "LIST"))
+
+	c.SetListCache(false)
+	list("/dir", time.Hour)
+	list("/dir", time.Hour)
+	assert.Equal(t, 9, countCommands(s, "LIST"))
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestListCachedTree(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"RNFR": "350 Ready for destination name",
+			"RNTO": "250 Rename successful",
+			"RMD":  "250 Directory removed",
+			"DELE": "250 File deleted",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	list := func(paths ...string) {
+		for _, p := range paths {
+			_, err := c.ListCached(p, time.Hour)
+			assert.NoError(t, err)
+		}
+	}
+
+	list("/dir", "/dir/sub", "/dir/sub/deep", "/dirty")
+	assert.Equal(t, 4, countCommands(s, "LIST"))
+
+	// The directories below a renamed or removed one are dropped too
+	assert.NoError(t, c.Rename("/dir/sub", "/moved"))
+	list("/dir", "/dir/sub", "/dir/sub/deep", "/dirty")
+	assert.Equal(t, 7, countCommands(s, "LIST"))
+	assert.NoError(t, c.RemoveDir("/dir"))
+	list("/dir", "/dir/sub", "/dir/sub/deep", "/dirty")
+	assert.Equal(t, 10, countCommands(s, "LIST"))
+
+	// Without a known working directory, relative paths drop everything
+	_, _, err := c.Cmd(StatusCommandOK, "NOOP")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Delete("file"))
+	list("/dir", "/dirty")
+	assert.Equal(t, 12, countCommands(s, "LIST"))
+
+	assert.NoError(t, c.Quit())
+}
@@ -0,0 +1,93 @@
+package ftp
+
+import (
+	"compress/zlib"
+	"io"
+	"net"
+)
+
+// DialWithModeZ returns a DialOption that enables compressed transfers with
+// MODE Z, when the server advertises it in FEAT. The data of listings,
+// downloads and uploads is then deflated on the data connections, which is
+// transparent for the callers.
+//
+// If the server rejects the MODE Z command, the connection stays in the
+// default stream mode.
+func DialWithModeZ(enabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.modeZ = enabled
+	}}
+}
+
+// DialWithModeZLevel returns a DialOption that sets the compression level of
+// MODE Z transfers, from 1 (best speed) to 9 (best compression), with an
+// OPTS MODE Z LEVEL command. It implies DialWithModeZ(true). Zero keeps the
+// default level of the server.
+func DialWithModeZLevel(level int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.modeZ = true
+		do.modeZLevel = level
+	}}
+}
+
+// setModeZ switches the transfer mode to MODE Z if the server supports it.
+// A rejected level or mode is not an error: the server keeps its current
+// settings.
+func (c *ServerConn) setModeZ() error {
+	if !c.modeZSupported() {
+		return nil
+	}
+
+	if c.options.modeZLevel != 0 {
+		if _, _, err := c.cmd(-1, "OPTS MODE Z LEVEL %d", c.options.modeZLevel); err != nil {
+			return err
+		}
+	}
+
+	code, _, err := c.cmd(-1, "MODE Z")
+	if err != nil {
+		return err
+	}
+	c.modeZ = code == StatusCommandOK
+	return nil
+}
+
+// modeZSupported reports whether FEAT advertises MODE Z
+func (c *ServerConn) modeZSupported() bool {
+	mode, ok := c.features["MODE"]
+	return ok && mode == "Z"
+}
+
+// compressLevel returns the zlib level matching the MODE Z level option
+func (o *dialOptions) compressLevel() int {
+	if o.modeZLevel < zlib.BestSpeed || o.modeZLevel > zlib.BestCompression {
+		return zlib.DefaultCompression
+	}
+	return o.modeZLevel
+}
+
+// inflateConn decompresses what is read from a MODE Z data connection.
+// Writes are not compressed: uploads wrap it in a zlib.Writer themselves,
+// which must be closed before the connection to flush the last block.
+type inflateConn struct {
+	net.Conn
+	r io.ReadCloser
+}
+
+func (c *inflateConn) Read(buf []byte) (int, error) {
+	if c.r == nil {
+		// The zlib header is read from the connection right away, so the
+		// reader is only created on the first read.
+		counter := &countingReader{r: c.Conn}
+		r, err := zlib.NewReader(counter)
+		if err != nil {
+			if counter.n == 0 && err == io.ErrUnexpectedEOF {
+				// Nothing was sent at all for an empty transfer
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		c.r = r
+	}
+	return c.r.Read(buf)
+}
@@ -0,0 +1,87 @@
+package ftp
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// monthNames maps the lowercase abbreviated month names of non-English
+// locales, without their trailing period, to the month they stand for.
+var monthNames = map[string]time.Month{
+	// French
+	"janv": time.January, "févr": time.February, "fevr": time.February,
+	"mars": time.March, "avr": time.April, "juin": time.June,
+	"juil": time.July, "août": time.August, "aout": time.August,
+	"déc": time.December,
+
+	// German
+	"mär": time.March, "mrz": time.March, "okt": time.October,
+	"dez": time.December,
+
+	// Spanish
+	"ene": time.January, "abr": time.April, "ago": time.August,
+	"sept": time.September, "dic": time.December,
+
+	// Italian
+	"gen": time.January, "mag": time.May, "giu": time.June,
+	"lug": time.July, "set": time.September, "ott": time.October,
+
+	// Dutch
+	"mrt": time.March, "mei": time.May,
+
+	// Portuguese
+	"fev": time.February, "mai": time.May, "out": time.October,
+
+	// Polish
+	"sty": time.January, "lut": time.February, "kwi": time.April,
+	"maj": time.May, "cze": time.June, "lip": time.July,
+	"sie": time.August, "wrz": time.September, "paź": time.October,
+	"lis": time.November, "gru": time.December,
+
+	// Russian
+	"янв": time.January, "фев": time.February, "мар": time.March,
+	"апр": time.April, "май": time.May, "мая": time.May,
+	"июн": time.June, "июл": time.July, "авг": time.August,
+	"сен": time.September, "окт": time.October, "ноя": time.November,
+	"дек": time.December,
+}
+
+var monthNamesMu sync.RWMutex
+
+// englishMonths are the lowercase abbreviated English month names
+var englishMonths = [...]string{
+	"jan", "feb", "mar", "apr", "may", "jun",
+	"jul", "aug", "sep", "oct", "nov", "dec",
+}
+
+// RegisterMonthName makes the parsers of UNIX listings accept name as the
+// abbreviated name of month, for servers using a locale which is not
+// covered by default. name is matched case-insensitively, and a trailing
+// period is ignored.
+func RegisterMonthName(name string, month time.Month) {
+	monthNamesMu.Lock()
+	defer monthNamesMu.Unlock()
+
+	monthNames[strings.ToLower(strings.TrimSuffix(name, "."))] = month
+}
+
+// parseMonth returns the month for an abbreviated month name, either English
+// or from one of the known locales.
+func parseMonth(str string) (time.Month, bool) {
+	// The English names are matched case-insensitively, like time.Parse
+	// does, without allocating its error on mismatches
+	if len(str) == 3 {
+		for i, name := range englishMonths {
+			if str[0]|0x20 == name[0] && str[1]|0x20 == name[1] && str[2]|0x20 == name[2] {
+				return time.Month(i + 1), true
+			}
+		}
+	}
+
+	monthNamesMu.RLock()
+	defer monthNamesMu.RUnlock()
+
+	month, ok := monthNames[strings.ToLower(strings.TrimSuffix(str, "."))]
+	return month, ok
+}
@@ -0,0 +1,156 @@
+package ftp
+
+import (
+	"errors"
+	"net/textproto"
+	"path"
+	"strings"
+)
+
+// EntryFields is a set of fields of an Entry.
+type EntryFields int
+
+// The fields of an Entry which ListNames may leave unknown
+const (
+	EntryFieldType EntryFields = 1 << iota
+	EntryFieldSize
+	EntryFieldTime
+)
+
+// entryFieldsListed are the fields known for the entries of a listing
+const entryFieldsListed = EntryFieldType | EntryFieldSize | EntryFieldTime
+
+// NameEntry is an entry returned by ListNames. Only its fields in Known
+// were obtained from the server: the others are left to their zero value,
+// e.g. an EntryTypeFile Type which is not in Known only means that the type
+// of the entry is unknown.
+type NameEntry struct {
+	*Entry
+	Known EntryFields
+}
+
+// ListNamesOption represents an option for ListNames
+type ListNamesOption struct {
+	setup func(lo *listNamesOptions)
+}
+
+// listNamesOptions contains all the options set by ListNamesOption.setup
+type listNamesOptions struct {
+	maxStat int
+}
+
+// ListNamesWithStat returns a ListNamesOption that probes at most max of the
+// names listed with NLST, to find their type and size. The names are not
+// probed by default, since each of them costs up to three commands.
+func ListNamesWithStat(max int) ListNamesOption {
+	return ListNamesOption{func(lo *listNamesOptions) {
+		lo.maxStat = max
+	}}
+}
+
+// ListNames lists the directory dir like List, but falls back to NLST when
+// the server does not implement LIST, or when none of the lines of its
+// output can be parsed. The entries listed with NLST only have a name,
+// unless they are probed as set with ListNamesWithStat: with MLST when the
+// server supports it, or else with SIZE, which only succeeds on files, and
+// by trying to change the working directory to the entry, which only
+// succeeds on directories. The working directory is restored afterwards.
+//
+// The probes the server refuses leave the fields unknown, while the other
+// errors abort the listing.
+func (c *ServerConn) ListNames(dir string, options ...ListNamesOption) ([]*NameEntry, error) {
+	lo := &listNamesOptions{}
+	for _, option := range options {
+		option.setup(lo)
+	}
+
+	listed, skipped, err := c.list(dir)
+	if err == nil && (len(listed) > 0 || len(skipped) == 0) {
+		entries := make([]*NameEntry, len(listed))
+		for i, entry := range listed {
+			entries[i] = &NameEntry{Entry: entry, Known: entryFieldsListed}
+		}
+		return entries, nil
+	}
+	if err != nil && !isNotImplemented(err) {
+		return nil, err
+	}
+
+	names, err := c.NameList(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*NameEntry, len(names))
+	for i, name := range names {
+		// Some servers list the names along with the listed directory
+		p := path.Join(dir, name)
+		if dir != "" && strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/") {
+			p = name
+			name = path.Base(name)
+		}
+
+		entries[i] = &NameEntry{Entry: &Entry{Name: name}}
+		if i < lo.maxStat {
+			if err := c.probeEntry(p, entries[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// probeEntry fills the fields of entry which can be found about the file at
+// p, without transferring anything.
+func (c *ServerConn) probeEntry(p string, entry *NameEntry) error {
+	if c.mlstSupported {
+		facts, err := c.mlst(p)
+		if err != nil {
+			return refusedProbe(err)
+		}
+		facts.Name = entry.Name
+		entry.Entry = facts
+		entry.Known = entryFieldsListed
+		return nil
+	}
+
+	if _, ok := c.features["SIZE"]; ok {
+		size, err := c.FileSize(p)
+		if err == nil {
+			entry.Type = EntryTypeFile
+			entry.Size = uint64(size)
+			entry.Known = EntryFieldType | EntryFieldSize
+			return nil
+		}
+		if err := refusedProbe(err); err != nil {
+			return err
+		}
+	}
+
+	if err := c.PushDir(p); err != nil {
+		return refusedProbe(err)
+	}
+	entry.Type = EntryTypeFolder
+	entry.Known = EntryFieldType
+	return c.PopDir()
+}
+
+// refusedProbe turns the replies of the server refusing a probe into a nil
+// error, the probed fields staying unknown.
+func refusedProbe(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 400 && protoErr.Code != StatusNotAvailable {
+		return nil
+	}
+	return err
+}
+
+// isNotImplemented reports whether err is the reply of the server to a
+// command it does not implement
+func isNotImplemented(err error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return false
+	}
+	return protoErr.Code == StatusBadCommand || protoErr.Code == StatusNotImplemented
+}
@@ -0,0 +1,91 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListNamesParsed(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Listings: map[string]string{
+			"/pub": "-rw-r--r--   1 ftp ftp  5 Jan 01  2020 a.txt\r\n",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	entries, err := c.ListNames("/pub", ListNamesWithStat(10))
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, "a.txt", entries[0].Name)
+		assert.Equal(t, uint64(5), entries[0].Size)
+		assert.Equal(t, EntryFieldType|EntryFieldSize|EntryFieldTime, entries[0].Known)
+	}
+	assert.Zero(t, countCommands(s, "NLST"))
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestListNamesFallback(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"CWD /pub/a.txt":  "550 Not a directory",
+			"CWD /pub/secret": "550 Permission denied",
+		},
+		Listings: map[string]string{
+			"LIST /pub": "garbage\r\nmore garbage\r\n",
+			"NLST /pub": "a.txt\r\n/pub/sub\r\nsecret\r\nunprobed\r\n",
+		},
+		Files: map[string][]byte{
+			"/pub/a.txt": []byte("hello"),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	entries, err := c.ListNames("/pub", ListNamesWithStat(3))
+	if assert.NoError(t, err) && assert.Len(t, entries, 4) {
+		assert.Equal(t, &NameEntry{
+			Entry: &Entry{Name: "a.txt", Type: EntryTypeFile, Size: 5},
+			Known: EntryFieldType | EntryFieldSize,
+		}, entries[0])
+		assert.Equal(t, &NameEntry{
+			Entry: &Entry{Name: "sub", Type: EntryTypeFolder},
+			Known: EntryFieldType,
+		}, entries[1])
+		assert.Equal(t, &NameEntry{Entry: &Entry{Name: "secret"}}, entries[2])
+		assert.Equal(t, &NameEntry{Entry: &Entry{Name: "unprobed"}}, entries[3])
+	}
+
+	assert.Equal(t, 3, countCommands(s, "SIZE"))
+	assert.Equal(t, 1, countCommands(s, "PWD"))
+
+	// The working directory was restored after the successful probe
+	cwd, err := c.CurrentDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "/", cwd)
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestListNamesNotImplemented(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"LIST": "502 Not implemented",
+		},
+		Listings: map[string]string{
+			"": "a.txt\r\nb.txt\r\n",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	entries, err := c.ListNames("")
+	if assert.NoError(t, err) && assert.Len(t, entries, 2) {
+		assert.Equal(t, &NameEntry{Entry: &Entry{Name: "b.txt"}}, entries[1])
+	}
+	assert.Zero(t, countCommands(s, "SIZE"))
+
+	assert.NoError(t, c.Quit())
+}
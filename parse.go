@@ -3,6 +3,7 @@ package ftp
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -12,34 +13,150 @@ var errUnsupportedListLine = errors.New("unsupported LIST line")
 var errUnsupportedListDate = errors.New("unsupported LIST date")
 var errUnknownListEntryType = errors.New("unknown entry type")
 
+// errIncompleteListLine is returned by parsers for lines which only hold the
+// beginning of an entry continued on the next line.
+var errIncompleteListLine = errors.New("incomplete LIST line")
+
+// ListLineError describes a line of a directory listing which could not be
+// parsed.
+type ListLineError struct {
+	Line string // the line as sent by the server
+	Err  error  // the reason why the line was rejected
+}
+
+func (e *ListLineError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Err, e.Line)
+}
+
+// Unwrap returns the underlying parse error.
+func (e *ListLineError) Unwrap() error {
+	return e.Err
+}
+
 type parseFunc func(string, time.Time, *time.Location) (*Entry, error)
 
-var listLineParsers = []parseFunc{
-	parseRFC3659ListLine,
-	parseLsListLine,
-	parseDirListLine,
-	parseHostedFTPLine,
+var listLineParsers = newListLineParsers(false, "")
+
+// newListLineParsers returns the parsers tried in turn by parseListLine.
+// dayFirst tells whether ambiguous numeric dates such as 02/03/21 put the day
+// before the month. system is the reply to SYST, if known: the parser of the
+// format the system most likely uses is then tried first, which avoids lines
+// being misparsed by another one.
+func newListLineParsers(dayFirst bool, system string) []parseFunc {
+	parseDir, parseOS400 := parseDirListLine, parseOS400ListLine
+	if dayFirst {
+		parseDir, parseOS400 = parseDirListLineDayFirst, parseOS400ListLineDayFirst
+	}
+
+	// system is the prefix of the reply to SYST of the servers using the
+	// format of the parser
+	candidates := []struct {
+		system string
+		parse  parseFunc
+	}{
+		{"", parseRFC3659ListLine},
+		{"", parseLsListLine},
+		{"WINDOWS_NT", parseDir},
+		{"", parseHostedFTPLine},
+		{"", parseEplfListLine},
+		{"VMS", parseVmsListLine},
+		{"OS/400", parseOS400},
+		{"NETWARE", parseNetWareListLine},
+	}
+
+	system = strings.ToUpper(system)
+	preferred := func(candidateSystem string) bool {
+		return candidateSystem != "" && strings.HasPrefix(system, candidateSystem)
+	}
+
+	// The parser of the system first, then the others in order
+	parsers := make([]parseFunc, 0, len(candidates))
+	for _, candidate := range candidates {
+		if preferred(candidate.system) {
+			parsers = append(parsers, candidate.parse)
+		}
+	}
+	for _, candidate := range candidates {
+		if !preferred(candidate.system) {
+			parsers = append(parsers, candidate.parse)
+		}
+	}
+	return parsers
+}
+
+var vmsTimeFormats = []string{
+	"2-Jan-2006 15:04:05",
+	"2-Jan-2006 15:04", // MultiNet and TCPware
+}
+
+var os400TimeFormats = []string{
+	"01/02/06 15:04:05",
+	"02/01/06 15:04:05",
+}
+
+var os400TimeFormatsDayFirst = []string{
+	"02/01/06 15:04:05",
+	"01/02/06 15:04:05",
+}
+
+// dirNamePadding is the number of spaces between "<DIR>" and the name in
+// DIR listings
+const dirNamePadding = 10
+
+// Date formats used by DIR listings, in order of preference. The separators
+// are normalized to '-' before parsing.
+var dirDateFormats = []string{
+	"01-02-06",
+	"01-02-2006",
+	"2006-01-02",
+	"02-01-06",
+	"02-01-2006",
+}
+
+var dirDateFormatsDayFirst = []string{
+	"02-01-06",
+	"02-01-2006",
+	"2006-01-02",
+	"01-02-06",
+	"01-02-2006",
 }
 
 var dirTimeFormats = []string{
-	"01-02-06  03:04PM",
-	"2006-01-02  15:04",
+	"03:04PM",
+	"3:04PM",
+	"15:04",
 }
 
 // parseRFC3659ListLine parses the style of directory line defined in RFC 3659.
+//
+// As required by the RFC, the modify fact is always interpreted as UTC,
+// regardless of the location configured for the connection.
 func parseRFC3659ListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
-	iSemicolon := strings.Index(line, ";")
-	iWhitespace := strings.Index(line, " ")
+	// The facts are separated from the name by exactly one space: any other
+	// space belongs to the name.
+	iWhitespace := strings.IndexByte(line, ' ')
+	if iWhitespace < 1 {
+		return nil, errUnsupportedListLine
+	}
 
-	if iSemicolon < 0 || iSemicolon > iWhitespace {
+	facts := line[:iWhitespace]
+	if !strings.Contains(facts, ";") {
 		return nil, errUnsupportedListLine
 	}
 
 	e := &Entry{
 		Name: line[iWhitespace+1:],
 	}
+	if e.Name == "" {
+		return nil, errUnsupportedListLine
+	}
+
+	for _, field := range strings.Split(facts, ";") {
+		// Tolerate a missing trailing semicolon and empty facts
+		if field == "" {
+			continue
+		}
 
-	for _, field := range strings.Split(line[:iWhitespace-1], ";") {
 		i := strings.Index(field, "=")
 		if i < 1 {
 			return nil, errUnsupportedListLine
@@ -51,21 +168,62 @@ func parseRFC3659ListLine(line string, now time.Time, loc *time.Location) (*Entr
 		switch key {
 		case "modify":
 			var err error
-			e.Time, err = time.ParseInLocation("20060102150405", value, loc)
+			e.Time, err = parseTimeVal(value)
+			if err != nil {
+				return nil, err
+			}
+			e.TimeResolution = time.Second
+			if i := strings.IndexByte(value, '.'); i >= 0 {
+				e.TimeResolution = fracResolution(value[i+1:])
+			}
+		case "create":
+			var err error
+			e.CreateTime, err = parseTimeVal(value)
 			if err != nil {
 				return nil, err
 			}
 		case "type":
-			switch value {
-			case "dir", "cdir", "pdir":
+			lower := strings.ToLower(value)
+			switch {
+			case lower == "dir":
 				e.Type = EntryTypeFolder
-			case "file":
+			case lower == "cdir" || lower == "pdir":
+				e.Type = EntryTypeFolder
+				e.Pseudo = true
+			case lower == "file":
 				e.Type = EntryTypeFile
+			case lower == "os.unix=symlink":
+				e.Type = EntryTypeLink
+			case strings.HasPrefix(lower, "os.unix=slink"):
+				e.Type = EntryTypeLink
+				if i := strings.IndexByte(value, ':'); i >= 0 {
+					e.Target = value[i+1:]
+				}
 			}
+		case "perm":
+			e.Perm = value
+		case "unique":
+			e.Unique = value
 		case "size":
 			if err := e.setSize(value); err != nil {
 				return nil, err
 			}
+		case "unix.mode":
+			mode, err := parseUnixMode(value)
+			if err != nil {
+				return nil, errUnsupportedListLine
+			}
+			e.unixMode = mode
+			e.hasUnixMode = true
+		case "unix.owner":
+			e.Owner = value
+		case "unix.group":
+			e.Group = value
+		default:
+			if e.Facts == nil {
+				e.Facts = make(map[string]string)
+			}
+			e.Facts[key] = value
 		}
 	}
 	return e, nil
@@ -83,7 +241,9 @@ func parseLsListLine(line string, now time.Time, loc *time.Location) (*Entry, er
 	}
 
 	scanner := newScanner(line)
-	fields := scanner.NextFields(6)
+	// Up to 8 fields are used below
+	var buf [8]string
+	fields := scanner.AppendFields(buf[:0], 6)
 
 	if len(fields) < 6 {
 		return nil, errUnsupportedListLine
@@ -91,8 +251,12 @@ func parseLsListLine(line string, now time.Time, loc *time.Location) (*Entry, er
 
 	if fields[1] == "folder" && fields[2] == "0" {
 		e := &Entry{
-			Type: EntryTypeFolder,
-			Name: scanner.Remaining(),
+			Type:        EntryTypeFolder,
+			Name:        scanner.Remaining(),
+			Permissions: fields[0],
+		}
+		if e.Name == "" {
+			return nil, errUnsupportedListLine
 		}
 		if err := e.setTime(fields[3:6], now, loc); err != nil {
 			return nil, err
@@ -104,8 +268,12 @@ func parseLsListLine(line string, now time.Time, loc *time.Location) (*Entry, er
 	if fields[1] == "0" {
 		fields = append(fields, scanner.Next())
 		e := &Entry{
-			Type: EntryTypeFile,
-			Name: scanner.Remaining(),
+			Type:        EntryTypeFile,
+			Name:        scanner.Remaining(),
+			Permissions: fields[0],
+		}
+		if e.Name == "" {
+			return nil, errUnsupportedListLine
 		}
 
 		if err := e.setSize(fields[2]); err != nil {
@@ -118,14 +286,69 @@ func parseLsListLine(line string, now time.Time, loc *time.Location) (*Entry, er
 		return e, nil
 	}
 
-	// Read two more fields
-	fields = append(fields, scanner.NextFields(2)...)
-	if len(fields) < 8 {
-		return nil, errUnsupportedListLine
+	// ls --time-style=long-iso and full-iso print the date as a single
+	// field, followed by the time and, with full-iso, a time zone offset
+	var isoTime []string
+	switch {
+	case isISODate(fields[5]):
+		isoTime = []string{fields[5], scanner.Next()}
+		fields = fields[:5]
+	case isISODate(fields[4]) && isNumber(fields[3]):
+		// no group column
+		isoTime = []string{fields[4], fields[5]}
+		fields = append(fields[:3], "", fields[3])
+	case (fields[0][0] == 'c' || fields[0][0] == 'b') && strings.HasSuffix(fields[4], ","):
+		position := scanner.position
+		if date := scanner.Next(); isISODate(date) {
+			isoTime = []string{date, scanner.Next()}
+			fields = append(fields[:4], fields[4]+fields[5])
+		} else {
+			scanner.position = position
+		}
+	}
+
+	if isoTime != nil {
+		position := scanner.position
+		if zone := scanner.Next(); isZoneOffset(zone) && scanner.Remaining() != "" {
+			isoTime = append(isoTime, zone)
+		} else {
+			scanner.position = position
+		}
+	} else {
+		// Read two more fields, remembering where the last one starts
+		fields = append(fields, scanner.Next())
+		lastField := scanner.position
+		fields = append(fields, scanner.Next())
+		if fields[7] == "" {
+			return nil, errUnsupportedListLine
+		}
+
+		// Some servers omit the group column, so the date starts one field
+		// earlier and the last field read is already part of the name
+		if !isMonth(fields[5]) && isMonth(fields[4]) && isNumber(fields[3]) {
+			fields = append(fields[:3], "", fields[3], fields[4], fields[5], fields[6])
+			scanner.position = lastField
+		}
+
+		// Devices have their "major, minor" numbers in place of the size,
+		// which may span two fields
+		if (fields[0][0] == 'c' || fields[0][0] == 'b') && strings.HasSuffix(fields[4], ",") {
+			fields[4] += fields[5]
+			fields = append(fields[:5], fields[6:]...)
+			if next := scanner.Next(); next != "" {
+				fields = append(fields, next)
+			}
+			if len(fields) < 8 {
+				return nil, errUnsupportedListLine
+			}
+		}
 	}
 
 	e := &Entry{
-		Name: scanner.Remaining(),
+		Name:        scanner.Remaining(),
+		Owner:       fields[2],
+		Group:       fields[3],
+		Permissions: fields[0],
 	}
 	switch fields[0][0] {
 	case '-':
@@ -135,6 +358,15 @@ func parseLsListLine(line string, now time.Time, loc *time.Location) (*Entry, er
 		}
 	case 'd':
 		e.Type = EntryTypeFolder
+	case 'c', 'b':
+		e.Type = EntryTypeDevice
+		if err := e.setDevice(fields[4]); err != nil {
+			return nil, err
+		}
+	case 'p':
+		e.Type = EntryTypePipe
+	case 's':
+		e.Type = EntryTypeSocket
 	case 'l':
 		e.Type = EntryTypeLink
 
@@ -147,55 +379,149 @@ func parseLsListLine(line string, now time.Time, loc *time.Location) (*Entry, er
 		return nil, errUnknownListEntryType
 	}
 
-	if err := e.setTime(fields[5:8], now, loc); err != nil {
+	if isoTime != nil {
+		if err := e.setISOTime(isoTime, loc); err != nil {
+			return nil, err
+		}
+	} else if err := e.setTime(fields[5:8], now, loc); err != nil {
 		return nil, err
 	}
 
+	if e.Name == "" {
+		return nil, errUnsupportedListLine
+	}
+
 	return e, nil
 }
 
 // parseDirListLine parses a directory line in a format based on the output of
 // the MS-DOS DIR command.
 func parseDirListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	return parseDirListLineWith(line, loc, dirDateFormats)
+}
+
+// parseDirListLineDayFirst is parseDirListLine for servers writing the day
+// before the month.
+func parseDirListLineDayFirst(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	return parseDirListLineWith(line, loc, dirDateFormatsDayFirst)
+}
+
+func parseDirListLineWith(line string, loc *time.Location, dateFormats []string) (*Entry, error) {
 	e := &Entry{}
 	var err error
 
-	// Try various time formats that DIR might use, and stop when one works.
-	for _, format := range dirTimeFormats {
-		if len(line) > len(format) {
-			e.Time, err = time.ParseInLocation(format, line[:len(format)], loc)
+	scanner := newScanner(line)
+	fields := scanner.NextFields(2)
+	if len(fields) < 2 {
+		return nil, errUnsupportedListLine
+	}
+
+	// Try the various date and time formats that DIR might use, and stop
+	// when one works.
+	date := strings.NewReplacer("/", "-", ".", "-").Replace(fields[0])
+	clock := strings.ToUpper(fields[1])
+	for _, dateFormat := range dateFormats {
+		for _, timeFormat := range dirTimeFormats {
+			e.Time, err = time.ParseInLocation(dateFormat+" "+timeFormat, date+" "+clock, loc)
 			if err == nil {
-				line = line[len(format):]
 				break
 			}
 		}
+		if err == nil {
+			break
+		}
 	}
 	if err != nil {
 		// None of the time formats worked.
 		return nil, errUnsupportedListLine
 	}
+	e.TimeResolution = time.Minute
 
+	line = scanner.Remaining()
 	line = strings.TrimLeft(line, " ")
-	if strings.HasPrefix(line, "<DIR>") {
+	if marker := dirMarker(line); marker != "" {
 		e.Type = EntryTypeFolder
-		line = strings.TrimPrefix(line, "<DIR>")
+		if marker != "<DIR>" {
+			e.Type = EntryTypeLink
+		}
+		line = strings.TrimPrefix(line, marker)
+
+		// Names are aligned after the sizes, which are right-aligned in a
+		// column of 14 characters: with the usual padding, spaces beyond it
+		// belong to the name.
+		padding := len(line) - len(strings.TrimLeft(line, " "))
+		if width := dirNamePadding + len("<DIR>") - len(marker); padding > width {
+			line = line[width:]
+		} else {
+			line = line[padding:]
+		}
+
+		// Junctions and symbolic links may be followed by their target
+		if e.Type == EntryTypeLink && strings.HasSuffix(line, "]") {
+			if i := strings.LastIndex(line, " ["); i > 0 {
+				e.Target = line[i+2 : len(line)-1]
+				line = line[:i]
+			}
+		}
 	} else {
 		space := strings.Index(line, " ")
 		if space == -1 {
 			return nil, errUnsupportedListLine
 		}
-		e.Size, err = strconv.ParseUint(line[:space], 10, 64)
+		e.Size, err = parseGroupedSize(line[:space])
 		if err != nil {
 			return nil, errUnsupportedListLine
 		}
 		e.Type = EntryTypeFile
-		line = line[space:]
+
+		// The name starts after the single space following the size
+		line = line[space+1:]
 	}
 
-	e.Name = strings.TrimLeft(line, " ")
+	if line == "" {
+		return nil, errUnsupportedListLine
+	}
+
+	e.Name = line
 	return e, nil
 }
 
+// dirMarkers are the markers DIR listings use in place of the size of
+// directories, junctions and directory symbolic links.
+var dirMarkers = []string{"<DIR>", "<JUNCTION>", "<SYMLINKD>"}
+
+// dirMarker returns the marker line starts with, if any.
+func dirMarker(line string) string {
+	for _, marker := range dirMarkers {
+		if strings.HasPrefix(line, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// parseGroupedSize parses a size which may contain thousands separators, as
+// in 1,234,567 or 1.234.567. Separators are only accepted between groups of
+// three digits.
+func parseGroupedSize(size string) (uint64, error) {
+	sep := strings.IndexAny(size, ",.")
+	if sep == -1 {
+		return strconv.ParseUint(size, 10, 64)
+	}
+
+	groups := strings.Split(size, size[sep:sep+1])
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return 0, errUnsupportedListLine
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return 0, errUnsupportedListLine
+		}
+	}
+
+	return strconv.ParseUint(strings.Join(groups, ""), 10, 64)
+}
+
 // parseHostedFTPLine parses a directory line in the non-standard format used
 // by hostedftp.com
 // -r--------   0 user group     65222236 Feb 24 00:39 UABlacklistingWeek8.csv
@@ -217,29 +543,557 @@ func parseHostedFTPLine(line string, now time.Time, loc *time.Location) (*Entry,
 	return parseLsListLine(fields[0]+" 1 "+scanner.Remaining(), now, loc)
 }
 
+// parseEplfListLine parses a directory line in the Easily Parsed LIST Format
+// described in https://cr.yp.to/ftp/list/eplf.html
+// +i8388621.44468,m839956783,r,s10376,	RFCEPLF
+func parseEplfListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	if !strings.HasPrefix(line, "+") {
+		return nil, errUnsupportedListLine
+	}
+
+	iTab := strings.IndexByte(line, '\t')
+	if iTab < 0 {
+		return nil, errUnsupportedListLine
+	}
+
+	e := &Entry{
+		Name: line[iTab+1:],
+	}
+	if e.Name == "" {
+		return nil, errUnsupportedListLine
+	}
+
+	for _, fact := range strings.Split(line[1:iTab], ",") {
+		if fact == "" {
+			continue
+		}
+
+		switch fact[0] {
+		case '/':
+			e.Type = EntryTypeFolder
+		case 'r':
+			e.Type = EntryTypeFile
+		case 's':
+			if err := e.setSize(fact[1:]); err != nil {
+				return nil, errUnsupportedListLine
+			}
+		case 'm':
+			secs, err := strconv.ParseInt(fact[1:], 10, 64)
+			if err != nil {
+				return nil, errUnsupportedListLine
+			}
+			e.Time = time.Unix(secs, 0).In(loc)
+			e.TimeResolution = time.Second
+		}
+	}
+
+	return e, nil
+}
+
+// parseVmsListLine parses a directory line in the format used by OpenVMS,
+// including the variant of the MultiNet and TCPware servers whose times lack
+// the seconds. The owner and protection fields are ignored.
+// DATA.DIR;1   1 19-NOV-2016 18:53:39.00 [SYSTEM] (RWED,RWED,RE,RE)
+// 00README.TXT;1      2 30-DEC-1996 17:44 [SYSTEM] (RWED,RWED,RE,RE)
+//
+// Long file names are followed by a line break before the remaining fields.
+// In that case errIncompleteListLine is returned for the line holding only the
+// name, and the caller is expected to retry with the next line appended.
+func parseVmsListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	scanner := newScanner(line)
+	name := scanner.Next()
+
+	iSemicolon := strings.LastIndexByte(name, ';')
+	if iSemicolon < 1 {
+		return nil, errUnsupportedListLine
+	}
+	if _, err := strconv.ParseUint(name[iSemicolon+1:], 10, 32); err != nil {
+		return nil, errUnsupportedListLine
+	}
+
+	fields := scanner.NextFields(3)
+	if len(fields) == 0 {
+		return nil, errIncompleteListLine
+	}
+	if len(fields) < 3 {
+		return nil, errUnsupportedListLine
+	}
+
+	e := &Entry{
+		Name: name[:iSemicolon],
+		Type: EntryTypeFile,
+	}
+
+	if strings.HasSuffix(e.Name, ".DIR") {
+		e.Type = EntryTypeFolder
+		e.Name = strings.TrimSuffix(e.Name, ".DIR")
+	}
+
+	// The size is given in blocks of 512 bytes, optionally followed by the
+	// number of allocated blocks: 3/6
+	blocks := fields[0]
+	if i := strings.IndexByte(blocks, '/'); i >= 0 {
+		blocks = blocks[:i]
+	}
+	size, err := strconv.ParseUint(blocks, 10, 64)
+	if err != nil {
+		return nil, errUnsupportedListLine
+	}
+	if e.Type == EntryTypeFile {
+		e.Size = size * 512
+	}
+
+	// The owner is either [OWNER] or [GROUP,OWNER]
+	if owner := scanner.Next(); strings.HasPrefix(owner, "[") && strings.HasSuffix(owner, "]") {
+		owner = owner[1 : len(owner)-1]
+		if i := strings.IndexByte(owner, ','); i >= 0 {
+			e.Group = owner[:i]
+			owner = owner[i+1:]
+		}
+		e.Owner = owner
+	}
+
+	for _, format := range vmsTimeFormats {
+		e.Time, err = time.ParseInLocation(format, fields[1]+" "+fields[2], loc)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, errUnsupportedListDate
+	}
+	e.TimeResolution = time.Minute
+	if strings.Count(fields[2], ":") == 2 {
+		e.TimeResolution = time.Second
+	}
+
+	return e, nil
+}
+
+// parseOS400ListLine parses a directory line in the format used by IBM i
+// (OS/400) servers.
+// QSYS          77824 02/23/00 15:35:40 *FILE      EVFEVENT.FILE
+// QSYS                                  *MEM       EVFEVENT.FILE/EVFEVENT.MBR
+func parseOS400ListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	return parseOS400ListLineWith(line, loc, os400TimeFormats)
+}
+
+// parseOS400ListLineDayFirst is parseOS400ListLine for servers writing the
+// day before the month.
+func parseOS400ListLineDayFirst(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	return parseOS400ListLineWith(line, loc, os400TimeFormatsDayFirst)
+}
+
+func parseOS400ListLineWith(line string, loc *time.Location, timeFormats []string) (*Entry, error) {
+	scanner := newScanner(line)
+	fields := scanner.NextFields(2)
+	if len(fields) < 2 {
+		return nil, errUnsupportedListLine
+	}
+
+	e := &Entry{
+		Owner: fields[0],
+	}
+
+	// Members of files do not have a size nor a time
+	if !strings.HasPrefix(fields[1], "*") {
+		fields = append(fields, scanner.NextFields(3)...)
+		if len(fields) < 5 || !strings.HasPrefix(fields[4], "*") {
+			return nil, errUnsupportedListLine
+		}
+
+		if err := e.setSize(fields[1]); err != nil {
+			return nil, errUnsupportedListLine
+		}
+
+		// Depending on the system settings, the date is either MM/DD/YY or
+		// DD/MM/YY: the first one which is valid wins.
+		var err error
+		for _, format := range timeFormats {
+			e.Time, err = time.ParseInLocation(format, fields[2]+" "+fields[3], loc)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, errUnsupportedListDate
+		}
+		e.TimeResolution = time.Second
+
+		fields = []string{fields[0], fields[4]}
+	}
+
+	switch fields[1] {
+	case "*DIR", "*DDIR", "*LIB", "*FLR":
+		e.Type = EntryTypeFolder
+	default:
+		e.Type = EntryTypeFile
+	}
+
+	e.Name = strings.TrimSuffix(strings.TrimLeft(scanner.Remaining(), " "), "/")
+	if e.Name == "" {
+		return nil, errUnsupportedListLine
+	}
+
+	return e, nil
+}
+
+// parseNetWareListLine parses a directory line in the format used by Novell
+// NetWare servers, where the rights are shown in brackets and there is no
+// link count.
+// d [R----F--] supervisor            512       Jan 16 18:53    login
+func parseNetWareListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
+	scanner := newScanner(line)
+	fields := scanner.NextFields(7)
+	if len(fields) < 7 || len(fields[0]) != 1 {
+		return nil, errUnsupportedListLine
+	}
+
+	if !strings.HasPrefix(fields[1], "[") || !strings.HasSuffix(fields[1], "]") {
+		return nil, errUnsupportedListLine
+	}
+
+	e := &Entry{
+		Name:  strings.TrimLeft(scanner.Remaining(), " "),
+		Owner: fields[2],
+	}
+	if e.Name == "" {
+		return nil, errUnsupportedListLine
+	}
+
+	switch fields[0] {
+	case "d":
+		e.Type = EntryTypeFolder
+	case "-":
+		e.Type = EntryTypeFile
+		if err := e.setSize(fields[3]); err != nil {
+			return nil, errUnsupportedListLine
+		}
+	default:
+		return nil, errUnsupportedListLine
+	}
+
+	if err := e.setTime(fields[4:7], now, loc); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// isListHeader reports whether the line of a directory listing carries no
+// entry and should be silently ignored: empty lines and the "total N" block
+// count printed by ls.
+func isListHeader(line string) bool {
+	if strings.TrimSpace(line) == "" {
+		return true
+	}
+
+	if !strings.HasPrefix(line, "total ") {
+		return false
+	}
+	_, err := strconv.ParseUint(strings.TrimSpace(line[len("total "):]), 10, 64)
+	return err == nil
+}
+
 // parseListLine parses the various non-standard format returned by the LIST
 // FTP command.
 func parseListLine(line string, now time.Time, loc *time.Location) (*Entry, error) {
-	for _, f := range listLineParsers {
+	return parseListLineWith(listLineParsers, line, now, loc)
+}
+
+// parseListLineWith is parseListLine trying the given parsers in turn.
+func parseListLineWith(parsers []parseFunc, line string, now time.Time, loc *time.Location) (*Entry, error) {
+	for _, f := range parsers {
 		e, err := f(line, now, loc)
 		if err != errUnsupportedListLine {
+			if err == nil {
+				e.Raw = line
+				if e.Name == "." || e.Name == ".." {
+					e.Pseudo = true
+				}
+			}
 			return e, err
 		}
 	}
 	return nil, errUnsupportedListLine
 }
 
+// Mode returns the file mode decoded from the permissions sent by the server,
+// either as a UNIX permission string or as the UNIX.mode MLSD fact.
+// If the server did not provide any, see HasMode, only the type bits derived
+// from the entry type are set.
+func (e *Entry) Mode() os.FileMode {
+	if e.Permissions != "" {
+		if mode, ok := parsePermissions(e.Permissions); ok {
+			return mode
+		}
+	}
+
+	if !e.hasUnixMode {
+		return e.Type.mode()
+	}
+	return e.unixMode | e.Type.mode()
+}
+
+// parseUnixMode decodes the octal value of the UNIX.mode MLSD fact, e.g.
+// "0755".
+func parseUnixMode(value string) (os.FileMode, error) {
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	mode := os.FileMode(bits & 0777)
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// formatUnixMode encodes mode like the UNIX.mode MLSD fact, see
+// parseUnixMode.
+func formatUnixMode(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// HasMode reports whether the server provided permissions for the entry,
+// which tells a mode of 0000 from a missing one.
+func (e *Entry) HasMode() bool {
+	if e.Permissions != "" {
+		_, ok := parsePermissions(e.Permissions)
+		return ok
+	}
+	return e.hasUnixMode
+}
+
+// parsePermissions decodes a UNIX permission string such as "drwxr-sr-t".
+// Extra characters after the ten first ones (ACL markers) are ignored.
+func parsePermissions(perm string) (os.FileMode, bool) {
+	if len(perm) < 10 {
+		return 0, false
+	}
+
+	var mode os.FileMode
+	switch perm[0] {
+	case '-':
+	case 'd':
+		mode |= os.ModeDir
+	case 'l':
+		mode |= os.ModeSymlink
+	case 'c':
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 'b':
+		mode |= os.ModeDevice
+	case 'p':
+		mode |= os.ModeNamedPipe
+	case 's':
+		mode |= os.ModeSocket
+	default:
+		return 0, false
+	}
+
+	// special bit set on the execute position of each triplet
+	special := [3]os.FileMode{os.ModeSetuid, os.ModeSetgid, os.ModeSticky}
+
+	for i := 0; i < 3; i++ {
+		triplet := perm[1+3*i : 4+3*i]
+		shift := uint(3 * (2 - i))
+
+		if triplet[0] == 'r' {
+			mode |= 04 << shift
+		}
+		if triplet[1] == 'w' {
+			mode |= 02 << shift
+		}
+		switch triplet[2] {
+		case 'x':
+			mode |= 01 << shift
+		case 's', 't':
+			mode |= 01<<shift | special[i]
+		case 'S', 'T':
+			mode |= special[i]
+		}
+	}
+
+	return mode, true
+}
+
+// parseTimeVal parses a time value as defined in RFC 3659, used by the MLSD
+// modify fact and the MDTM command: YYYYMMDDHHMMSS in UTC, optionally followed
+// by a fractional part of the seconds.
+func parseTimeVal(value string) (time.Time, error) {
+	frac := ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		value, frac = value[:i], value[i+1:]
+		if frac == "" || len(frac) > 9 || !isNumber(frac) {
+			return time.Time{}, errUnsupportedListDate
+		}
+	}
+
+	t, err := time.ParseInLocation(timeFormat, value, time.UTC)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if frac != "" {
+		nsec, _ := strconv.Atoi(frac + strings.Repeat("0", 9-len(frac)))
+		t = t.Add(time.Duration(nsec))
+	}
+
+	return t, nil
+}
+
+// isMonth reports whether str is an abbreviated month name
+func isMonth(str string) bool {
+	_, ok := parseMonth(str)
+	return ok
+}
+
+// isISODate reports whether str is a date in the YYYY-MM-DD format
+func isISODate(str string) bool {
+	// Checking the shape first spares the error of time.Parse, which is
+	// allocated, on most fields
+	if len(str) != len("2006-01-02") || str[4] != '-' || str[7] != '-' {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", str)
+	return err == nil
+}
+
+// isZoneOffset reports whether str is a numeric time zone offset such as
+// +0100
+func isZoneOffset(str string) bool {
+	if len(str) != len("-0700") || (str[0] != '+' && str[0] != '-') {
+		return false
+	}
+	return isNumber(str[1:])
+}
+
+// isNumber reports whether str only contains decimal digits, and fits in an
+// uint64
+func isNumber(str string) bool {
+	if str == "" {
+		return false
+	}
+	for i := 0; i < len(str); i++ {
+		if str[i] < '0' || str[i] > '9' {
+			return false
+		}
+	}
+	if len(str) < len("18446744073709551615") {
+		return true
+	}
+	_, err := strconv.ParseUint(str, 10, 64)
+	return err == nil
+}
+
+// parseDigits parses str as a decimal number of min to max digits, without
+// sign.
+func parseDigits(str string, min, max int) (int, bool) {
+	if len(str) < min || len(str) > max {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(str); i++ {
+		if str[i] < '0' || str[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(str[i]-'0')
+	}
+	return n, true
+}
+
+// isValidDay reports whether day is a day of month in year
+func isValidDay(year int, month time.Month, day int) bool {
+	return day >= 1 && time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Day() == day
+}
+
+// setDevice parses the "major,minor" device numbers
+func (e *Entry) setDevice(str string) error {
+	i := strings.IndexByte(str, ',')
+	if i < 0 {
+		return errUnsupportedListLine
+	}
+
+	major, err := strconv.ParseUint(str[:i], 10, 32)
+	if err != nil {
+		return errUnsupportedListLine
+	}
+	minor, err := strconv.ParseUint(str[i+1:], 10, 32)
+	if err != nil {
+		return errUnsupportedListLine
+	}
+
+	e.Major, e.Minor = uint32(major), uint32(minor)
+	return nil
+}
+
 func (e *Entry) setSize(str string) (err error) {
 	e.Size, err = strconv.ParseUint(str, 0, 64)
 	return
 }
 
-func (e *Entry) setTime(fields []string, now time.Time, loc *time.Location) (err error) {
-	if strings.Contains(fields[2], ":") { // contains time
-		thisYear, _, _ := now.Date()
-		timeStr := fmt.Sprintf("%s %s %d %s", fields[1], fields[0], thisYear, fields[2])
-		e.Time, err = time.ParseInLocation("_2 Jan 2006 15:04", timeStr, loc)
+// setISOTime sets the time of an entry from the date, time and optional
+// time zone fields printed by ls --time-style=long-iso or full-iso.
+func (e *Entry) setISOTime(fields []string, loc *time.Location) (err error) {
+	timeStr := fields[0] + " " + fields[1]
+	layout := "2006-01-02 15:04"
+	e.TimeResolution = time.Minute
+	if strings.Count(fields[1], ":") == 2 {
+		// Fractional seconds are accepted even if absent from the layout
+		layout = "2006-01-02 15:04:05"
+		e.TimeResolution = time.Second
+		if i := strings.IndexByte(fields[1], '.'); i >= 0 {
+			e.TimeResolution = fracResolution(fields[1][i+1:])
+		}
+	}
 
+	if len(fields) > 2 {
+		e.Time, err = time.Parse(layout+" -0700", timeStr+" "+fields[2])
+		e.Time = e.Time.In(loc)
+	} else {
+		e.Time, err = time.ParseInLocation(layout, timeStr, loc)
+	}
+	if err != nil {
+		return errUnsupportedListDate
+	}
+	return nil
+}
+
+// listTimeSkew is how far in the future a timestamp without a year may be
+// and still be considered recent, to allow for clock skew and for servers
+// listing times in a time zone ahead of the location used for parsing.
+const listTimeSkew = 24 * time.Hour
+
+// setTime sets the time of an entry from the month, day, and time or year
+// fields of UNIX listings. The time is built from its components rather
+// than parsed with time.Parse, which is noticeably slower on large listings.
+func (e *Entry) setTime(fields []string, now time.Time, loc *time.Location) error {
+	month, ok := parseMonth(fields[0])
+	if !ok {
+		return errUnsupportedListDate
+	}
+	day, ok := parseDigits(fields[1], 1, 2)
+	if !ok {
+		return errUnsupportedListDate
+	}
+
+	if i := strings.IndexByte(fields[2], ':'); i >= 0 { // contains time
 		/*
 			On unix, `info ls` shows:
 
@@ -251,17 +1105,48 @@ func (e *Entry) setTime(fields []string, now time.Time, loc *time.Location) (err
 			is not listed in recent form, the timestamp is in the future, which
 			means you probably have clock skew problems which may break programs
 			like ‘make’ that rely on file timestamps.
+
+			So the timestamp is in the most recent year which does not put it
+			in the future. Going back several years is needed for Feb 29.
 		*/
-		if !e.Time.Before(now.AddDate(0, 6, 0)) {
-			e.Time = e.Time.AddDate(-1, 0, 0)
+		hour, okHour := parseDigits(fields[2][:i], 1, 2)
+		min, okMin := parseDigits(fields[2][i+1:], 2, 2)
+		if !okHour || !okMin || hour > 23 || min > 59 || !isValidDay(2000, month, day) {
+			return errUnsupportedListDate
 		}
 
-	} else { // only the date
-		if len(fields[2]) != 4 {
-			return errUnsupportedListDate
+		latest := now.Add(listTimeSkew)
+		year, _, _ := latest.Date()
+		for i := 0; i < 8; i, year = i+1, year-1 {
+			e.Time = time.Date(year, month, day, hour, min, 0, 0, loc)
+			if e.Time.Day() != day {
+				// Feb 29 of a non-leap year
+				continue
+			}
+			if !e.Time.After(latest) {
+				e.TimeResolution = time.Minute
+				return nil
+			}
 		}
-		timeStr := fmt.Sprintf("%s %s %s 00:00", fields[1], fields[0], fields[2])
-		e.Time, err = time.ParseInLocation("_2 Jan 2006 15:04", timeStr, loc)
+		return errUnsupportedListDate
 	}
-	return
+
+	// only the date
+	year, ok := parseDigits(fields[2], 4, 4)
+	if !ok || !isValidDay(year, month, day) {
+		return errUnsupportedListDate
+	}
+	e.Time = time.Date(year, month, day, 0, 0, 0, 0, loc)
+	e.TimeResolution = 24 * time.Hour
+	return nil
+}
+
+// fracResolution returns the resolution of a time whose seconds are
+// followed by the fractional part frac
+func fracResolution(frac string) time.Duration {
+	resolution := time.Second
+	for i := 0; i < len(frac) && resolution > time.Nanosecond; i++ {
+		resolution /= 10
+	}
+	return resolution
 }
@@ -1,6 +1,8 @@
 package ftp
 
 import (
+	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -30,6 +32,12 @@ type symlinkLine struct {
 	target string
 }
 
+type ownerLine struct {
+	line  string
+	owner string
+	group string
+}
+
 type unsupportedLine struct {
 	line string
 	err  error
@@ -45,12 +53,12 @@ var listTests = []line{
 	{"lrwxrwxrwx   1 root     other          7 Jan 25 00:17 bin -> usr/bin", "bin", 0, EntryTypeLink, newTime(thisYear, time.January, 25, 0, 17)},
 
 	// Another ls style
-	{"drwxr-xr-x               folder        0 Aug 15 05:49 !!!-Tipp des Haus!", "!!!-Tipp des Haus!", 0, EntryTypeFolder, newTime(thisYear, time.August, 15, 5, 49)},
-	{"drwxrwxrwx               folder        0 Aug 11 20:32 P0RN", "P0RN", 0, EntryTypeFolder, newTime(thisYear, time.August, 11, 20, 32)},
+	{"drwxr-xr-x               folder        0 Aug 15 05:49 !!!-Tipp des Haus!", "!!!-Tipp des Haus!", 0, EntryTypeFolder, newTime(previousYear, time.August, 15, 5, 49)},
+	{"drwxrwxrwx               folder        0 Aug 11 20:32 P0RN", "P0RN", 0, EntryTypeFolder, newTime(previousYear, time.August, 11, 20, 32)},
 	{"-rw-r--r--        0   18446744073709551615 18446744073709551615 Nov 16  2006 VIDEO_TS.VOB", "VIDEO_TS.VOB", 18446744073709551615, EntryTypeFile, newTime(2006, time.November, 16)},
 
 	// Microsoft's FTP servers for Windows
-	{"----------   1 owner    group         1803128 Jul 10 10:18 ls-lR.Z", "ls-lR.Z", 1803128, EntryTypeFile, newTime(thisYear, time.July, 10, 10, 18)},
+	{"----------   1 owner    group         1803128 Jul 10 10:18 ls-lR.Z", "ls-lR.Z", 1803128, EntryTypeFile, newTime(previousYear, time.July, 10, 10, 18)},
 	{"d---------   1 owner    group               0 Nov  9 19:45 Softlib", "Softlib", 0, EntryTypeFolder, newTime(previousYear, time.November, 9, 19, 45)},
 
 	// WFTPD for MSDOS
@@ -68,34 +76,139 @@ var listTests = []line{
 	// DOS DIR command output
 	{"08-07-15  07:50PM                  718 Post_PRR_20150901_1166_265118_13049.dat", "Post_PRR_20150901_1166_265118_13049.dat", 718, EntryTypeFile, newTime(2015, time.August, 7, 19, 50)},
 	{"08-10-15  02:04PM       <DIR>          Billing", "Billing", 0, EntryTypeFolder, newTime(2015, time.August, 10, 14, 4)},
+	{"08-10-15  02:04pm       <DIR>          Billing", "Billing", 0, EntryTypeFolder, newTime(2015, time.August, 10, 14, 4)},
+	{"2015-08-10  14:04       <DIR>          Billing", "Billing", 0, EntryTypeFolder, newTime(2015, time.August, 10, 14, 4)},
+	{"08-10-15  14:04                    718 Post.dat", "Post.dat", 718, EntryTypeFile, newTime(2015, time.August, 10, 14, 4)},
+	{"08-10-2015  14:04                  718 Post.dat", "Post.dat", 718, EntryTypeFile, newTime(2015, time.August, 10, 14, 4)},
+	{"25-12-2015  14:04                  718 Post.dat", "Post.dat", 718, EntryTypeFile, newTime(2015, time.December, 25, 14, 4)},
+	{"25.12.2015 14:04 718 Post.dat", "Post.dat", 718, EntryTypeFile, newTime(2015, time.December, 25, 14, 4)},
+	{"08/10/15 2:04AM 718 Post.dat", "Post.dat", 718, EntryTypeFile, newTime(2015, time.August, 10, 2, 4)},
 
 	// dir and file names that contain multiple spaces
 	{"drwxr-xr-x    3 110      1002            3 Dec 02  2009 spaces   dir   name", "spaces   dir   name", 0, EntryTypeFolder, newTime(2009, time.December, 2)},
 	{"-rwxr-xr-x    3 110      1002            1234567 Dec 02  2009 file   name", "file   name", 1234567, EntryTypeFile, newTime(2009, time.December, 2)},
 	{"-rwxr-xr-x    3 110      1002            1234567 Dec 02  2009  foo bar ", " foo bar ", 1234567, EntryTypeFile, newTime(2009, time.December, 2)},
+	{"-rw-r--r--    1 ftp      ftp                  12 Jan 25 00:17  report .txt", " report .txt", 12, EntryTypeFile, newTime(thisYear, time.January, 25, 0, 17)},
+	{"-rw-r--r--    1 ftp      ftp                  12 Jan 25 00:17 a  b.csv", "a  b.csv", 12, EntryTypeFile, newTime(thisYear, time.January, 25, 0, 17)},
+	{"modify=20150813175250;type=file;size=12;  report .txt ", " report .txt ", 12, EntryTypeFile, newTime(2015, time.August, 13, 17, 52, 50)},
+	{"08-07-15  07:50PM                   12  report .txt ", " report .txt ", 12, EntryTypeFile, newTime(2015, time.August, 7, 19, 50)},
+	{"08-07-15  07:50PM                   12 a  b.csv", "a  b.csv", 12, EntryTypeFile, newTime(2015, time.August, 7, 19, 50)},
+	{"08-10-15  02:04PM       <DIR>            Billing  ", "  Billing  ", 0, EntryTypeFolder, newTime(2015, time.August, 10, 14, 4)},
+	{"08-10-15  02:04PM       <DIR> Billing", "Billing", 0, EntryTypeFolder, newTime(2015, time.August, 10, 14, 4)},
+	{"-rw-r--r--    1 ftp      ftp          1024 déc. 12 09:33 notes.txt", "notes.txt", 1024, EntryTypeFile, newTime(previousYear, time.December, 12, 9, 33)},
+	{"-rw-r--r--    1 ftp      ftp          1024 févr. 28  2015 notes.txt", "notes.txt", 1024, EntryTypeFile, newTime(2015, time.February, 28)},
+	{"drwxr-xr-x    2 ftp      ftp          4096 Mär  3 14:22 daten", "daten", 0, EntryTypeFolder, newTime(thisYear, time.March, 3, 14, 22)},
+	{"drwxr-xr-x    2 ftp      ftp          4096 Okt 30  2016 daten", "daten", 0, EntryTypeFolder, newTime(2016, time.October, 30)},
+	{"-rw-r--r--    1 ftp      ftp            12 ene  5  2019 datos.csv", "datos.csv", 12, EntryTypeFile, newTime(2019, time.January, 5)},
+	{"-rw-r--r--    1 ftp      ftp            12 dic 24 18:00 datos.csv", "datos.csv", 12, EntryTypeFile, newTime(previousYear, time.December, 24, 18, 0)},
+	{"-rw-r--r--    1 ftp      ftp            12 окт  5  2019 файл.txt", "файл.txt", 12, EntryTypeFile, newTime(2019, time.October, 5)},
+	{"-rw-r--r--    1 ftp      ftp            12 мая 17  2019 файл.txt", "файл.txt", 12, EntryTypeFile, newTime(2019, time.May, 17)},
+	{"-rw-r--r--    1 ftp      ftp            12 mrt  3 14:22 bestand", "bestand", 12, EntryTypeFile, newTime(thisYear, time.March, 3, 14, 22)},
+	{"drwxr-xr-x 2 ftp ftp 4096 2024-01-15 10:22 incoming", "incoming", 0, EntryTypeFolder, newTime(2024, time.January, 15, 10, 22)},
+	{"-rw-r--r-- 1 ftp ftp 4096 2024-01-15 10:22  two  spaces", " two  spaces", 4096, EntryTypeFile, newTime(2024, time.January, 15, 10, 22)},
+	{"-rw-r--r-- 1 ftp 4096 2024-01-15 10:22 no group", "no group", 4096, EntryTypeFile, newTime(2024, time.January, 15, 10, 22)},
+	{"-rw-r--r-- 1 ftp ftp 4096 2024-01-15 10:22:33.123456789 +0100 full.iso", "full.iso", 4096, EntryTypeFile, time.Date(2024, time.January, 15, 9, 22, 33, 123456789, time.UTC)},
+	{"-rw-r--r-- 1 ftp ftp 4096 2024-01-15 10:22:33.000000000 -0130 +0100", "+0100", 4096, EntryTypeFile, newTime(2024, time.January, 15, 11, 52, 33)},
+	{"-rw-r--r-- 1 ftp ftp 4096 2024-01-15 10:22:33 +0000", "+0000", 4096, EntryTypeFile, newTime(2024, time.January, 15, 10, 22, 33)},
+	{"crw-rw----  1 root tty  4, 64 2024-01-15 10:22 tty0", "tty0", 0, EntryTypeDevice, newTime(2024, time.January, 15, 10, 22)},
+	{"06-25-24  10:12AM       <JUNCTION>     data", "data", 0, EntryTypeLink, newTime(2024, time.June, 25, 10, 12)},
+	{"01-16-24  03:04PM        1,234,567 backup.zip", "backup.zip", 1234567, EntryTypeFile, newTime(2024, time.January, 16, 15, 4)},
+	{"16.01.2024  15:04        1.234.567 backup.zip", "backup.zip", 1234567, EntryTypeFile, newTime(2024, time.January, 16, 15, 4)},
+	{"01-16-24  03:04PM              999 backup.zip", "backup.zip", 999, EntryTypeFile, newTime(2024, time.January, 16, 15, 4)},
 
 	// Odd link count from hostedftp.com
 	{"-r--------   0 user group     65222236 Feb 24 00:39 RegularFile", "RegularFile", 65222236, EntryTypeFile, newTime(thisYear, time.February, 24, 0, 39)},
 
+	// EPLF format: https://cr.yp.to/ftp/list/eplf.html
+	{"+i8388621.29609,m824255902,/,\tdev", "dev", 0, EntryTypeFolder, newTime(1996, time.February, 13, 23, 58, 22)},
+	{"+i8388621.44468,m839956783,r,s10376,\tRFCEPLF", "RFCEPLF", 10376, EntryTypeFile, newTime(1996, time.August, 13, 17, 19, 43)},
+	{"+r,s0,up,\tfile with spaces", "file with spaces", 0, EntryTypeFile, time.Time{}},
+
+	// OpenVMS
+	{"DATA.DIR;1   1 19-NOV-2016 18:53:39.00 [SYSTEM] (RWED,RWED,RE,RE)", "DATA", 0, EntryTypeFolder, newTime(2016, time.November, 19, 18, 53, 39)},
+	{"LOGIN.COM;3          2/3   5-JAN-2017 09:12:01.00 [USER,JDOE] (RWED,RWED,RE,)", "LOGIN.COM", 1024, EntryTypeFile, newTime(2017, time.January, 5, 9, 12, 1)},
+	{"A_RATHER_LONG_FILE_NAME.TXT;12 17 30-DEC-2016 17:44:10.00 [SYSTEM] (RWED,RWED,RE,RE)", "A_RATHER_LONG_FILE_NAME.TXT", 8704, EntryTypeFile, newTime(2016, time.December, 30, 17, 44, 10)},
+
+	// MultiNet / TCPware
+	{"00README.TXT;1      2 30-DEC-1996 17:44 [SYSTEM] (RWED,RWED,RE,RE)", "00README.TXT", 1024, EntryTypeFile, newTime(1996, time.December, 30, 17, 44)},
+	{"CORE.DIR;1          1  8-SEP-1996 16:09 (RWE,RWE,RE,RE)", "CORE", 0, EntryTypeFolder, newTime(1996, time.September, 8, 16, 9)},
+
+	// IBM i (OS/400)
+	{"QSYS          77824 02/23/00 15:35:40 *FILE      EVFEVENT.FILE", "EVFEVENT.FILE", 77824, EntryTypeFile, newTime(2000, time.February, 23, 15, 35, 40)},
+	{"QSYS           8192 05/19/06 10:07:31 *DIR       QOpenSys/", "QOpenSys", 8192, EntryTypeFolder, newTime(2006, time.May, 19, 10, 7, 31)},
+	{"QSYS           8192 19/05/06 10:07:31 *DDIR      QDLS/", "QDLS", 8192, EntryTypeFolder, newTime(2006, time.May, 19, 10, 7, 31)},
+	{"QPGMR              1234 12/31/98 23:59:59 *STMF      my file.txt", "my file.txt", 1234, EntryTypeFile, newTime(1998, time.December, 31, 23, 59, 59)},
+	{"QSYS                                    *MEM       EVFEVENT.FILE/EVFEVENT.MBR", "EVFEVENT.FILE/EVFEVENT.MBR", 0, EntryTypeFile, time.Time{}},
+
+	// Novell NetWare
+	{"d [R----F--] supervisor            512       Jan 16 18:53    login", "login", 0, EntryTypeFolder, newTime(thisYear, time.January, 16, 18, 53)},
+	{"- [R----F--] rhesus             214059       Oct 20 15:27 cx.exe", "cx.exe", 214059, EntryTypeFile, newTime(previousYear, time.October, 20, 15, 27)},
+	{"- [RWCEAFMS] jdoe                  1024       Mar  3  2015    read me.txt", "read me.txt", 1024, EntryTypeFile, newTime(2015, time.March, 3)},
+	{"d [RWCEAFMS] jdoe                   512       Feb  1 09:00    my  folder", "my  folder", 0, EntryTypeFolder, newTime(thisYear, time.February, 1, 9, 0)},
+
+	// Missing group column
+	{"-rw-r--r-- 1 ftp 5000 Mar 10 12:00 file.txt", "file.txt", 5000, EntryTypeFile, newTime(thisYear, time.March, 10, 12, 0)},
+	{"-rw-r--r-- 1 ftp 5000 Mar 10  2015 my  file.txt", "my  file.txt", 5000, EntryTypeFile, newTime(2015, time.March, 10)},
+	{"drwxr-xr-x 2 root 4096 Jan  5 09:00 dir", "dir", 0, EntryTypeFolder, newTime(thisYear, time.January, 5, 9, 0)},
+	{"-rw-r--r-- 1 ftp ftp 5000 Mar 10 12:00 Mar", "Mar", 5000, EntryTypeFile, newTime(thisYear, time.March, 10, 12, 0)},
+
+	// Devices, pipes and sockets
+	{"crw-rw-rw-   1 root     wheel      3,   2 May  4  2020 null", "null", 0, EntryTypeDevice, newTime(2020, time.May, 4)},
+	{"brw-rw----   1 root     disk       8,0 May  4  2020 sda", "sda", 0, EntryTypeDevice, newTime(2020, time.May, 4)},
+	{"crw--w----   1 root     tty      136,   0 Feb 10 22:45 pts 0", "pts 0", 0, EntryTypeDevice, newTime(thisYear, time.February, 10, 22, 45)},
+	{"prw-r--r--   1 root     root           0 May  4  2020 fifo", "fifo", 0, EntryTypePipe, newTime(2020, time.May, 4)},
+	{"srwxrwxrwx   1 root     root           0 May  4  2020 docker.sock", "docker.sock", 0, EntryTypeSocket, newTime(2020, time.May, 4)},
+
 	// Line with ACL persmissions
-	{"-rwxrw-r--+  1 521      101         2080 May 21 10:53 data.csv", "data.csv", 2080, EntryTypeFile, newTime(thisYear, time.May, 21, 10, 53)},
+	{"-rwxrw-r--+  1 521      101         2080 May 21 10:53 data.csv", "data.csv", 2080, EntryTypeFile, newTime(previousYear, time.May, 21, 10, 53)},
 }
 
 var listTestsSymlink = []symlinkLine{
 	{"lrwxrwxrwx   1 root     other          7 Jan 25 00:17 bin -> usr/bin", "bin", "usr/bin"},
 	{"lrwxrwxrwx    1 0        1001           27 Jul 07  2017 R-3.4.0.pkg -> el-capitan/base/R-3.4.0.pkg", "R-3.4.0.pkg", "el-capitan/base/R-3.4.0.pkg"},
+	{`06-25-24  10:12AM       <JUNCTION>     data [d:\storage\data]`, "data", `d:\storage\data`},
+	{`06-25-24  10:12AM       <SYMLINKD>     My Docs [C:\Users\jdoe\Documents]`, "My Docs", `C:\Users\jdoe\Documents`},
+	{"06-25-24  10:12AM       <SYMLINKD>     shared", "shared", ""},
+}
+
+var listTestsOwner = []ownerLine{
+	{"drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub", "110", "1002"},
+	{"-rw-r--r--   1 marketwired marketwired    12016 Mar 16  2016 2016031611G087802-001.newsml", "marketwired", "marketwired"},
+	{"-r--------   0 user group     65222236 Feb 24 00:39 RegularFile", "user", "group"},
+	{"modify=20150813175250;perm=adfr;size=951;type=file;unique=119FBB87UE;UNIX.group=0;UNIX.mode=0644;UNIX.owner=0; welcome.msg", "0", "0"},
+	{"modify=20150813175250;type=file;UNIX.group=staff;UNIX.owner=jdoe; welcome.msg", "jdoe", "staff"},
+	{"drwxr-xr-x               folder        0 Aug 15 05:49 !!!-Tipp des Haus!", "", ""},
+	{"08-10-15  02:04PM       <DIR>          Billing", "", ""},
+	{"LOGIN.COM;3          2/3   5-JAN-2017 09:12:01.00 [USER,JDOE] (RWED,RWED,RE,)", "JDOE", "USER"},
+	{"DATA.DIR;1   1 19-NOV-2016 18:53:39.00 [SYSTEM] (RWED,RWED,RE,RE)", "SYSTEM", ""},
+	{"QSYS          77824 02/23/00 15:35:40 *FILE      EVFEVENT.FILE", "QSYS", ""},
+	{"-rw-r--r-- 1 ftp 5000 Mar 10 12:00 file.txt", "ftp", ""},
+	{"- [R----F--] rhesus             214059       Oct 20 15:27 cx.exe", "rhesus", ""},
 }
 
 // Not supported, we expect a specific error message
 var listTestsFail = []unsupportedLine{
-	{"d [R----F--] supervisor            512       Jan 16 18:53 login", errUnsupportedListLine},
-	{"- [R----F--] rhesus             214059       Oct 20 15:27 cx.exe", errUnsupportedListLine},
+	{"-rw-r--r--    1 ftp      ftp            12 foo  5  2019 file", errUnsupportedListDate},
+	{"01-16-24  03:04PM        1,23,4567 backup.zip", errUnsupportedListLine},
+	{"01-16-24  03:04PM        1,234.567 backup.zip", errUnsupportedListLine},
+	{"01-16-24  03:04PM        ,234,567 backup.zip", errUnsupportedListLine},
+	{"01-16-24  03:04PM        18,446,744,073,709,551,616 backup.zip", errUnsupportedListLine},
+	{"d [R----F--] supervisor            512       Jan 16 18:53", errUnsupportedListLine},
+	{"x [R----F--] rhesus             214059       Oct 20 15:27 cx.exe", errUnsupportedListLine},
 	{"drwxr-xr-x    3 110      1002            3 Dec 02  209 pub", errUnsupportedListDate},
 	{"modify=20150806235817;invalid;UNIX.owner=0; movies", errUnsupportedListLine},
 	{"Zrwxrwxrwx   1 root     other          7 Jan 25 00:17 bin -> usr/bin", errUnknownListEntryType},
 	{"total 1", errUnsupportedListLine},
 	{"000000000x ", errUnsupportedListLine}, // see https://github.com/jlaffaye/ftp/issues/97
+	{"08-07-15  07:50PM                  718 ", errUnsupportedListLine},
 	{"", errUnsupportedListLine},
+	{"+i8388621.29609,m824255902,/, dev", errUnsupportedListLine},
+	{"A_RATHER_LONG_FILE_NAME.TXT;12", errIncompleteListLine},
+	{"Directory DISK$USER:[JDOE]", errUnsupportedListLine},
+	{"Total of 2 files, 19 blocks.", errUnsupportedListLine},
+	{"Total of 11 files", errUnsupportedListLine},
+	{"00README.TXT;1      2 30-DEC-1996 [SYSTEM] (RWED,RWED,RE,RE)", errUnsupportedListDate},
+	{"QSYS          77824 23/23/00 15:35:40 *FILE      EVFEVENT.FILE", errUnsupportedListDate},
 }
 
 func TestParseValidListLine(t *testing.T) {
@@ -109,6 +222,30 @@ func TestParseValidListLine(t *testing.T) {
 				assert.Equal(lt.entryType, entry.Type)
 				assert.Equal(lt.size, entry.Size)
 				assert.Equal(lt.time, entry.Time)
+				assert.Equal(lt.line, entry.Raw)
+			}
+		})
+	}
+}
+
+func TestParseDevices(t *testing.T) {
+	tests := []struct {
+		line         string
+		major, minor uint32
+	}{
+		{"crw-rw-rw-   1 root     wheel      3,   2 May  4  2020 null", 3, 2},
+		{"brw-rw----   1 root     disk       8,0 May  4  2020 sda", 8, 0},
+		{"crw--w----   1 root     tty      136,   0 Feb 10 22:45 pts 0", 136, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			assert := assert.New(t)
+			entry, err := parseListLine(test.line, now, time.UTC)
+
+			if assert.NoError(err) {
+				assert.Equal(test.major, entry.Major)
+				assert.Equal(test.minor, entry.Minor)
 			}
 		})
 	}
@@ -129,6 +266,55 @@ func TestParseSymlinks(t *testing.T) {
 	}
 }
 
+func TestParseOwner(t *testing.T) {
+	for _, lt := range listTestsOwner {
+		t.Run(lt.line, func(t *testing.T) {
+			assert := assert.New(t)
+			entry, err := parseListLine(lt.line, now, time.UTC)
+
+			if assert.NoError(err) {
+				assert.Equal(lt.owner, entry.Owner)
+				assert.Equal(lt.group, entry.Group)
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		line    string
+		mode    os.FileMode
+		hasMode bool
+	}{
+		{"drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub", os.ModeDir | 0755, true},
+		{"-rw-r--r--   1 marketwired marketwired    12016 Mar 16  2016 file", 0644, true},
+		{"lrwxrwxrwx   1 root     other          7 Jan 25 00:17 bin -> usr/bin", os.ModeSymlink | 0777, true},
+		{"-rwsr-sr-x   1 root     root       12016 Mar 16  2016 su", os.ModeSetuid | os.ModeSetgid | 0755, true},
+		{"drwxrwxrwt   1 root     root           0 Mar 16  2016 tmp", os.ModeDir | os.ModeSticky | 0777, true},
+		{"-rwSr--r-T   1 root     root           0 Mar 16  2016 odd", os.ModeSetuid | os.ModeSticky | 0644, true},
+		{"crw-rw-rw-   1 root     wheel      3,   2 May  4  2020 null", os.ModeDevice | os.ModeCharDevice | 0666, true},
+		{"----------   1 owner    group         1803128 Jul 10 10:18 ls-lR.Z", 0, true},
+		{"-rwxrw-r--+  1 521      101         2080 May 21 10:53 data.csv", 0764, true},
+		{"modify=20150813175250;type=file;UNIX.mode=0644; welcome.msg", 0644, true},
+		{"modify=20150813175250;type=dir;UNIX.mode=2775; shared", os.ModeDir | os.ModeSetgid | 0775, true},
+		{"modify=20150813175250;type=file; welcome.msg", 0, false},
+		{"modify=20150813175250;type=OS.unix=symlink; link", os.ModeSymlink, false},
+		{"08-10-15  02:04PM       <DIR>          Billing", os.ModeDir, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			assert := assert.New(t)
+			entry, err := parseListLine(test.line, now, time.UTC)
+
+			if assert.NoError(err) {
+				assert.Equal(test.mode, entry.Mode())
+				assert.Equal(test.hasMode, entry.HasMode())
+			}
+		})
+	}
+}
+
 func TestParseUnsupportedListLine(t *testing.T) {
 	for _, lt := range listTestsFail {
 		t.Run(lt.line, func(t *testing.T) {
@@ -139,6 +325,171 @@ func TestParseUnsupportedListLine(t *testing.T) {
 	}
 }
 
+func TestIsListHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isListHeader(""))
+	assert.True(isListHeader("  "))
+	assert.True(isListHeader("total 96"))
+	assert.True(isListHeader("total 0"))
+	assert.False(isListHeader("total"))
+	assert.False(isListHeader("total files"))
+	assert.False(isListHeader("-rw-r--r--   1 ftp      wheel           0 Jan 29 10:29 total 1"))
+}
+
+func TestParseTimeVal(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"20240115123045", time.Date(2024, time.January, 15, 12, 30, 45, 0, time.UTC)},
+		{"20240115123045.1", time.Date(2024, time.January, 15, 12, 30, 45, 100000000, time.UTC)},
+		{"20240115123045.123", time.Date(2024, time.January, 15, 12, 30, 45, 123000000, time.UTC)},
+		{"20240115123045.123456", time.Date(2024, time.January, 15, 12, 30, 45, 123456000, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			tm, err := parseTimeVal(test.value)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.expected, tm)
+			}
+		})
+	}
+
+	for _, value := range []string{"20240115123045.", "20240115123045.12a", "2024011512304", "20241315123045"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseTimeVal(value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseRFC3659ListLineUTC(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+
+	entry, err := parseListLine("modify=20240115123045.123;Type=FILE;size=3; file", now, loc)
+	if assert.NoError(t, err) {
+		assert.Equal(t, EntryTypeFile, entry.Type)
+		assert.True(t, time.Date(2024, time.January, 15, 12, 30, 45, 123000000, time.UTC).Equal(entry.Time))
+	}
+}
+
+func TestParseRFC3659Facts(t *testing.T) {
+	assert := assert.New(t)
+
+	entry, err := parseListLine("create=20150813175250.5;modify=20150813175250;perm=adfr;size=951;type=file;unique=119FBB87UE;UNIX.mode=0644;UNIX.uid=1000;Media-Type=text/plain; welcome.msg", now, time.UTC)
+	if assert.NoError(err) {
+		assert.Equal(time.Date(2015, time.August, 13, 17, 52, 50, 500000000, time.UTC), entry.CreateTime)
+		assert.Equal("adfr", entry.Perm)
+		assert.Equal("119FBB87UE", entry.Unique)
+		assert.Equal(map[string]string{"unix.uid": "1000", "media-type": "text/plain"}, entry.Facts)
+	}
+
+	entry, err = parseListLine("modify=20150813175250;type=file; plain", now, time.UTC)
+	if assert.NoError(err) {
+		assert.Nil(entry.Facts)
+	}
+
+	entry, err = parseListLine("modify=20150813175250;type=OS.unix=symlink; link", now, time.UTC)
+	if assert.NoError(err) {
+		assert.Equal(EntryTypeLink, entry.Type)
+		assert.Equal("", entry.Target)
+	}
+
+	entry, err = parseListLine("modify=20150813175250;type=OS.unix=slink:/usr/Bin; bin", now, time.UTC)
+	if assert.NoError(err) {
+		assert.Equal(EntryTypeLink, entry.Type)
+		assert.Equal("/usr/Bin", entry.Target)
+		assert.Equal("bin", entry.Name)
+	}
+}
+
+func TestParsePseudo(t *testing.T) {
+	tests := []struct {
+		line   string
+		pseudo bool
+	}{
+		{"modify=20150813224845;perm=fle;type=cdir;unique=119FBB87U4; /pub", true},
+		{"modify=20150813224845;perm=fle;type=pdir;unique=119FBB87U4; /", true},
+		{"modify=20150806235817;perm=fle;type=dir;unique=1B20F360U4; movies", false},
+		{"drwxr-xr-x    3 110      1002            3 Dec 02  2009 .", true},
+		{"drwxr-xr-x    3 110      1002            3 Dec 02  2009 ..", true},
+		{"drwxr-xr-x    3 110      1002            3 Dec 02  2009 ...", false},
+		{"drwxr-xr-x    3 110      1002            3 Dec 02  2009 .hidden", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			entry, err := parseListLine(test.line, now, time.UTC)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.pseudo, entry.Pseudo)
+				assert.Equal(t, EntryTypeFolder, entry.Type)
+			}
+		})
+	}
+}
+
+func TestParseRFC3659Variations(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		entry string
+		size  uint64
+		err   error
+	}{
+		{"trailing semicolon", "type=file;size=3; foo", "foo", 3, nil},
+		{"no trailing semicolon", "type=file;size=3 foo", "foo", 3, nil},
+		{"empty fact", "type=file;;size=3; foo", "foo", 3, nil},
+		{"leading semicolon", ";type=file;size=3; foo", "foo", 3, nil},
+		{"space-prefixed name", "type=file;size=3;  foo", " foo", 3, nil},
+		{"name with spaces", "type=file;size=3; foo  bar ", "foo  bar ", 3, nil},
+		{"empty name", "type=file;size=3; ", "", 0, errUnsupportedListLine},
+		{"no name", "type=file;size=3;", "", 0, errUnsupportedListLine},
+		{"leading space", " type=file;size=3; foo", "", 0, errUnsupportedListLine},
+		{"fact without value", "type=file;size; foo", "", 0, errUnsupportedListLine},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := parseRFC3659ListLine(test.line, now, time.UTC)
+			if test.err != nil {
+				assert.Equal(t, test.err, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.entry, entry.Name)
+				assert.Equal(t, test.size, entry.Size)
+				assert.Equal(t, EntryTypeFile, entry.Type)
+			}
+		})
+	}
+}
+
+func TestParseDayFirst(t *testing.T) {
+	parsers := newListLineParsers(true, "")
+
+	tests := []struct {
+		line     string
+		expected time.Time
+	}{
+		{"08-10-15  14:04                    718 Post.dat", newTime(2015, time.October, 8, 14, 4)},
+		{"08-10-2015  02:04PM                718 Post.dat", newTime(2015, time.October, 8, 14, 4)},
+		{"12-25-2015  14:04                  718 Post.dat", newTime(2015, time.December, 25, 14, 4)},
+		{"2015-08-10  14:04                  718 Post.dat", newTime(2015, time.August, 10, 14, 4)},
+		{"QSYS          77824 02/03/00 15:35:40 *FILE      EVFEVENT.FILE", newTime(2000, time.March, 2, 15, 35, 40)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			entry, err := parseListLineWith(parsers, test.line, now, time.UTC)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.expected, entry.Time)
+			}
+		})
+	}
+}
+
 func TestSettime(t *testing.T) {
 	tests := []struct {
 		line     string
@@ -147,11 +498,15 @@ func TestSettime(t *testing.T) {
 		// this year, in the past
 		{"Feb 10 23:00", newTime(thisYear, time.February, 10, 23)},
 
-		// this year, less than six months in the future
-		{"Sep 10 22:59", newTime(thisYear, time.September, 10, 22, 59)},
+		// today
+		{"Mar 10 22:59", newTime(thisYear, time.March, 10, 22, 59)},
 
-		// previous year, otherwise it would be more than 6 months in the future
-		{"Sep 10 23:00", newTime(previousYear, time.September, 10, 23)},
+		// this year, within the clock skew allowance
+		{"Mar 11 22:59", newTime(thisYear, time.March, 11, 22, 59)},
+
+		// previous year, otherwise it would be in the future
+		{"Mar 11 23:01", newTime(previousYear, time.March, 11, 23, 1)},
+		{"Sep 10 22:59", newTime(previousYear, time.September, 10, 22, 59)},
 
 		// far in the future
 		{"Jan 23  2019", newTime(2019, time.January, 23)},
@@ -169,6 +524,84 @@ func TestSettime(t *testing.T) {
 	}
 }
 
+func TestParseTimeResolution(t *testing.T) {
+	tests := []struct {
+		line       string
+		resolution time.Duration
+	}{
+		{"modify=20150813175250;type=file;size=12; welcome.msg", time.Second},
+		{"modify=20150813175250.123;type=file;size=12; welcome.msg", time.Millisecond},
+		{"type=file;size=12; welcome.msg", 0},
+		{"-rw-r--r--   1 root     other        531 Jan 29 03:26 README", time.Minute},
+		{"-rw-r--r--   1 root     other        531 Jan 29  2016 README", 24 * time.Hour},
+		{"-rw-r--r--   1 root     other        531 2016-01-29 03:26 README", time.Minute},
+		{"-rw-r--r--   1 root     other        531 2016-01-29 03:26:13.123456789 +0100 README", time.Nanosecond},
+		{"08-10-15  02:04PM       <DIR>          Billing", time.Minute},
+		{"+i8388621.29609,m824255902,/,\tdev", time.Second},
+		{"DATA.DIR;1   1 19-NOV-2016 18:53:39.00 [SYSTEM] (RWED,RWED,RE,RE)", time.Second},
+		{"CORE.DIR;1          1  8-SEP-1996 16:09 (RWE,RWE,RE,RE)", time.Minute},
+		{"QSYS          77824 02/23/00 15:35:40 *FILE      EVFEVENT.FILE", time.Second},
+	}
+
+	for _, test := range tests {
+		entry, err := parseListLine(test.line, now, time.UTC)
+		if assert.NoError(t, err, test.line) {
+			assert.Equal(t, test.resolution, entry.TimeResolution, test.line)
+		}
+	}
+}
+
+func TestRegisterMonthName(t *testing.T) {
+	line := "-rw-r--r--    1 ftp      ftp            12 tammi  5  2019 tiedosto"
+
+	_, err := parseListLine(line, now, time.UTC)
+	assert.Equal(t, errUnsupportedListDate, err)
+
+	RegisterMonthName("Tammi.", time.January)
+
+	entry, err := parseListLine(line, now, time.UTC)
+	if assert.NoError(t, err) {
+		assert.Equal(t, newTime(2019, time.January, 5), entry.Time)
+	}
+}
+
+func TestSettimeYearBoundaries(t *testing.T) {
+	tests := []struct {
+		now      time.Time
+		line     string
+		expected time.Time
+	}{
+		// early January
+		{newTime(2024, time.January, 3, 12), "Jan  3 10:00", newTime(2024, time.January, 3, 10)},
+		{newTime(2024, time.January, 3, 12), "Jan  1 00:00", newTime(2024, time.January, 1)},
+		{newTime(2024, time.January, 3, 12), "Dec 31 23:59", newTime(2023, time.December, 31, 23, 59)},
+		{newTime(2024, time.January, 3, 12), "Jul  2 10:00", newTime(2023, time.July, 2, 10)},
+		{newTime(2024, time.January, 3, 12), "Jul  4 10:00", newTime(2023, time.July, 4, 10)},
+		{newTime(2024, time.January, 3, 12), "Jan  5 10:00", newTime(2023, time.January, 5, 10)},
+
+		// late December, with a server ahead of the client
+		{newTime(2023, time.December, 31, 20), "Dec 31 23:59", newTime(2023, time.December, 31, 23, 59)},
+		{newTime(2023, time.December, 31, 20), "Jan  1 05:00", newTime(2024, time.January, 1, 5)},
+		{newTime(2023, time.December, 31, 20), "Jun 30 10:00", newTime(2023, time.June, 30, 10)},
+
+		// leap days
+		{newTime(2024, time.March, 1, 12), "Feb 29 10:00", newTime(2024, time.February, 29, 10)},
+		{newTime(2025, time.March, 1, 12), "Feb 29 10:00", newTime(2024, time.February, 29, 10)},
+		{newTime(2100, time.March, 1, 12), "Feb 29 10:00", newTime(2096, time.February, 29, 10)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.now.String()+"/"+test.line, func(t *testing.T) {
+			entry := &Entry{}
+			if err := entry.setTime(strings.Fields(test.line), test.now, time.UTC); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, test.expected, entry.Time)
+		})
+	}
+}
+
 // newTime builds a UTC time from the given year, month, day, hour and minute
 func newTime(year int, month time.Month, day int, hourMinSec ...int) time.Time {
 	var hour, min, sec int
@@ -190,3 +623,57 @@ func newTime(year int, month time.Month, day int, hourMinSec ...int) time.Time {
 
 	return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
 }
+
+func TestListLineParsersSystem(t *testing.T) {
+	tests := []struct {
+		system   string
+		expected parseFunc
+	}{
+		{"", parseRFC3659ListLine},
+		{"UNIX Type: L8", parseRFC3659ListLine},
+		{"Windows_NT", parseDirListLine},
+		{"VMS V7.3", parseVmsListLine},
+		{"OS/400 is the remote operating system.", parseOS400ListLine},
+		{"NETWARE Type: L8", parseNetWareListLine},
+	}
+
+	for _, test := range tests {
+		parsers := newListLineParsers(false, test.system)
+		assert.Len(t, parsers, len(listLineParsers), test.system)
+		assert.Equal(t, reflect.ValueOf(test.expected).Pointer(), reflect.ValueOf(parsers[0]).Pointer(), test.system)
+	}
+
+	// The other parsers keep their order
+	parsers := newListLineParsers(true, "VMS V7.3")
+	assert.Equal(t, reflect.ValueOf(parseRFC3659ListLine).Pointer(), reflect.ValueOf(parsers[1]).Pointer())
+	assert.Equal(t, reflect.ValueOf(parseDirListLineDayFirst).Pointer(), reflect.ValueOf(parsers[3]).Pointer())
+	assert.Equal(t, reflect.ValueOf(parseOS400ListLineDayFirst).Pointer(), reflect.ValueOf(parsers[6]).Pointer())
+}
+
+func BenchmarkParseLsListLine(b *testing.B) {
+	lines := []string{
+		"drwxr-xr-x    3 110      1002            3 Dec 02  2009 pub",
+		"-rw-r--r--    1 marketwired marketwired    12016 Mar 16  2016 2016031611G087802-001.newsml",
+		"-rwxr-xr-x   1 root     other          7 Jan 25 00:17 bin",
+		"lrwxrwxrwx   1 root     other          7 Jan 25 00:17 bin -> usr/bin",
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			if _, err := parseLsListLine(line, now, time.UTC); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseListLine(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, lt := range listTests {
+			if _, err := parseListLine(lt.line, now, time.UTC); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/textproto"
+	"strings"
+)
+
+// PathError is returned when the server refuses an operation on a path, such
+// as Retr, Stor, Delete or MakeDir.
+//
+// It matches fs.ErrNotExist, fs.ErrExist or fs.ErrPermission with errors.Is
+// when the reply tells so. As servers word their replies differently, the
+// reply code is combined with common phrases of the message, e.g. a 550
+// reply saying "No such file or directory" matches fs.ErrNotExist.
+//
+// The errors of an Errors are PathError too, whatever their cause: Err may
+// then be any error, e.g. of the local file system.
+type PathError struct {
+	Op   string // the FTP command, e.g. DELE
+	Path string
+	Err  error // reply of the server, as a *textproto.Error, see Errors otherwise
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Op, e.Path, e.Err)
+}
+
+// Is reports whether target is fs.ErrNotExist, fs.ErrExist or fs.ErrPermission
+// and the reply of the server tells so.
+func (e *PathError) Is(target error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(e.Err, &protoErr) {
+		return false
+	}
+	kind := classifyReply(e.Op, protoErr.Code, protoErr.Msg)
+	return kind != nil && kind == target
+}
+
+// Unwrap returns the reply of the server.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// The phrases of the replies which tell why an operation on a path failed,
+// in lower case
+var (
+	existPhrases = []string{
+		"already exists", "file exists", "directory exists",
+	}
+	permissionPhrases = []string{
+		"permission denied", "access denied", "access is denied",
+		"not permitted", "not allowed", "forbidden", "insufficient privileges",
+	}
+	notExistPhrases = []string{
+		"no such file", "no such directory", "not found",
+		"does not exist", "doesn't exist", "cannot find", "can't find",
+	}
+)
+
+// classifyReply returns fs.ErrNotExist, fs.ErrExist or fs.ErrPermission when
+// the reply to the command op tells so, or nil.
+func classifyReply(op string, code int, msg string) error {
+	switch code {
+	case StatusFileActionIgnored, StatusFileUnavailable, StatusBadFileName:
+	default:
+		return nil
+	}
+
+	msg = strings.ToLower(msg)
+	switch {
+	case containsAny(msg, existPhrases):
+		return fs.ErrExist
+	case containsAny(msg, permissionPhrases):
+		return fs.ErrPermission
+	case containsAny(msg, notExistPhrases):
+		return fs.ErrNotExist
+	case op == "MLST" && code == StatusFileUnavailable:
+		// MLST only fails this way for missing files
+		return fs.ErrNotExist
+	}
+	return nil
+}
+
+// containsAny reports whether s contains any of the phrases
+func containsAny(s string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(s, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathError wraps the error of the command op on path in a *PathError when it
+// is a reply of the server.
+func pathError(op, path string, err error) error {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return err
+	}
+	return &PathError{Op: op, Path: path, Err: err}
+}
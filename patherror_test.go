@@ -0,0 +1,73 @@
+package ftp
+
+import (
+	"errors"
+	"io/fs"
+	"net/textproto"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyReply(t *testing.T) {
+	tests := []struct {
+		op       string
+		code     int
+		msg      string
+		expected error
+	}{
+		{"RETR", 550, "file.txt: No such file or directory", fs.ErrNotExist},
+		{"DELE", 550, "File not found", fs.ErrNotExist},
+		{"CWD", 550, "The system cannot find the file specified.", fs.ErrNotExist},
+		{"MLST", 550, "Unknown", fs.ErrNotExist},
+		{"SIZE", 550, "Could not get file size.", nil},
+		{"STOR", 553, "Could not create file: Permission denied", fs.ErrPermission},
+		{"DELE", 550, "Access is denied.", fs.ErrPermission},
+		{"STOR", 450, "Operation not permitted", fs.ErrPermission},
+		{"MKD", 550, "Create directory operation failed: Directory already exists", fs.ErrExist},
+		{"MKD", 550, "mydir: File exists", fs.ErrExist},
+		{"RETR", 425, "No such file", nil},
+		{"RETR", 226, "Transfer complete", nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, classifyReply(test.op, test.code, test.msg), test.msg)
+	}
+}
+
+func TestPathError(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"DELE locked.txt": "550 locked.txt: Permission denied",
+			"MKD incoming":    "550 incoming: Directory already exists",
+			"CWD missing":     "550 missing: No such file or directory",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	_, err := c.Retr("missing.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist), err)
+	var pathErr *PathError
+	if assert.True(t, errors.As(err, &pathErr)) {
+		assert.Equal(t, "RETR", pathErr.Op)
+		assert.Equal(t, "missing.txt", pathErr.Path)
+	}
+	var protoErr *textproto.Error
+	if assert.True(t, errors.As(err, &protoErr)) {
+		assert.Equal(t, StatusFileUnavailable, protoErr.Code)
+	}
+
+	err = c.Delete("locked.txt")
+	assert.True(t, errors.Is(err, fs.ErrPermission), err)
+	assert.False(t, errors.Is(err, fs.ErrNotExist))
+
+	err = c.MakeDir("incoming")
+	assert.True(t, errors.Is(err, fs.ErrExist), err)
+
+	err = c.ChangeDir("missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist), err)
+	assert.Equal(t, `CWD missing: 550 "missing: No such file or directory"`, err.Error())
+
+	assert.NoError(t, c.Quit())
+}
@@ -0,0 +1,59 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/textproto"
+	"path"
+)
+
+// RenameError is returned by Rename when the server refuses the source or
+// the destination of the rename.
+type RenameError struct {
+	Op   string // RNFR when the source is refused, RNTO for the destination
+	From string
+	To   string
+	Err  error // reply of the server
+}
+
+func (e *RenameError) Error() string {
+	return fmt.Sprintf("rename %s to %s: %s: %s", e.From, e.To, e.Op, e.Err)
+}
+
+// Is reports whether target is fs.ErrNotExist for a source which does not
+// exist, or fs.ErrPermission or fs.ErrExist for a destination which can not
+// be written, as servers reply 553 to RNTO in both cases. Other replies are
+// classified like the ones of PathError.
+func (e *RenameError) Is(target error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(e.Err, &protoErr) {
+		return false
+	}
+
+	switch {
+	case e.Op == "RNFR" && protoErr.Code == StatusFileUnavailable:
+		return target == fs.ErrNotExist
+	case e.Op == "RNTO" && protoErr.Code == StatusBadFileName:
+		return target == fs.ErrPermission || target == fs.ErrExist
+	}
+	kind := classifyReply(e.Op, protoErr.Code, protoErr.Msg)
+	return kind != nil && kind == target
+}
+
+// Unwrap returns the reply of the server.
+func (e *RenameError) Unwrap() error {
+	return e.Err
+}
+
+// Move renames from to to, like Rename, after creating the missing
+// directories of to with MakeDirAll, e.g. when moving /incoming/a.csv to
+// /archive/2024/a.csv.
+func (c *ServerConn) Move(from, to string) error {
+	if dir := path.Dir(to); dir != "." && dir != "/" {
+		if err := c.MakeDirAll(dir); err != nil {
+			return err
+		}
+	}
+	return c.Rename(from, to)
+}
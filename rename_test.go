@@ -0,0 +1,44 @@
+package ftp
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMove(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	err := c.Move("/incoming/a.csv", "/archive/2024/a.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "RNTO /archive/2024/a.csv", mock.lastFull)
+
+	err = c.Move("a.csv", "b.csv")
+	assert.NoError(t, err)
+
+	closeConn(t, mock, c, []string{"MKD", "MKD", "RNFR", "RNTO", "RNFR", "RNTO"})
+}
+
+func TestRenameErrors(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	err := c.Rename("/incoming/missing", "/archive/a.csv")
+	var renameErr *RenameError
+	if assert.True(t, errors.As(err, &renameErr)) {
+		assert.Equal(t, "RNFR", renameErr.Op)
+	}
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.False(t, errors.Is(err, fs.ErrPermission))
+
+	err = c.Rename("/incoming/a.csv", "/archive/denied")
+	if assert.True(t, errors.As(err, &renameErr)) {
+		assert.Equal(t, "RNTO", renameErr.Op)
+	}
+	assert.True(t, errors.Is(err, fs.ErrPermission))
+	assert.True(t, errors.Is(err, fs.ErrExist))
+	assert.False(t, errors.Is(err, fs.ErrNotExist))
+
+	closeConn(t, mock, c, []string{"RNFR", "RNFR", "RNTO"})
+}
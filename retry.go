@@ -0,0 +1,251 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// defaultRetryCodes are the replies retried when RetryPolicy.Codes is nil:
+// file busy and local error.
+var defaultRetryCodes = []int{StatusFileActionIgnored, StatusActionAborted}
+
+// RetryPolicy describes how the operations are retried after a transient
+// failure, see DialWithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first one. A
+	// value of 1 or less disables the retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the second attempt, doubled for each
+	// of the following ones.
+	BaseDelay time.Duration
+
+	// Jitter is the fraction of the delays which is random, between 0 and 1,
+	// so that clients failing together do not retry together.
+	Jitter float64
+
+	// Codes are the reply codes which are retried: 450 and 451 when nil.
+	// A 421 reply closes the connection, and is never retried on it, see
+	// ErrServerClosed.
+	Codes []int
+
+	// NetworkErrors retries the network errors, such as timeouts. Note that
+	// the control connection may be unusable after such an error.
+	NetworkErrors bool
+}
+
+// RetryError is returned when an operation failed after several attempts.
+type RetryError struct {
+	Attempts int
+	Err      error // error of the last attempt
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s (after %d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// DialWithRetryPolicy returns a DialOption that retries the idempotent
+// operations which fail transiently, following policy: List, FileSize,
+// GetTime, ChangeDir, and the start of the transfers of Retr and RetrFrom.
+//
+// The operations which could change the files on the server are never
+// retried, except the Stor and StorFrom calls explicitly marked safe with
+// TransferWithRetry. The waits between the attempts stop when the context
+// of DialWithContext is done.
+func DialWithRetryPolicy(policy RetryPolicy) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.retryPolicy = &policy
+	}}
+}
+
+// TransferWithRetry returns a TransferOption that marks a single Stor or
+// StorFrom call as safe to retry, following the policy of
+// DialWithRetryPolicy. It is only retried if its reader is an io.Seeker, to
+// read the data again from the start. StorRetry also resumes the uploads
+// which were interrupted, and fails when the reader is not an io.Seeker.
+func TransferWithRetry(safe bool) TransferOption {
+	return TransferOption{func(to *transferOptions) {
+		to.retry = safe
+	}}
+}
+
+// ErrNotSeekable is returned by StorRetry when its reader is not an
+// io.Seeker, as the data consumed by a failed attempt could not be sent
+// again.
+var ErrNotSeekable = errors.New("reader is not an io.Seeker")
+
+// StorRetry is like Stor, retrying the upload following the policy of
+// DialWithRetryPolicy when it fails transiently, including when the server
+// aborts it with a 426 reply, e.g. because the data connection dropped. r
+// must be an io.Seeker: otherwise StorRetry fails with ErrNotSeekable
+// without uploading anything.
+//
+// Once an attempt sent data, the upload is resumed from the size of the file
+// on the server, with SIZE and REST, when the server supports them and the
+// transfer type is binary. Otherwise, or if the file on the server is larger
+// than the data sent, e.g. because another file was there, r is rewound to
+// where it started and the file uploaded again.
+func (c *ServerConn) StorRetry(path string, r io.Reader, options ...TransferOption) error {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return &PathError{Op: "STOR", Path: path, Err: ErrNotSeekable}
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return &PathError{Op: "STOR", Path: path, Err: err}
+	}
+
+	to := newTransferOptions(options)
+	resume := c.restStreamSupported() && c.transferType(to) == TransferTypeBinary
+	if _, ok := c.features["SIZE"]; !ok {
+		resume = false
+	}
+
+	// The stats of each attempt tell how much of r was sent
+	var stats TransferStats
+	attempt := *to
+	attempt.stats = &stats
+
+	first := true
+	var sent int64 // bytes of r sent by the attempts, from start
+	return c.retryWhen(isAbortReply, func() error {
+		var offset int64
+		if !first {
+			if resume && sent > 0 {
+				// Uploaded again from scratch if the size is not known
+				if size, err := c.FileSize(path); err == nil && size <= sent {
+					offset = size
+				}
+			}
+			if _, err := seeker.Seek(start+offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		_, err := c.upload("STOR", path, r, uint64(offset), &attempt)
+		if stats.Bytes > 0 {
+			sent = offset + stats.Bytes
+		}
+		if to.stats != nil {
+			*to.stats = stats
+		}
+		return err
+	})
+}
+
+// retry calls fn until it succeeds, fails with an error which is not
+// transient, or the attempts of the retry policy are exhausted.
+func (c *ServerConn) retry(fn func() error) error {
+	return c.retryWhen(nil, fn)
+}
+
+// retryWhen is like retry, also retrying the errors for which transient
+// returns true.
+func (c *ServerConn) retryWhen(transient func(error) bool, fn func() error) error {
+	p := c.options.retryPolicy
+	if p == nil || p.MaxAttempts <= 1 {
+		return fn()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		retryable := p.retryable(err) || (transient != nil && transient(err))
+		if !retryable || attempt == p.MaxAttempts {
+			if attempt == 1 {
+				return err
+			}
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+
+		if errWait := c.waitRetry(p.delay(attempt)); errWait != nil {
+			return &RetryError{Attempts: attempt, Err: multierror.Append(err, errWait)}
+		}
+	}
+}
+
+// retryReader is like retry, rewinding r before each new attempt. Readers
+// which can not be rewound are not retried.
+func (c *ServerConn) retryReader(r io.Reader, fn func() error) error {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return fn()
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fn()
+	}
+
+	first := true
+	return c.retry(func() error {
+		if !first {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+		return fn()
+	})
+}
+
+// waitRetry waits for d, or until the context of the connection is done.
+func (c *ServerConn) waitRetry(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	if c.options.context == nil {
+		<-timer.C
+		return nil
+	}
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.options.context.Done():
+		return c.options.context.Err()
+	}
+}
+
+// retryable reports whether err is a transient failure according to p.
+func (p *RetryPolicy) retryable(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		codes := p.Codes
+		if codes == nil {
+			codes = defaultRetryCodes
+		}
+		for _, code := range codes {
+			if protoErr.Code == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return p.NetworkErrors && errors.As(err, &netErr)
+}
+
+// delay returns the delay before the attempt following the attempt-th one.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
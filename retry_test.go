@@ -0,0 +1,145 @@
+package ftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5}
+	mock, c := openConnScenario(t, "127.0.0.1", "busy", DialWithRetryPolicy(policy))
+
+	assert.NoError(t, c.ChangeDir("incoming"))
+	_, err := c.List("")
+	assert.NoError(t, err)
+
+	// Not retried unless marked safe
+	assert.Error(t, c.Stor("file", strings.NewReader(testData)))
+	assert.NoError(t, c.Stor("file", strings.NewReader(testData), TransferWithRetry(true)))
+	assert.Equal(t, testData, mock.fileCont.String())
+
+	_, err = c.FileSize("magic-file")
+	assert.NoError(t, err)
+
+	r, err := c.Retr("file")
+	if assert.NoError(t, err) {
+		buf, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, string(buf))
+		assert.NoError(t, r.Close())
+	}
+
+	assert.Error(t, c.Delete("file"))
+
+	closeConn(t, mock, c, []string{
+		"CWD", "CWD",
+		"EPSV", "LIST", "EPSV", "LIST",
+		"EPSV", "STOR", "EPSV", "STOR",
+		"SIZE", "SIZE",
+		"EPSV", "RETR", "EPSV", "RETR",
+		"DELE",
+	})
+}
+
+func TestRetryError(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, Codes: []int{StatusFileUnavailable}}
+	mock, c := openConn(t, "127.0.0.1", DialWithRetryPolicy(policy))
+
+	err := c.ChangeDir("missing-dir")
+	var retryErr *RetryError
+	if assert.True(t, errors.As(err, &retryErr)) {
+		assert.Equal(t, 2, retryErr.Attempts)
+	}
+	assert.True(t, isNotFound(err))
+
+	closeConn(t, mock, c, []string{"CWD", "CWD"})
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour, Codes: []int{StatusFileUnavailable}}
+	mock, c := openConn(t, "127.0.0.1", DialWithContext(ctx), DialWithRetryPolicy(policy))
+	cancel()
+
+	err := c.ChangeDir("missing-dir")
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	closeConn(t, mock, c, []string{"CWD"})
+}
+
+func TestStorRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	for _, test := range []struct {
+		name   string
+		feat   string
+		resume bool
+	}{
+		{"resumed", "211-Features:\r\n EPSV\r\n SIZE\r\n REST STREAM\r\n211 End", true},
+		{"restarted", "211-Features:\r\n EPSV\r\n SIZE\r\n211 End", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := ftptest.NewServer(ftptest.Script{
+				Replies: map[string]string{"FEAT": test.feat},
+				Files:   map[string][]byte{},
+				Transfers: map[string]ftptest.TransferReplies{
+					"STOR /file": {AbortAfter: 10},
+				},
+			})
+			defer s.Close()
+			c := dialScript(t, s, DialWithRetryPolicy(policy))
+
+			r := strings.NewReader("header" + testData)
+			_, _ = r.Seek(6, io.SeekStart)
+			assert.NoError(t, c.StorRetry("/file", r))
+
+			data, _ := s.File("/file")
+			assert.Equal(t, testData, string(data))
+			assert.Equal(t, 2, countCommands(s, "STOR"))
+			if test.resume {
+				assert.Contains(t, s.Commands(), "REST 10")
+			} else {
+				assert.Zero(t, countCommands(s, "REST"))
+			}
+			assert.NoError(t, c.Quit())
+		})
+	}
+}
+
+func TestStorRetryNotStarted(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT": "211-Features:\r\n EPSV\r\n SIZE\r\n REST STREAM\r\n211 End",
+		},
+		RepliesOnce: map[string]string{"STOR /file": "450 File busy"},
+		Files:       map[string][]byte{"/file": []byte("a previous and longer content")},
+	})
+	defer s.Close()
+	c := dialScript(t, s, DialWithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+
+	// The first attempt sent nothing: the file is uploaded from scratch
+	assert.NoError(t, c.StorRetry("/file", strings.NewReader(testData)))
+	data, _ := s.File("/file")
+	assert.Equal(t, testData, string(data))
+	assert.Equal(t, 2, countCommands(s, "STOR"))
+	assert.Zero(t, countCommands(s, "REST"))
+	assert.NoError(t, c.Quit())
+}
+
+func TestStorRetryNotSeekable(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s, DialWithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+	err := c.StorRetry("/file", io.MultiReader(strings.NewReader(testData)))
+	assert.True(t, errors.Is(err, ErrNotSeekable), "%v", err)
+	assert.Zero(t, countCommands(s, "STOR"))
+	assert.NoError(t, c.Quit())
+}
@@ -1,21 +1,30 @@
 package ftp
 
-// A scanner for fields delimited by one or more whitespace characters
+// A scanner for fields delimited by one or more whitespace characters.
+//
+// The fields are substrings of the scanned string rather than copies, so
+// that parsing a line does not allocate one string per field: the entries
+// parsed from a line share its memory, which they keep as Raw anyway.
 type scanner struct {
-	bytes    []byte
+	str      string
 	position int
 }
 
 // newScanner creates a new scanner
 func newScanner(str string) *scanner {
 	return &scanner{
-		bytes: []byte(str),
+		str: str,
 	}
 }
 
 // NextFields returns the next `count` fields
 func (s *scanner) NextFields(count int) []string {
-	fields := make([]string, 0, count)
+	return s.AppendFields(make([]string, 0, count), count)
+}
+
+// AppendFields appends the next `count` fields to fields, which lets the
+// callers provide an array on their stack.
+func (s *scanner) AppendFields(fields []string, count int) []string {
 	for i := 0; i < count; i++ {
 		if field := s.Next(); field != "" {
 			fields = append(fields, field)
@@ -28,11 +37,11 @@ func (s *scanner) NextFields(count int) []string {
 
 // Next returns the next field
 func (s *scanner) Next() string {
-	sLen := len(s.bytes)
+	sLen := len(s.str)
 
 	// skip trailing whitespace
 	for s.position < sLen {
-		if s.bytes[s.position] != ' ' {
+		if s.str[s.position] != ' ' {
 			break
 		}
 		s.position++
@@ -42,17 +51,17 @@ func (s *scanner) Next() string {
 
 	// skip non-whitespace
 	for s.position < sLen {
-		if s.bytes[s.position] == ' ' {
+		if s.str[s.position] == ' ' {
 			s.position++
-			return string(s.bytes[start : s.position-1])
+			return s.str[start : s.position-1]
 		}
 		s.position++
 	}
 
-	return string(s.bytes[start:s.position])
+	return s.str[start:s.position]
 }
 
 // Remaining returns the remaining string
 func (s *scanner) Remaining() string {
-	return string(s.bytes[s.position:len(s.bytes)])
+	return s.str[s.position:]
 }
@@ -0,0 +1,198 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSizeMismatch is returned when a download does not deliver the number of
+// bytes announced by the server.
+var ErrSizeMismatch = errors.New("downloaded size does not match the file size")
+
+// DownloadSegmented downloads the file at path into w, fetching segments
+// parts of it in parallel over as many connections, each returned by dial
+// already logged in.
+//
+// It falls back to a single stream if the server does not support the SIZE
+// or REST STREAM commands. If a segment fails, the other ones are cancelled.
+// The connections are closed before returning. The file is always
+// transferred in binary mode, as offsets are meaningless in ASCII mode.
+//
+// Failures are returned as Errors.
+func DownloadSegmented(dial func() (*ServerConn, error), path string, w io.WriterAt, segments int) error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+
+	size := int64(-1)
+	if _, ok := c.features["SIZE"]; ok {
+		if s, err := c.FileSize(path); err == nil {
+			size = s
+		}
+	}
+
+	if segments < 2 || size < int64(segments) || !c.restStreamSupported() {
+		err = downloadSegment(c, path, w, 0, size, true)
+		return closeSegmentConns(path, err, c)
+	}
+
+	conns := []*ServerConn{c}
+	for i := 1; i < segments; i++ {
+		c, err := dial()
+		if err != nil {
+			return closeSegmentConns(path, err, conns...)
+		}
+		conns = append(conns, c)
+	}
+
+	s := &segmentedDownload{}
+	segmentSize := size / int64(segments)
+
+	var wg sync.WaitGroup
+	for i, c := range conns {
+		offset := int64(i) * segmentSize
+		length := segmentSize
+		last := i == len(conns)-1
+		if last {
+			length = size - offset
+		}
+
+		wg.Add(1)
+		go func(c *ServerConn) {
+			defer wg.Done()
+			s.fail(s.download(c, path, w, offset, length, last))
+		}(c)
+	}
+	wg.Wait()
+
+	return closeSegmentConns(path, s.err, conns...)
+}
+
+// segmentedDownload tracks the transfers of DownloadSegmented, to cancel
+// them all when one fails.
+type segmentedDownload struct {
+	mu        sync.Mutex
+	responses []*Response
+	err       error
+}
+
+// download fetches one segment, unless another one already failed.
+func (s *segmentedDownload) download(c *ServerConn, path string, w io.WriterAt, offset, length int64, last bool) error {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	r, err := c.RetrFrom(path, uint64(offset), TransferWithType(TransferTypeBinary))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	cancelled := s.err != nil
+	s.responses = append(s.responses, r)
+	s.mu.Unlock()
+	if cancelled {
+		_ = r.SetDeadline(time.Now())
+	}
+
+	return copySegment(r, w, offset, length, last)
+}
+
+// fail records the first error, and cancels the running transfers.
+func (s *segmentedDownload) fail(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	for _, r := range s.responses {
+		_ = r.SetDeadline(time.Now())
+	}
+}
+
+// downloadSegment fetches length bytes of path starting at offset, or the
+// whole remaining file if length is negative.
+func downloadSegment(c *ServerConn, path string, w io.WriterAt, offset, length int64, last bool) error {
+	r, err := c.RetrFrom(path, uint64(offset), TransferWithType(TransferTypeBinary))
+	if err != nil {
+		return err
+	}
+	return copySegment(r, w, offset, length, last)
+}
+
+// copySegment copies a segment from r to w and closes r. The transfer of
+// segments which end before the end of the file is aborted once they are
+// complete.
+func copySegment(r *Response, w io.WriterAt, offset, length int64, last bool) error {
+	dst := &offsetWriter{w: w, offset: offset}
+
+	var n int64
+	var err error
+	if last {
+		n, err = io.Copy(dst, r)
+	} else {
+		n, err = io.CopyN(dst, r, length)
+		if err == nil {
+			// Stop the transfer of the rest of the file
+			r.err = errSegmentDone
+		}
+	}
+
+	var errs Errors
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := r.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if errs == nil && length >= 0 && n != length {
+		errs = append(errs, fmt.Errorf("%w: %d bytes instead of %d at offset %d", ErrSizeMismatch, n, length, offset))
+	}
+	return errs.errorOrNil()
+}
+
+// errSegmentDone marks a Response whose transfer must be aborted on Close
+var errSegmentDone = errors.New("segment downloaded")
+
+// restStreamSupported reports whether the server supports restarting stream
+// mode transfers at an offset
+func (c *ServerConn) restStreamSupported() bool {
+	param, ok := c.features["REST"]
+	return ok && strings.Contains(strings.ToUpper(param), "STREAM")
+}
+
+// closeSegmentConns quits the connections of the DownloadSegmented of path
+func closeSegmentConns(path string, err error, conns ...*ServerConn) error {
+	var errs Errors
+	errs.add("RETR", path, err)
+	for _, c := range conns {
+		errs.add("QUIT", path, c.Quit())
+	}
+	return errs.errorOrNil()
+}
+
+// offsetWriter writes sequentially to an io.WriterAt from an offset
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (w *offsetWriter) Write(buf []byte) (int, error) {
+	n, err := w.w.WriteAt(buf, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
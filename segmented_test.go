@@ -0,0 +1,84 @@
+package ftp
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writerAtBuffer is an in-memory io.WriterAt
+type writerAtBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if end := int(off) + len(p); end > len(b.buf) {
+		b.buf = append(b.buf, make([]byte, end-len(b.buf))...)
+	}
+	return copy(b.buf[off:], p), nil
+}
+
+// segmentDialer opens connections to new mock servers serving data
+type segmentDialer struct {
+	t     *testing.T
+	data  string
+	mocks []*ftpMock
+}
+
+func (d *segmentDialer) dial() (*ServerConn, error) {
+	mock, c := openConn(d.t, "127.0.0.1")
+	d.mocks = append(d.mocks, mock)
+	if err := c.Stor("file", bytes.NewBufferString(d.data)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (d *segmentDialer) wait() {
+	for _, mock := range d.mocks {
+		mock.Wait()
+	}
+}
+
+func TestDownloadSegmented(t *testing.T) {
+	data := "0123456789abcdefghijklmnopqrstuvwxyz"
+	d := &segmentDialer{t: t, data: data}
+
+	w := &writerAtBuffer{}
+	err := DownloadSegmented(d.dial, "sized-file", w, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(w.buf))
+	assert.Len(t, d.mocks, 3)
+	d.wait()
+
+	// without SIZE, a single stream is used
+	d = &segmentDialer{t: t, data: data}
+	w = &writerAtBuffer{}
+	err = DownloadSegmented(d.dial, "file", w, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(w.buf))
+	assert.Len(t, d.mocks, 1)
+	d.wait()
+}
+
+func TestDownloadSegmentedDialError(t *testing.T) {
+	data := "0123456789abcdefghijklmnopqrstuvwxyz"
+	d := &segmentDialer{t: t, data: data}
+
+	errDial := errors.New("dial failed")
+	err := DownloadSegmented(func() (*ServerConn, error) {
+		if len(d.mocks) == 2 {
+			return nil, errDial
+		}
+		return d.dial()
+	}, "sized-file", &writerAtBuffer{}, 3)
+	assert.True(t, errors.Is(err, errDial))
+	d.wait()
+}
@@ -0,0 +1,40 @@
+package ftp
+
+import (
+	"fmt"
+	"net/textproto"
+	"os"
+)
+
+// Site issues a SITE FTP command with the server specific command, e.g.
+// "UMASK 022" or "FILETYPE=JES", and returns the reply of the server.
+//
+// All the 2xx replies are successes, including 202 which some servers send
+// for parameters which are already set. The 500 and 502 replies return an
+// error matching ErrCommandNotSupported.
+func (c *ServerConn) Site(command string) (code int, msg string, err error) {
+	code, msg, err = c.cmd(-1, "SITE %s", command)
+	if err != nil {
+		return code, msg, err
+	}
+
+	switch {
+	case code >= 200 && code < 300:
+		return code, msg, nil
+	case code == StatusBadCommand || code == StatusNotImplemented:
+		return code, msg, fmt.Errorf("SITE %s: %w", command, ErrCommandNotSupported)
+	}
+	return code, msg, &textproto.Error{Code: code, Msg: msg}
+}
+
+// Chmod changes the permissions of path to the permission bits of mode, with
+// a SITE CHMOD command, e.g. "SITE CHMOD 755 script.sh". Once SiteHelp was
+// called, it fails with ErrCommandNotSupported without sending the command
+// if the server does not list CHMOD.
+func (c *ServerConn) Chmod(path string, mode os.FileMode) error {
+	if listed, known := helpLists(c.siteHelp, "CHMOD"); known && !listed {
+		return fmt.Errorf("SITE CHMOD: %w", ErrCommandNotSupported)
+	}
+	_, _, err := c.Site(fmt.Sprintf("CHMOD %03o %s", mode.Perm(), path))
+	return err
+}
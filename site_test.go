@@ -0,0 +1,40 @@
+package ftp
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChmod(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	assert.NoError(t, c.Chmod("script.sh", 0755))
+	assert.Equal(t, "SITE CHMOD 755 script.sh", mock.lastFull)
+
+	assert.NoError(t, c.Chmod("secret", 0600|os.ModeSetuid))
+	assert.Equal(t, "SITE CHMOD 600 secret", mock.lastFull)
+
+	closeConn(t, mock, c, []string{"SITE", "SITE"})
+}
+
+func TestSite(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	code, msg, err := c.Site("UMASK 022")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCommandOK, code)
+	assert.Equal(t, "SITE command was accepted", msg)
+
+	code, _, err = c.Site("BOGUS")
+	assert.True(t, errors.Is(err, ErrCommandNotSupported))
+	assert.Equal(t, StatusBadCommand, code)
+
+	_, _, err = c.Site("UNIT=BAD")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrCommandNotSupported))
+
+	closeConn(t, mock, c, []string{"SITE", "SITE", "SITE"})
+}
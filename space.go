@@ -0,0 +1,96 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AvailableSpace returns the number of bytes available to store files in
+// path, or in the current directory if path is empty, with the AVBL command.
+// ErrCommandNotSupported is returned when the server does not advertise it.
+func (c *ServerConn) AvailableSpace(path string) (uint64, error) {
+	if _, ok := c.features["AVBL"]; !ok {
+		return 0, ErrCommandNotSupported
+	}
+
+	var msg string
+	var err error
+	if path == "" {
+		_, msg, err = c.cmd(StatusFile, "AVBL")
+	} else {
+		_, msg, err = c.cmd(StatusFile, "AVBL %s", path)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Some servers follow the number with a text, e.g. "1234 bytes free"
+	fields := strings.Fields(msg)
+	if len(fields) == 0 {
+		return 0, errors.New("invalid AVBL reply: " + msg)
+	}
+	return strconv.ParseUint(fields[0], 10, 64)
+}
+
+// TransferWithAllocation returns a TransferOption that sends an ALLO command
+// with size before an upload, so that the servers enforcing quotas can
+// refuse it before any data is transferred. A negative size uses the size of
+// the data left in the reader, when it is an *os.File of a regular file or
+// has a Len method like *bytes.Reader, *bytes.Buffer and *strings.Reader: no
+// ALLO command is sent otherwise.
+//
+// The servers which do not implement ALLO are not an error.
+func TransferWithAllocation(size int64) TransferOption {
+	return TransferOption{func(to *transferOptions) {
+		to.allocate = true
+		to.allocSize = size
+	}}
+}
+
+// allocate sends the ALLO command of an upload reading r, if requested.
+func (c *ServerConn) allocate(r io.Reader, to *transferOptions) error {
+	if !to.allocate {
+		return nil
+	}
+
+	size := to.allocSize
+	if size < 0 {
+		var ok bool
+		if size, ok = readerSize(r); !ok {
+			return nil
+		}
+	}
+
+	code, msg, err := c.cmd(-1, "ALLO %d", size)
+	if err != nil {
+		return err
+	}
+	if code >= 400 && code != StatusBadCommand && code != StatusNotImplemented {
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+	return nil
+}
+
+// readerSize returns the number of bytes left in r, if it can be known
+// without reading it.
+func readerSize(r io.Reader) (int64, bool) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		pos, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		return info.Size() - pos, true
+	}
+	return 0, false
+}
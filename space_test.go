@@ -0,0 +1,104 @@
+package ftp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailableSpace(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT":        "211-Features:\r\n EPSV\r\n AVBL\r\n211 End",
+			"AVBL":        "213 1048576",
+			"AVBL /full":  "213 0 bytes available",
+			"AVBL /nodir": "550 No such directory",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	n, err := c.AvailableSpace("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1048576), n)
+
+	n, err = c.AvailableSpace("/full")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+
+	_, err = c.AvailableSpace("/nodir")
+	assert.Error(t, err)
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestAvailableSpaceNotSupported(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	_, err := c.AvailableSpace("")
+	assert.True(t, errors.Is(err, ErrCommandNotSupported))
+	assert.NotContains(t, s.Commands(), "AVBL")
+
+	assert.NoError(t, c.Quit())
+}
+
+func TestTransferWithAllocation(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"ALLO 14":   "200 ALLO command successful",
+			"ALLO 2048": "552 Quota exceeded",
+			"ALLO 5":    "502 Command not implemented",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	assert.NoError(t, c.Stor("reader", strings.NewReader(testData), TransferWithAllocation(-1)))
+	assert.NoError(t, c.Stor("buffer", bytes.NewBufferString(testData), TransferWithAllocation(-1)))
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, _ = f.WriteString(testData)
+	_, _ = f.Seek(0, 0)
+	assert.NoError(t, c.Stor("file", f, TransferWithAllocation(-1)))
+	data, _ := s.File("file")
+	assert.Equal(t, testData, string(data))
+
+	// Unknown size
+	assert.NoError(t, c.Stor("pipe", io.LimitReader(strings.NewReader(testData), 100), TransferWithAllocation(-1)))
+
+	// Rejected before the transfer
+	err = c.Stor("big", strings.NewReader(testData), TransferWithAllocation(2048))
+	var protoErr *textproto.Error
+	if assert.True(t, errors.As(err, &protoErr)) {
+		assert.Equal(t, StatusExceededStorage, protoErr.Code)
+	}
+	_, ok := s.File("big")
+	assert.False(t, ok)
+
+	// Not implemented by the server
+	assert.NoError(t, c.Stor("small", strings.NewReader("small"), TransferWithAllocation(-1)))
+
+	var allo []string
+	for _, cmd := range s.Commands() {
+		if strings.HasPrefix(cmd, "ALLO") {
+			allo = append(allo, cmd)
+		}
+	}
+	assert.Equal(t, []string{"ALLO 14", "ALLO 14", "ALLO 14", "ALLO 2048", "ALLO 5"}, allo)
+
+	assert.NoError(t, c.Quit())
+}
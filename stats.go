@@ -0,0 +1,55 @@
+package ftp
+
+import (
+	"time"
+)
+
+// TransferStats describes the timing of a transfer, see Response.Stats and
+// TransferWithStats.
+type TransferStats struct {
+	Bytes int64 // number of bytes transferred
+
+	// Duration is the wall-clock time of the transfer, from the setup of
+	// the data connection to the reply of the server at the end.
+	Duration time.Duration
+
+	// TimeToFirstByte is the time from the start of the transfer until the
+	// first byte of a download is received, or until the server is ready
+	// to receive an upload. It isolates the delays of the server, such as
+	// the recall of a data set from tape, from the throughput of the
+	// network. It is zero for a download which received nothing.
+	TimeToFirstByte time.Duration
+}
+
+// Throughput returns the average throughput of the transfer, in bytes per
+// second.
+func (s TransferStats) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Duration.Seconds()
+}
+
+// TransferWithStats returns a TransferOption filling stats at the end of the
+// transfer, whether it succeeds or fails: when Stor returns, or when the
+// Response of Retr is closed.
+func TransferWithStats(stats *TransferStats) TransferOption {
+	return TransferOption{func(to *transferOptions) {
+		to.stats = stats
+	}}
+}
+
+// Stats returns the stats of the download. The Duration is the one of the
+// whole transfer once the Response is closed, and the time elapsed so far
+// before.
+func (r *Response) Stats() TransferStats {
+	duration := r.duration
+	if !r.closed {
+		duration = time.Since(r.start)
+	}
+	return TransferStats{
+		Bytes:           r.BytesRead(),
+		Duration:        duration,
+		TimeToFirstByte: r.firstByte,
+	}
+}
@@ -0,0 +1,61 @@
+package ftp
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferStats(t *testing.T) {
+	s := ftptest.NewServer(ftptest.Script{
+		Files: map[string][]byte{
+			"/file": []byte(testData),
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s)
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	// Downloads read with Read, or handed to an io.ReaderFrom
+	for _, w := range []io.Writer{&bytes.Buffer{}, f} {
+		var stats TransferStats
+		r, err := c.Retr("/file", TransferWithStats(&stats))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		_, err = io.Copy(w, r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+
+		assert.Equal(t, r.Stats(), stats)
+		assert.Equal(t, int64(len(testData)), stats.Bytes)
+		assert.NotZero(t, stats.TimeToFirstByte)
+		assert.True(t, stats.TimeToFirstByte <= stats.Duration)
+		assert.NotZero(t, stats.Throughput())
+	}
+
+	var stats TransferStats
+	assert.NoError(t, c.Stor("/upload", bytes.NewBufferString(testData), TransferWithStats(&stats)))
+	assert.Equal(t, int64(len(testData)), stats.Bytes)
+	assert.NotZero(t, stats.TimeToFirstByte)
+	assert.True(t, stats.TimeToFirstByte <= stats.Duration)
+
+	// Failed transfers are timed too
+	stats = TransferStats{}
+	_, err = c.Retr("/missing", TransferWithStats(&stats))
+	assert.Error(t, err)
+	assert.NotZero(t, stats.Duration)
+	assert.Zero(t, stats.TimeToFirstByte)
+
+	assert.NoError(t, c.Quit())
+}
@@ -0,0 +1,119 @@
+package ftp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DialWithRateLimit returns a DialOption that caps the throughput of the
+// data connections to bytesPerSec bytes per second, shared by uploads,
+// downloads and listings. Zero means unlimited. See SetRateLimit.
+func DialWithRateLimit(bytesPerSec int64) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.rateLimit = bytesPerSec
+	}}
+}
+
+// SetRateLimit changes the maximum throughput of the data connections, in
+// bytes per second. Zero means unlimited. It may be called while a transfer
+// is running, which then adapts its pace.
+func (c *ServerConn) SetRateLimit(bytesPerSec int64) {
+	c.limiter.setRate(bytesPerSec)
+}
+
+// rateLimiter is a token bucket holding up to one second of transfer.
+type rateLimiter struct {
+	rate int64 // bytes per second, accessed atomically
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{
+		rate:  bytesPerSec,
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+}
+
+func (l *rateLimiter) setRate(bytesPerSec int64) {
+	atomic.StoreInt64(&l.rate, bytesPerSec)
+}
+
+// burst returns the maximum number of bytes to transfer at once, or 0 if
+// the throughput is not limited.
+func (l *rateLimiter) burst() int {
+	return int(atomic.LoadInt64(&l.rate))
+}
+
+// wait accounts for n bytes transferred, and blocks as long as needed to
+// keep the throughput under the limit.
+func (l *rateLimiter) wait(n int) {
+	rate := float64(atomic.LoadInt64(&l.rate))
+	if rate <= 0 || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+		if l.tokens > rate {
+			l.tokens = rate
+		}
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		l.sleep(delay)
+	}
+}
+
+// throttledConn limits the throughput of a data connection
+type throttledConn struct {
+	net.Conn
+	limiter *rateLimiter
+	buffers *sync.Pool // for ReadFrom
+}
+
+func (c *throttledConn) Read(buf []byte) (int, error) {
+	if burst := c.limiter.burst(); burst > 0 && len(buf) > burst {
+		buf = buf[:burst]
+	}
+
+	n, err := c.Conn.Read(buf)
+	c.limiter.wait(n)
+	return n, err
+}
+
+func (c *throttledConn) Write(buf []byte) (written int, err error) {
+	for len(buf) > 0 {
+		chunk := buf
+		if burst := c.limiter.burst(); burst > 0 && len(chunk) > burst {
+			chunk = chunk[:burst]
+		}
+
+		c.limiter.wait(len(chunk))
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		buf = buf[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,71 @@
+package ftp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is advanced by the sleeps of a rateLimiter
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+	c.slept += d
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := newRateLimiter(100)
+	l.now, l.sleep = clock.Now, clock.Sleep
+
+	for i := 0; i < 10; i++ {
+		l.wait(l.burst())
+	}
+	assert.Equal(t, 10*time.Second, clock.slept)
+
+	// time spent elsewhere fills the bucket, up to one second of transfer
+	clock.now = clock.now.Add(time.Hour)
+	clock.slept = 0
+	l.wait(100)
+	assert.Equal(t, time.Duration(0), clock.slept)
+	l.wait(50)
+	assert.Equal(t, 500*time.Millisecond, clock.slept)
+
+	// zero means unlimited
+	clock.slept = 0
+	l.setRate(0)
+	assert.Equal(t, 0, l.burst())
+	l.wait(1 << 30)
+	assert.Equal(t, time.Duration(0), clock.slept)
+}
+
+func TestRateLimit(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithRateLimit(100))
+
+	// The pace is measured on a fake clock, not to depend on the load of
+	// the machine
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c.limiter.now, c.limiter.sleep = clock.Now, clock.Sleep
+
+	data := bytes.Repeat([]byte{'x'}, 20)
+	assert.NoError(t, c.Stor("file", bytes.NewReader(data)))
+	assert.Equal(t, 200*time.Millisecond, clock.slept)
+
+	c.SetRateLimit(0)
+	clock.slept = 0
+	assert.NoError(t, c.Stor("file", bytes.NewReader(data)))
+	assert.Equal(t, time.Duration(0), clock.slept)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
@@ -0,0 +1,242 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"time"
+
+	"golang.org/x/text/encoding"
+)
+
+// ErrMaxSizeExceeded is matched by the errors returned when a transfer
+// exceeds the limit set with TransferWithMaxBytes or DialWithMaxDownloadBytes.
+var ErrMaxSizeExceeded = errors.New("maximum transfer size exceeded")
+
+// MaxSizeError is returned when a download was stopped because it exceeded
+// a size limit.
+type MaxSizeError struct {
+	Limit   int64 // the limit which was crossed, in bytes
+	Written int64 // number of bytes handed to the caller before aborting
+}
+
+func (e *MaxSizeError) Error() string {
+	return fmt.Sprintf("%s: limit is %d bytes, %d bytes written", ErrMaxSizeExceeded, e.Limit, e.Written)
+}
+
+// Is reports whether target is ErrMaxSizeExceeded.
+func (e *MaxSizeError) Is(target error) bool {
+	return target == ErrMaxSizeExceeded
+}
+
+// TransferOption represents an option for a single transfer
+type TransferOption struct {
+	setup func(to *transferOptions)
+}
+
+// transferOptions contains all the options set by TransferOption.setup
+type transferOptions struct {
+	maxBytes      int64
+	progressFn    func(transferred int64)
+	progressEvery int64
+
+	transferType    TransferType
+	transferTypeSet bool // transferType overrides the one of the connection
+
+	encoding encoding.Encoding // character set of the data, decoded on the client
+
+	retry bool // the upload may be retried
+
+	allocate  bool  // send ALLO before an upload
+	allocSize int64 // size given to ALLO, negative for the size of the reader
+
+	stats *TransferStats // filled at the end of the transfer
+}
+
+// TransferWithMaxBytes returns a TransferOption limiting the number of bytes
+// a download may deliver. Once the limit is crossed, the transfer is aborted
+// and reading fails with a *MaxSizeError. Zero means unlimited.
+//
+// When the server supports the SIZE command, the size of the file is checked
+// before the transfer is started.
+func TransferWithMaxBytes(n int64) TransferOption {
+	return TransferOption{func(to *transferOptions) {
+		to.maxBytes = n
+	}}
+}
+
+// TransferWithProgress returns a TransferOption calling fn with the number of
+// bytes transferred so far, each time at least every more bytes went through,
+// or after each read if every is 0 or less. A last call with the total is
+// always made once the transfer completes or fails.
+//
+// fn is called from the goroutine doing the transfer, and must return
+// quickly not to slow it down.
+func TransferWithProgress(fn func(transferred int64), every int64) TransferOption {
+	return TransferOption{func(to *transferOptions) {
+		to.progressFn = fn
+		to.progressEvery = every
+	}}
+}
+
+func newTransferOptions(options []TransferOption) *transferOptions {
+	to := &transferOptions{}
+	for _, option := range options {
+		option.setup(to)
+	}
+	return to
+}
+
+// downloadLimit returns the number of bytes a download which already
+// delivered read bytes may still transfer, according to its own maxBytes
+// limit and to the connection limit, along with the limit which applies.
+// It returns -1 if there is no limit.
+func (c *ServerConn) downloadLimit(maxBytes, read int64) (remaining, limit int64) {
+	remaining, limit = -1, -1
+	if maxBytes > 0 {
+		remaining, limit = maxBytes-read, maxBytes
+	}
+	if max := c.options.maxDownloadBytes; max > 0 {
+		left := max - c.downloaded
+		if left < 0 {
+			left = 0
+		}
+		if remaining < 0 || left < remaining {
+			remaining, limit = left, max
+		}
+	}
+	return remaining, limit
+}
+
+// checkDownloadSize fails early if the size of the file reported by the
+// server does not fit into the remaining download limit. Errors of the SIZE
+// command are ignored, as the limit is enforced during the transfer anyway.
+func (c *ServerConn) checkDownloadSize(path string, offset uint64, maxBytes int64) error {
+	remaining, limit := c.downloadLimit(maxBytes, 0)
+	if remaining < 0 {
+		return nil
+	}
+	if _, ok := c.features["SIZE"]; !ok {
+		return nil
+	}
+
+	size, err := c.FileSize(path)
+	if err != nil {
+		return nil
+	}
+	if size-int64(offset) > remaining {
+		return &MaxSizeError{Limit: limit}
+	}
+	return nil
+}
+
+// abortTransfer issues an ABOR FTP command after the data connection of a
+// transfer was closed, and consumes the replies of both the transfer and
+// the ABOR command so that the control connection stays usable.
+func (c *ServerConn) abortTransfer() error {
+	if err := c.sendCmd("ABOR"); err != nil {
+		return err
+	}
+
+	// The first reply ends the transfer: 426 if it was interrupted, or 226
+	// if it completed before the server noticed. The second one is the
+	// reply to ABOR itself. A preliminary reply to the transfer command may
+	// still be pending.
+	code, _, err := c.readCompletion()
+	if err != nil {
+		return err
+	}
+	if code == StatusDataConnectionOpen {
+		// Some servers directly answer ABOR when no transfer is running.
+		return nil
+	}
+
+	_, _, err = c.readResponse(-1)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// The data left when a Response is closed before the end of the transfer
+// is drained within these limits, see Response.Close.
+const (
+	closeDrainLimit   = 64 << 10
+	closeDrainTimeout = 500 * time.Millisecond
+)
+
+// drain reads what is left of the transfer within the limits, so that a
+// transfer closed near its end still completes normally.
+func (r *Response) drain() {
+	if err := r.conn.SetDeadline(time.Now().Add(closeDrainTimeout)); err != nil {
+		return
+	}
+	if _, err := io.CopyN(io.Discard, r.conn, closeDrainLimit); err != nil {
+		r.readErr = err
+	}
+}
+
+// isAbortReply reports whether err is the reply of a server which aborted
+// a transfer, e.g. because its data connection was closed.
+func isAbortReply(err error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return false
+	}
+	return protoErr.Code == StatusTransfertAborted || protoErr.Code == StatusActionAborted
+}
+
+// newProgress returns the progress of a new transfer, or nil if it is not
+// reported.
+func (to *transferOptions) newProgress() *progress {
+	if to.progressFn == nil {
+		return nil
+	}
+	return &progress{fn: to.progressFn, every: to.progressEvery}
+}
+
+// progress reports the advancement of a transfer for TransferWithProgress
+type progress struct {
+	fn    func(transferred int64)
+	every int64
+	total int64
+	next  int64
+	done  bool
+}
+
+// add accounts for n more bytes transferred
+func (p *progress) add(n int64) {
+	if n <= 0 || p.done {
+		return
+	}
+
+	p.total += n
+	if p.total >= p.next {
+		p.fn(p.total)
+		p.next = p.total + p.every
+	}
+}
+
+// finish makes the last call with the total, once
+func (p *progress) finish() {
+	if p.done {
+		return
+	}
+
+	p.done = true
+	p.fn(p.total)
+}
+
+// progressReader reports the bytes read from r
+type progressReader struct {
+	r        io.Reader
+	progress *progress
+}
+
+func (r *progressReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	r.progress.add(int64(n))
+	return n, err
+}
@@ -0,0 +1,166 @@
+package ftp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// errNoTimezoneReference is returned when no file can be used to compare
+// the times of LIST and MDTM
+var errNoTimezoneReference = errors.New("no file to detect the timezone")
+
+// maxTimezoneOffset is the largest offset of the time zones in use
+const maxTimezoneOffset = 14 * time.Hour
+
+// DialWithTimezoneDetection returns a DialOption that detects the time zone
+// of the LIST timestamps after login, as DetectTimezone does for the current
+// directory, but without ever uploading a marker file: the detection only
+// succeeds when the directory has a file listed with its time of day. It is
+// skipped when the server does not support MDTM, and failures leave the
+// location of the connection unchanged.
+func DialWithTimezoneDetection(enabled bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.detectTimezone = enabled
+	}}
+}
+
+// DetectTimezone finds the offset of the time zone of the server, by
+// comparing the LIST and MDTM times of a file of dir. The time of MDTM is in
+// UTC, while the one of LIST is in the local time of the server.
+//
+// If dir has no file listed with its time of day, an empty marker file named
+// ".ftp-timezone-" followed by a number is uploaded to dir, then deleted:
+// this requires the permission to write to dir.
+//
+// The offset is rounded to the nearest 30 minutes, and becomes the location
+// used to parse the listings, replacing the one of DialWithLocation. The
+// location has a fixed offset: it must be detected again after daylight
+// saving time changes.
+//
+// Listings with MLSD are in UTC and do not need any detection: a zero offset
+// is returned without changing the location.
+func (c *ServerConn) DetectTimezone(dir string) (time.Duration, error) {
+	return c.detectTimezone(dir, true)
+}
+
+// detectTimezone is DetectTimezone, where marker tells whether a marker file
+// may be uploaded.
+func (c *ServerConn) detectTimezone(dir string, marker bool) (time.Duration, error) {
+	if c.mlstSupported {
+		return 0, nil
+	}
+	if !c.mdtmSupported {
+		return 0, errors.New("timezone detection requires MDTM")
+	}
+
+	offset, err := c.timezoneOffset(dir)
+	if err == errNoTimezoneReference && marker {
+		offset, err = c.timezoneOffsetMarker(dir)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	c.timezone = offset
+	c.timezoneDetected = true
+	c.options.location = time.FixedZone(formatOffset(offset), int(offset/time.Second))
+	return offset, nil
+}
+
+// TimezoneOffset returns the offset of the time zone of the server found by
+// DetectTimezone, and whether it was detected.
+func (c *ServerConn) TimezoneOffset() (offset time.Duration, detected bool) {
+	return c.timezone, c.timezoneDetected
+}
+
+// timezoneOffset compares the times of the most recent file of dir which is
+// listed with its time of day.
+func (c *ServerConn) timezoneOffset(dir string) (time.Duration, error) {
+	entries, _, err := c.list(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var files []*Entry
+	for _, e := range entries {
+		// Old files are listed with their date only
+		if e.Type == EntryTypeFile && e.TimeResolution > 0 && e.TimeResolution <= time.Minute {
+			files = append(files, e)
+		}
+	}
+	if len(files) == 0 {
+		return 0, errNoTimezoneReference
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time.After(files[j].Time)
+	})
+
+	name := files[0].Name
+	if dir != "" {
+		name = dir + "/" + name
+	}
+	utc, err := c.GetTime(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return listOffset(files[0].Time, utc)
+}
+
+// timezoneOffsetMarker uploads an empty file to dir to compare its times.
+func (c *ServerConn) timezoneOffsetMarker(dir string) (time.Duration, error) {
+	name := fmt.Sprintf(".ftp-timezone-%d", time.Now().UnixNano())
+	if dir != "" {
+		name = dir + "/" + name
+	}
+	if err := c.Stor(name, &bytes.Buffer{}, TransferWithType(TransferTypeBinary)); err != nil {
+		return 0, err
+	}
+
+	offset, err := c.timezoneOffset(dir)
+	if errDelete := c.Delete(name); err == nil {
+		err = errDelete
+	}
+	return offset, err
+}
+
+// listOffset returns the offset between listed, a time read from a listing,
+// and utc, the same time in UTC. The year of listed is ignored, as it may
+// have been guessed.
+func listOffset(listed, utc time.Time) (time.Duration, error) {
+	var offset time.Duration
+	for i, year := range []int{utc.Year() - 1, utc.Year(), utc.Year() + 1} {
+		wall := time.Date(year, listed.Month(), listed.Day(), listed.Hour(), listed.Minute(), 0, 0, time.UTC)
+		diff := wall.Sub(utc.Truncate(time.Minute))
+		if i == 0 || abs(diff) < abs(offset) {
+			offset = diff
+		}
+	}
+
+	offset = offset.Round(30 * time.Minute)
+	if abs(offset) > maxTimezoneOffset {
+		return 0, fmt.Errorf("listed time %s does not match %s", listed.Format(time.Stamp), utc)
+	}
+	return offset, nil
+}
+
+// formatOffset returns the name of the time zone with the given offset,
+// e.g. UTC+05:30
+func formatOffset(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, offset/time.Hour, offset%time.Hour/time.Minute)
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,113 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jlaffaye/ftp/ftptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListOffset(t *testing.T) {
+	utc := time.Date(2020, time.December, 13, 20, 24, 31, 0, time.UTC)
+
+	tests := []struct {
+		listed   time.Time
+		expected time.Duration
+	}{
+		{time.Date(2020, time.December, 13, 20, 24, 0, 0, time.UTC), 0},
+		{time.Date(2020, time.December, 13, 22, 24, 0, 0, time.UTC), 2 * time.Hour},
+		{time.Date(2020, time.December, 14, 1, 54, 0, 0, time.UTC), 5*time.Hour + 30*time.Minute},
+		{time.Date(2020, time.December, 13, 15, 25, 0, 0, time.UTC), -5 * time.Hour},
+		// the year of the listing was guessed wrong
+		{time.Date(2021, time.December, 13, 21, 24, 0, 0, time.UTC), time.Hour},
+	}
+
+	for _, test := range tests {
+		offset, err := listOffset(test.listed, utc)
+		if assert.NoError(t, err, test.listed) {
+			assert.Equal(t, test.expected, offset, test.listed)
+		}
+	}
+
+	_, err := listOffset(time.Date(2020, time.January, 29, 10, 29, 0, 0, time.UTC), utc)
+	assert.Error(t, err)
+
+	// across the new year
+	offset, err := listOffset(time.Date(2020, time.January, 1, 1, 0, 0, 0, time.UTC), time.Date(2020, time.December, 31, 23, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, offset)
+}
+
+func TestFormatOffset(t *testing.T) {
+	assert.Equal(t, "UTC+00:00", formatOffset(0))
+	assert.Equal(t, "UTC+05:30", formatOffset(5*time.Hour+30*time.Minute))
+	assert.Equal(t, "UTC-03:30", formatOffset(-3*time.Hour-30*time.Minute))
+}
+
+func TestDetectTimezone(t *testing.T) {
+	mock, c := openConnExt(t, "127.0.0.1", "std-time")
+
+	_, detected := c.TimezoneOffset()
+	assert.False(t, detected)
+
+	offset, err := c.DetectTimezone("tz")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, offset)
+	offset, detected = c.TimezoneOffset()
+	assert.True(t, detected)
+	assert.Equal(t, 2*time.Hour, offset)
+	assert.Equal(t, "MDTM tz/tz-file", mock.lastFull)
+
+	// The file listed at midnight is the reference
+	entries, err := c.List("tz")
+	if assert.NoError(t, err) && assert.Len(t, entries, 2) {
+		utc := entries[1].Time.UTC()
+		assert.Equal(t, 13, utc.Day())
+		assert.Equal(t, 22, utc.Hour())
+		assert.Equal(t, 0, utc.Minute())
+	}
+
+	closeConn(t, mock, c, []string{"EPSV", "LIST", "MDTM", "EPSV", "LIST"})
+}
+
+func TestDetectTimezoneUnsupported(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1", DialWithTimezoneDetection(true))
+
+	_, detected := c.TimezoneOffset()
+	assert.False(t, detected)
+	_, err := c.DetectTimezone("")
+	assert.Error(t, err)
+
+	closeConn(t, mock, c, nil)
+}
+
+func TestDialWithTimezoneDetection(t *testing.T) {
+	// the time of the listing does not match the one of MDTM
+	mock, c := openConnExt(t, "127.0.0.1", "std-time", DialWithTimezoneDetection(true))
+
+	_, detected := c.TimezoneOffset()
+	assert.False(t, detected)
+	assert.Equal(t, time.UTC, c.options.location)
+
+	closeConn(t, mock, c, []string{"EPSV", "LIST", "MDTM"})
+}
+
+func TestDialWithTimezoneDetectionNoMarker(t *testing.T) {
+	// no file to compare the times with
+	s := ftptest.NewServer(ftptest.Script{
+		Replies: map[string]string{
+			"FEAT": "211-Features:\r\n EPSV\r\n MDTM\r\n211 End",
+		},
+	})
+	defer s.Close()
+	c := dialScript(t, s, DialWithTimezoneDetection(true))
+
+	_, detected := c.TimezoneOffset()
+	assert.False(t, detected)
+	assert.Equal(t, 1, countCommands(s, "LIST"))
+	assert.Zero(t, countCommands(s, "STOR"))
+	assert.Zero(t, countCommands(s, "DELE"))
+
+	assert.NoError(t, c.Quit())
+}
@@ -1,7 +1,9 @@
 package ftp
 
 import (
+	"io/fs"
 	"path"
+	"sort"
 )
 
 //Walker traverses the directory tree of a remote FTP server
@@ -11,6 +13,7 @@ type Walker struct {
 	cur        *item
 	stack      []*item
 	descend    bool
+	visited    map[string]bool // unique facts of the directories listed
 }
 
 type item struct {
@@ -33,17 +36,22 @@ func (w *Walker) Next() bool {
 		}
 	}
 
-	if w.descend && w.cur.entry.Type == EntryTypeFolder {
+	if w.descend && w.cur.err == nil && w.visit(w.cur.entry) {
 		entries, err := w.serverConn.List(w.cur.path)
 
-		// an error occurred, drop out and stop walking
+		// an error occurred, report it on the directory itself and
+		// carry on with the rest of the tree
 		if err != nil {
 			w.cur.err = err
-			return false
+			w.descend = false
+			return true
 		}
 
-		for _, entry := range entries {
-			if entry.Name == "." || entry.Name == ".." {
+		// push in reverse order so that entries are visited sorted by name
+		sortEntries(entries)
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+			if entry.Pseudo {
 				continue
 			}
 
@@ -71,14 +79,37 @@ func (w *Walker) Next() bool {
 	return true
 }
 
+// visit reports whether the walker should list the directory of entry. Only
+// folders are listed, which excludes symbolic links to directories. When the
+// server identifies them with the unique MLSD fact, directories already
+// listed through another path are not listed again, to prevent cycles.
+func (w *Walker) visit(entry *Entry) bool {
+	if entry.Type != EntryTypeFolder {
+		return false
+	}
+	if entry.Unique == "" {
+		return true
+	}
+
+	if w.visited == nil {
+		w.visited = make(map[string]bool)
+	}
+	if w.visited[entry.Unique] {
+		return false
+	}
+	w.visited[entry.Unique] = true
+	return true
+}
+
 // SkipDir tells the Next function to skip the currently processed directory
 func (w *Walker) SkipDir() {
 	w.descend = false
 }
 
 // Err returns the error, if any, for the most recent attempt by Next to
-// visit a file or a directory. If a directory has an error, the walker
-// will not descend in that directory
+// visit a file or a directory. If a directory can't be listed, Next visits
+// it a second time with the error set, and the walker will not descend in
+// that directory
 func (w *Walker) Err() error {
 	return w.cur.err
 }
@@ -96,3 +127,57 @@ func (w *Walker) Stat() *Entry {
 func (w *Walker) Path() string {
 	return w.cur.path
 }
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, like fs.WalkDir.
+//
+// The entries of each directory are visited in lexical order. If a directory
+// can't be listed, fn is called a second time for it with the error, and the
+// walk only stops if fn returns an error other than fs.SkipDir. Symbolic
+// links to directories are not followed.
+func (c *ServerConn) WalkDir(root string, fn fs.WalkDirFunc) error {
+	rootEntry := &Entry{Name: path.Base(root), Type: EntryTypeFolder}
+	w := &Walker{serverConn: c}
+
+	err := w.walkDir(root, rootEntry, fn)
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkDir recursively descends name for WalkDir
+func (w *Walker) walkDir(name string, entry *Entry, fn fs.WalkDirFunc) error {
+	if err := fn(name, entry.DirEntry(), nil); err != nil || !w.visit(entry) {
+		return err
+	}
+
+	entries, err := w.serverConn.List(name)
+	if err != nil {
+		// Second call, to report the listing error
+		return fn(name, entry.DirEntry(), err)
+	}
+
+	sortEntries(entries)
+	for _, entry := range entries {
+		if entry.Pseudo {
+			continue
+		}
+
+		if err := w.walkDir(path.Join(name, entry.Name), entry, fn); err != nil {
+			if err == fs.SkipDir && entry.Type == EntryTypeFolder {
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortEntries sorts entries by name
+func sortEntries(entries []*Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+}
@@ -1,7 +1,9 @@
 package ftp
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"testing"
 	"time"
 
@@ -209,3 +211,106 @@ func TestCurInit(t *testing.T) {
 	assert.Equal(t, 0, len(w.stack))
 	assert.Equal(t, "/root/lo", w.Path())
 }
+
+func TestWalkerSorted(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	var paths []string
+	w := c.Walk("unix")
+	for w.Next() {
+		assert.NoError(t, w.Err())
+		paths = append(paths, w.Path())
+	}
+	assert.Equal(t, []string{"unix/a.txt", "unix/b.txt", "unix/pub", "unix/pub/lo"}, paths)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestWalkerListError(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	w := c.Walk("denied")
+	if assert.True(t, w.Next()) {
+		assert.Equal(t, "denied/", w.Path())
+		assert.Error(t, w.Err())
+	}
+	assert.False(t, w.Next())
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestWalkDir(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	var paths []string
+	err := c.WalkDir("unix", func(path string, d fs.DirEntry, err error) error {
+		assert.NoError(t, err)
+		paths = append(paths, path)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unix", "unix/a.txt", "unix/b.txt", "unix/pub", "unix/pub/lo"}, paths)
+
+	// SkipDir on a directory
+	paths = nil
+	err = c.WalkDir("unix", func(path string, d fs.DirEntry, err error) error {
+		paths = append(paths, path)
+		if d.IsDir() && d.Name() == "pub" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unix", "unix/a.txt", "unix/b.txt", "unix/pub"}, paths)
+
+	// SkipDir on a file skips the rest of its directory
+	paths = nil
+	err = c.WalkDir("unix", func(path string, d fs.DirEntry, err error) error {
+		paths = append(paths, path)
+		if d.Name() == "a.txt" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unix", "unix/a.txt"}, paths)
+
+	// SkipDir on the root
+	paths = nil
+	err = c.WalkDir("unix", func(path string, d fs.DirEntry, err error) error {
+		paths = append(paths, path)
+		return fs.SkipDir
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unix"}, paths)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
+
+func TestWalkDirListError(t *testing.T) {
+	mock, c := openConn(t, "127.0.0.1")
+
+	var calls int
+	err := c.WalkDir("denied", func(path string, d fs.DirEntry, err error) error {
+		calls++
+		assert.Equal(t, "denied", path)
+		return err
+	})
+	assert.Equal(t, 2, calls)
+	assert.Error(t, err)
+
+	errStop := errors.New("stop")
+	err = c.WalkDir("unix", func(path string, d fs.DirEntry, err error) error {
+		if path == "unix/b.txt" {
+			return errStop
+		}
+		return nil
+	})
+	assert.Equal(t, errStop, err)
+
+	assert.NoError(t, c.Quit())
+	mock.Wait()
+}
@@ -0,0 +1,762 @@
+package ftp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PDSMemberEntry describes a member of a z/OS partitioned dataset, as listed
+// by ListPDSMembers. Only Name is set for the members without ISPF
+// statistics, such as the ones of load libraries.
+type PDSMemberEntry struct {
+	Name          string
+	Version       string    // version and modification level, e.g. "01.05"
+	Created       time.Time // date of creation
+	Changed       time.Time // date and time of the last change
+	CurrentLines  int       // number of lines
+	InitialLines  int       // number of lines when created
+	ModifiedLines int       // number of lines changed
+	UserID        string    // user who made the last change
+}
+
+// pdsDateFormat is the format of the dates of the PDS member listings
+const pdsDateFormat = "2006/01/02"
+
+// pdsTimeFormats are the formats of the change times of the PDS member
+// listings
+var pdsTimeFormats = []string{"15:04", "15:04:05"}
+
+// ListPDSMembers lists the members of a partitioned dataset of a z/OS
+// server. dataset is the fully qualified name of the dataset, e.g.
+// HLQ.PDS.NAME, with or without the surrounding quotes.
+//
+// The working directory is changed to the dataset for the listing, and
+// restored afterwards.
+func (c *ServerConn) ListPDSMembers(dataset string) (members []*PDSMemberEntry, err error) {
+	err = c.listLinesIn(QuoteDataSet(dataset), isPDSHeader, func(line string) error {
+		member, err := parsePDSMemberLine(line, c.options.location)
+		if err == nil {
+			members = append(members, member)
+		}
+		return err
+	})
+	return members, err
+}
+
+// ListDataSets lists the datasets of the catalog of a z/OS server whose
+// names start with the prefix qualifiers, e.g. "HLQ" or "HLQ.PROJECT". A
+// trailing ".**" or "." is accepted, as well as surrounding quotes. The names
+// of the datasets are relative to the prefix.
+//
+// The working directory is changed to the prefix for the listing, and
+// restored afterwards.
+func (c *ServerConn) ListDataSets(prefix string) (datasets []*DataSetEntry, err error) {
+	prefix = strings.Trim(prefix, "'")
+	prefix = strings.TrimSuffix(prefix, "**")
+	prefix = strings.TrimSuffix(prefix, ".") + "."
+
+	err = c.listLinesIn(QuoteDataSet(prefix), isDataSetHeader, func(line string) error {
+		dataset, err := parseDataSetListLine(line, c.options.location)
+		if err == nil {
+			if c.options.disableRawLines {
+				dataset.Raw = ""
+			}
+			datasets = append(datasets, dataset)
+		}
+		return err
+	})
+	return datasets, err
+}
+
+// ListGDG lists the generations of the generation data group base, sorted
+// from the newest to the oldest. As with ListDataSets, the names of the
+// generations are relative to base, e.g. "G0012V00".
+func (c *ServerConn) ListGDG(base string) ([]*DataSetEntry, error) {
+	datasets, err := c.ListDataSets(base)
+	if err != nil {
+		return nil, err
+	}
+
+	generations := datasets[:0]
+	for _, dataset := range datasets {
+		if _, _, ok := dataset.GDGGeneration(); ok {
+			generations = append(generations, dataset)
+		}
+	}
+
+	sort.SliceStable(generations, func(i, j int) bool {
+		geni, veri, _ := generations[i].GDGGeneration()
+		genj, verj, _ := generations[j].GDGGeneration()
+		if geni != genj {
+			return geni > genj
+		}
+		return veri > verj
+	})
+	return generations, nil
+}
+
+// ParseDataSetListLine parses a line of the catalog listing of a z/OS
+// server, for example one saved from ListDataSets. It returns an error for
+// lines which do not describe a dataset, such as the header.
+func ParseDataSetListLine(line string, loc *time.Location) (*DataSetEntry, error) {
+	return parseDataSetListLine(line, loc)
+}
+
+// listLinesIn lists the working directory after changing it to dir, which
+// is restored afterwards. The lines of the listing are passed to parse,
+// except the header. Lines which can not be parsed are skipped, unless the
+// connection was established with DialWithStrictList.
+func (c *ServerConn) listLinesIn(dir string, isHeader func(string) bool, parse func(string) error) (err error) {
+	cwd, err := c.CurrentDir()
+	if err != nil {
+		return err
+	}
+	if err := c.ChangeDir(dir); err != nil {
+		return err
+	}
+	defer func() {
+		if errCwd := c.ChangeDir(cwd); err == nil {
+			err = errCwd
+		}
+	}()
+
+	conn, err := c.cmdDataConnFrom(0, "LIST")
+	if err != nil {
+		return err
+	}
+
+	r := &Response{conn: conn, c: c}
+
+	scanner := bufio.NewScanner(c.options.wrapStream(r))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || isHeader(line) {
+			continue
+		}
+		if errParse := parse(line); errParse != nil && c.options.strictList {
+			return r.stop(&ListLineError{Line: line, Err: errParse})
+		}
+	}
+
+	return r.closeScanned(scanner)
+}
+
+// QuoteDataSet returns the fully qualified name of the z/OS dataset name,
+// surrounded by single quotes, e.g. 'SYS1.PARMLIB'. Unquoted names are
+// otherwise relative to the prefix of the user. Names which are already
+// quoted are returned as is, and so are the UNIX System Services paths,
+// which start with a slash.
+func QuoteDataSet(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return "'" + strings.Trim(name, "'") + "'"
+}
+
+// DataSetMember returns the fully qualified name of the member of the
+// partitioned dataset, given with or without the surrounding quotes, e.g.
+// 'SYS1.PARMLIB(IEASYS00)'.
+func DataSetMember(dataset, member string) string {
+	return QuoteDataSet(strings.Trim(dataset, "'") + "(" + member + ")")
+}
+
+// isDataSetHeader reports whether line is the header of a catalog listing
+func isDataSetHeader(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 1 && fields[0] == "Volume" && fields[1] == "Unit"
+}
+
+// isPDSHeader reports whether line is the header of a PDS member listing
+func isPDSHeader(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 1 && fields[0] == "Name" && (fields[1] == "VV.MM" || fields[1] == "Size")
+}
+
+// parsePDSMemberLine parses a line of the listing of a partitioned dataset:
+//
+//	ASM      01.05 2021/03/01 2021/05/18 10:01    45    40     0 IBMUSER
+//
+// Lines of other layouts, such as the ones of load modules, give entries
+// with their name only.
+func parsePDSMemberLine(line string, loc *time.Location) (*PDSMemberEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, errUnsupportedListLine
+	}
+
+	e := &PDSMemberEntry{Name: fields[0]}
+	if len(fields) != 9 || !isPDSVersion(fields[1]) {
+		return e, nil
+	}
+
+	var err error
+	e.Version = fields[1]
+	if e.Created, err = time.ParseInLocation(pdsDateFormat, fields[2], loc); err != nil {
+		return nil, err
+	}
+	for _, format := range pdsTimeFormats {
+		e.Changed, err = time.ParseInLocation(pdsDateFormat+" "+format, fields[3]+" "+fields[4], loc)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	counts := []*int{&e.CurrentLines, &e.InitialLines, &e.ModifiedLines}
+	for i, count := range counts {
+		if *count, err = strconv.Atoi(fields[5+i]); err != nil {
+			return nil, errors.New("invalid line count")
+		}
+	}
+	e.UserID = fields[8]
+
+	return e, nil
+}
+
+// isPDSVersion reports whether str is a VV.MM version of the ISPF statistics
+func isPDSVersion(str string) bool {
+	if len(str) != 5 || str[2] != '.' {
+		return false
+	}
+	for _, r := range str[:2] + str[3:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordFormat is the format of the records of a z/OS dataset.
+type RecordFormat int
+
+// The record formats of z/OS datasets
+const (
+	RecordFormatUnknown RecordFormat = iota
+	RecordFormatF                    // fixed length
+	RecordFormatFB                   // fixed length, blocked
+	RecordFormatFBA                  // fixed length, blocked, ASA control characters
+	RecordFormatFBS                  // fixed length, blocked, standard
+	RecordFormatV                    // variable length
+	RecordFormatVB                   // variable length, blocked
+	RecordFormatVBA                  // variable length, blocked, ASA control characters
+	RecordFormatVBS                  // variable length, blocked, spanned
+	RecordFormatU                    // undefined length
+)
+
+// recordFormatNames are the abbreviations of the record formats
+var recordFormatNames = [...]string{
+	RecordFormatF:   "F",
+	RecordFormatFB:  "FB",
+	RecordFormatFBA: "FBA",
+	RecordFormatFBS: "FBS",
+	RecordFormatV:   "V",
+	RecordFormatVB:  "VB",
+	RecordFormatVBA: "VBA",
+	RecordFormatVBS: "VBS",
+	RecordFormatU:   "U",
+}
+
+// recordFormats are the record formats by abbreviation
+var recordFormats = map[string]RecordFormat{
+	"F":   RecordFormatF,
+	"FB":  RecordFormatFB,
+	"FBA": RecordFormatFBA,
+	"FBS": RecordFormatFBS,
+	"V":   RecordFormatV,
+	"VB":  RecordFormatVB,
+	"VBA": RecordFormatVBA,
+	"VBS": RecordFormatVBS,
+	"U":   RecordFormatU,
+}
+
+// ParseRecordFormat returns the record format with the z/OS abbreviation s,
+// e.g. "FB".
+func ParseRecordFormat(s string) (RecordFormat, error) {
+	if f, ok := recordFormats[s]; ok {
+		return f, nil
+	}
+	return RecordFormatUnknown, fmt.Errorf("unknown record format %q", s)
+}
+
+// String returns the z/OS abbreviation of f, e.g. "FB", or an empty string
+// for RecordFormatUnknown.
+func (f RecordFormat) String() string {
+	if f < 0 || int(f) >= len(recordFormatNames) {
+		return fmt.Sprintf("RecordFormat(%d)", int(f))
+	}
+	return recordFormatNames[f]
+}
+
+// MarshalText implements encoding.TextMarshaler, with the abbreviation of f.
+func (f RecordFormat) MarshalText() ([]byte, error) {
+	if f < 0 || int(f) >= len(recordFormatNames) {
+		return nil, fmt.Errorf("invalid record format %d", int(f))
+	}
+	return []byte(recordFormatNames[f]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. An empty text is
+// RecordFormatUnknown.
+func (f *RecordFormat) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*f = RecordFormatUnknown
+		return nil
+	}
+	var err error
+	*f, err = ParseRecordFormat(string(text))
+	return err
+}
+
+// DataSetOrganization is the organization of a z/OS dataset.
+type DataSetOrganization int
+
+// The organizations of z/OS datasets
+const (
+	UnknownOrganization        DataSetOrganization = iota
+	PhysicalSequential                             // PS
+	Partitioned                                    // PO
+	PartitionedExtended                            // PO-E
+	DirectAccess                                   // DA
+	VirtualStorageAccessMethod                     // VS
+)
+
+// organizationNames are the abbreviations of the dataset organizations
+var organizationNames = [...]string{
+	PhysicalSequential:         "PS",
+	Partitioned:                "PO",
+	PartitionedExtended:        "PO-E",
+	DirectAccess:               "DA",
+	VirtualStorageAccessMethod: "VS",
+}
+
+// organizations are the dataset organizations by abbreviation
+var organizations = map[string]DataSetOrganization{
+	"PS":   PhysicalSequential,
+	"PO":   Partitioned,
+	"PO-E": PartitionedExtended,
+	"DA":   DirectAccess,
+	"VS":   VirtualStorageAccessMethod,
+}
+
+// ParseDataSetOrganization returns the dataset organization with the z/OS
+// abbreviation s, e.g. "PO".
+func ParseDataSetOrganization(s string) (DataSetOrganization, error) {
+	if o, ok := organizations[s]; ok {
+		return o, nil
+	}
+	return UnknownOrganization, fmt.Errorf("unknown dataset organization %q", s)
+}
+
+// String returns the z/OS abbreviation of o, e.g. "PO", or an empty string
+// for UnknownOrganization.
+func (o DataSetOrganization) String() string {
+	if o < 0 || int(o) >= len(organizationNames) {
+		return fmt.Sprintf("DataSetOrganization(%d)", int(o))
+	}
+	return organizationNames[o]
+}
+
+// MarshalText implements encoding.TextMarshaler, with the abbreviation of o.
+func (o DataSetOrganization) MarshalText() ([]byte, error) {
+	if o < 0 || int(o) >= len(organizationNames) {
+		return nil, fmt.Errorf("invalid dataset organization %d", int(o))
+	}
+	return []byte(organizationNames[o]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. An empty text is
+// UnknownOrganization.
+func (o *DataSetOrganization) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*o = UnknownOrganization
+		return nil
+	}
+	var err error
+	*o, err = ParseDataSetOrganization(string(text))
+	return err
+}
+
+// DataSetStatus tells whether the attributes of a z/OS dataset are available.
+type DataSetStatus int
+
+// The statuses of z/OS datasets
+const (
+	DataSetStatusOnline   DataSetStatus = iota // on a mounted volume
+	DataSetStatusMigrated                      // migrated by HSM, recalled on access
+	DataSetStatusArchived                      // on a device which is not direct access, e.g. a tape
+	DataSetStatusError                         // the server could not determine its attributes
+)
+
+// dataSetStatusNames are the string representations of the dataset statuses
+var dataSetStatusNames = [...]string{
+	DataSetStatusOnline:   "online",
+	DataSetStatusMigrated: "migrated",
+	DataSetStatusArchived: "archived",
+	DataSetStatusError:    "error",
+}
+
+// String returns the string representation of s, e.g. "migrated", or
+// "unknown(n)" for a value which is not one of the constants.
+func (s DataSetStatus) String() string {
+	if s < 0 || int(s) >= len(dataSetStatusNames) {
+		return "unknown(" + strconv.Itoa(int(s)) + ")"
+	}
+	return dataSetStatusNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler, with the string
+// representation of s.
+func (s DataSetStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *DataSetStatus) UnmarshalText(text []byte) error {
+	for i, name := range dataSetStatusNames {
+		if string(text) == name {
+			*s = DataSetStatus(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown dataset status %q", text)
+}
+
+// DataSetEntry describes a dataset of the catalog listing of a z/OS server.
+// Only Name and Status are set for the datasets which are not online.
+//
+// Its JSON schema is stable: the record format, the organization and the
+// status are encoded as strings, e.g. "FB", "PO" and "online", and the time
+// is null when it is unknown.
+type DataSetEntry struct {
+	Name         string              `json:"name"`
+	Volume       string              `json:"volume"`
+	Unit         string              `json:"unit"`
+	Time         *time.Time          `json:"time"` // date of last reference, nil if unknown
+	Extents      uint64              `json:"extents"`
+	Used         uint64              `json:"used"`         // number of tracks used
+	UsedUnknown  bool                `json:"used_unknown"` // Used is not known, e.g. for a volume which is not available
+	RecordFormat RecordFormat        `json:"record_format"`
+	RecordLength uint64              `json:"record_length"`
+	BlockSize    uint64              `json:"block_size"`
+	Organization DataSetOrganization `json:"organization"`
+	Status       DataSetStatus       `json:"status"`
+	GDGBase      bool                `json:"gdg_base"` // the base entry of a generation data group
+
+	// Raw is the listing line the dataset was parsed from, unless disabled
+	// with DialWithDisabledRawLines. It is omitted from JSON when empty.
+	Raw string `json:"raw,omitempty"`
+}
+
+// GDGGeneration parses the last qualifier of the name of a generation of a
+// generation data group, e.g. "G0012V00" of HLQ.GDG.G0012V00. ok is false
+// when the name does not end with such a qualifier.
+func (e *DataSetEntry) GDGGeneration() (gen int, version int, ok bool) {
+	qualifier := e.Name[strings.LastIndex(e.Name, ".")+1:]
+	if len(qualifier) != 8 || qualifier[0] != 'G' || qualifier[5] != 'V' {
+		return 0, 0, false
+	}
+	for _, r := range qualifier[1:5] + qualifier[6:] {
+		if r < '0' || r > '9' {
+			return 0, 0, false
+		}
+	}
+
+	gen, _ = strconv.Atoi(qualifier[1:5])
+	version, _ = strconv.Atoi(qualifier[6:])
+	return gen, version, true
+}
+
+// parseDataSetListLine parses a line of the catalog listing of a z/OS
+// server:
+//
+//	Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname
+//	WYPRC5 3390   2021/05/18  1   15  FB      80 27920  PO  ISPF.PROFILE
+//	Migrated                                                HLQ.OLD.DATA
+//	                                                        HLQ.GDG
+//
+// Raw is set to line.
+func parseDataSetListLine(line string, loc *time.Location) (*DataSetEntry, error) {
+	e, err := parseDataSetColumns(line, loc)
+	if err != nil {
+		return nil, err
+	}
+	e.Raw = line
+	return e, nil
+}
+
+// parseDataSetColumns parses the columns of a line of the catalog listing,
+// see parseDataSetListLine.
+func parseDataSetColumns(line string, loc *time.Location) (*DataSetEntry, error) {
+	fields := strings.Fields(line)
+
+	// The base of a generation data group only comes with its name
+	if len(fields) == 1 && strings.HasPrefix(line, " ") {
+		return &DataSetEntry{Name: fields[0], GDGBase: true}, nil
+	}
+	if len(fields) < 2 {
+		return nil, errUnsupportedListLine
+	}
+
+	// The datasets which are not online only come with a status
+	for _, status := range dataSetStatuses {
+		if strings.HasPrefix(line, status.prefix) {
+			return &DataSetEntry{Name: fields[len(fields)-1], Status: status.status}, nil
+		}
+	}
+
+	if fields[len(fields)-2] == "VS" {
+		return parseVSAMListLine(line, fields, loc)
+	}
+
+	// The columns are located by position, as their widths vary with the
+	// length of the volume and the level of the server
+	if len(fields) != 10 {
+		return nil, errUnsupportedListLine
+	}
+
+	e := &DataSetEntry{
+		Name:   fields[9],
+		Volume: fields[0],
+		Unit:   fields[1],
+	}
+
+	t, err := time.ParseInLocation("2006/01/02", fields[2], loc)
+	if err != nil {
+		return nil, err
+	}
+	e.Time = &t
+
+	if e.Extents, _, err = parseDataSetNumber(fields[3]); err != nil {
+		return nil, err
+	}
+	var known bool
+	if e.Used, known, err = parseDataSetNumber(fields[4]); err != nil {
+		return nil, err
+	}
+	e.UsedUnknown = !known
+
+	var ok bool
+	if fields[5] != "?" {
+		if e.RecordFormat, ok = recordFormats[fields[5]]; !ok {
+			return nil, errUnsupportedListLine
+		}
+	}
+
+	numbers := []*uint64{&e.RecordLength, &e.BlockSize}
+	for i, n := range numbers {
+		if *n, _, err = parseDataSetNumber(fields[6+i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.Organization, ok = organizations[fields[8]]; !ok {
+		return nil, errUnsupportedListLine
+	}
+
+	return e, nil
+}
+
+// parseDataSetNumber parses a numeric column of the catalog listing, which
+// is "?" when unknown, and ends with a "+" when it overflows the column,
+// e.g. "1+" for the extents.
+func parseDataSetNumber(column string) (n uint64, known bool, err error) {
+	if column == "?" {
+		return 0, false, nil
+	}
+	n, err = strconv.ParseUint(strings.TrimSuffix(column, "+"), 10, 64)
+	return n, err == nil, err
+}
+
+// dataSetStatuses are the start of the catalog lines of the datasets which
+// are not online
+var dataSetStatuses = []struct {
+	prefix string
+	status DataSetStatus
+}{
+	{"Migrated", DataSetStatusMigrated},
+	{"ARCIVE ", DataSetStatusArchived},
+	{"Error determining attributes", DataSetStatusError},
+}
+
+// parseVSAMListLine parses a catalog line of a VSAM cluster or of one of
+// its DATA and INDEX components, whose columns are blank or hold dashes or
+// question marks when they do not apply:
+//
+//	VSAM01 3390   2021/05/18  1   45  ?       ?     ?    VS  HLQ.KSDS.DATA
+//	                                                    VS  HLQ.KSDS
+func parseVSAMListLine(line string, fields []string, loc *time.Location) (*DataSetEntry, error) {
+	e := &DataSetEntry{
+		Name:         fields[len(fields)-1],
+		Organization: VirtualStorageAccessMethod,
+	}
+
+	// The columns are optional, but come in order
+	columns := fields[:len(fields)-2]
+	next := func() (string, bool) {
+		if len(columns) == 0 {
+			return "", false
+		}
+		column := columns[0]
+		columns = columns[1:]
+		return column, column != "-" && column != "?" && !strings.HasPrefix(column, "--")
+	}
+	number := func(n *uint64) error {
+		column, ok := next()
+		if !ok {
+			return nil
+		}
+		var err error
+		*n, _, err = parseDataSetNumber(column)
+		return err
+	}
+
+	if !strings.HasPrefix(line, " ") && len(columns) > 0 {
+		e.Volume, _ = next()
+		e.Unit, _ = next()
+	}
+
+	if len(columns) > 0 {
+		if t, err := time.ParseInLocation("2006/01/02", columns[0], loc); err == nil {
+			e.Time = &t
+			columns = columns[1:]
+		}
+	}
+
+	if err := number(&e.Extents); err != nil {
+		return nil, err
+	}
+	if err := number(&e.Used); err != nil {
+		return nil, err
+	}
+
+	if recfm, ok := next(); ok {
+		if e.RecordFormat, ok = recordFormats[recfm]; !ok {
+			return nil, errUnsupportedListLine
+		}
+	}
+
+	if err := number(&e.RecordLength); err != nil {
+		return nil, err
+	}
+	if err := number(&e.BlockSize); err != nil {
+		return nil, err
+	}
+	if len(columns) > 0 {
+		return nil, errUnsupportedListLine
+	}
+
+	return e, nil
+}
+
+// SpaceUnit is the unit of the space allocated to a z/OS dataset.
+type SpaceUnit int
+
+// The units of the space allocated to z/OS datasets
+const (
+	SpaceUnitDefault   SpaceUnit = iota // the default of the server
+	SpaceUnitTracks                     // TRACKS
+	SpaceUnitCylinders                  // CYLINDERS
+	SpaceUnitBlocks                     // BLOCKS
+)
+
+// spaceUnitNames are the names of the space units, as given to the SITE
+// command
+var spaceUnitNames = [...]string{
+	SpaceUnitTracks:    "TRACKS",
+	SpaceUnitCylinders: "CYLINDERS",
+	SpaceUnitBlocks:    "BLOCKS",
+}
+
+// DataSetAllocation holds the attributes of a z/OS dataset created by
+// StorDataSet. The attributes left at their zero value are not sent, so that
+// the defaults of the server apply.
+type DataSetAllocation struct {
+	RecordFormat        RecordFormat
+	LogicalRecordLength uint64
+	BlockSize           uint64
+	Primary             uint64 // primary space, in SpaceUnit
+	Secondary           uint64 // secondary space, in SpaceUnit
+	SpaceUnit           SpaceUnit
+	Directory           uint64 // number of directory blocks of a PDS
+	DataClass           string // SMS data class
+	StorClass           string // SMS storage class
+	MgmtClass           string // SMS management class
+	Unit                string
+	Volume              string
+}
+
+// SiteCommand returns the SITE FTP command setting the attributes, e.g.
+// "SITE LRECL=80 RECFM=FB BLKSIZE=27920 PRIMARY=50 SECONDARY=10 TRACKS". It
+// returns an empty string when all the attributes are left at their zero
+// value.
+func (a *DataSetAllocation) SiteCommand() string {
+	params := a.siteParams()
+	if params == "" {
+		return ""
+	}
+	return "SITE " + params
+}
+
+// siteParams returns the parameters of the SITE FTP command setting the
+// attributes.
+func (a *DataSetAllocation) siteParams() string {
+	var params []string
+	number := func(name string, n uint64) {
+		if n != 0 {
+			params = append(params, name+"="+strconv.FormatUint(n, 10))
+		}
+	}
+	text := func(name, s string) {
+		if s != "" {
+			params = append(params, name+"="+s)
+		}
+	}
+
+	number("LRECL", a.LogicalRecordLength)
+	text("RECFM", a.RecordFormat.String())
+	number("BLKSIZE", a.BlockSize)
+	number("PRIMARY", a.Primary)
+	number("SECONDARY", a.Secondary)
+	if a.SpaceUnit > SpaceUnitDefault && int(a.SpaceUnit) < len(spaceUnitNames) {
+		params = append(params, spaceUnitNames[a.SpaceUnit])
+	}
+	number("DIRECTORY", a.Directory)
+	text("DATACLAS", a.DataClass)
+	text("STORCLAS", a.StorClass)
+	text("MGMTCLAS", a.MgmtClass)
+	text("UNIT", a.Unit)
+	text("VOLUME", a.Volume)
+
+	return strings.Join(params, " ")
+}
+
+// StorDataSet stores the data from r to the z/OS dataset name, given with or
+// without the surrounding quotes, or to a UNIX System Services path. When
+// alloc is not nil, its attributes are
+// sent with a SITE command beforehand, and used if the dataset is created.
+func (c *ServerConn) StorDataSet(name string, r io.Reader, alloc *DataSetAllocation) error {
+	if alloc != nil {
+		if params := alloc.siteParams(); params != "" {
+			if _, _, err := c.Site(params); err != nil {
+				return err
+			}
+		}
+	}
+
+	return c.Stor(QuoteDataSet(name), r)
+}
+
+// RetrDataSet retrieves the z/OS dataset name like Retr, the name being
+// given with or without the surrounding quotes, or as a UNIX System Services
+// path. Members of partitioned datasets are named with DataSetMember.
+func (c *ServerConn) RetrDataSet(name string, options ...TransferOption) (*Response, error) {
+	return c.Retr(QuoteDataSet(name), options...)
+}
@@ -0,0 +1,489 @@
+package ftp
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePDSMemberLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected *PDSMemberEntry
+	}{
+		{
+			"ASM      01.05 2021/03/01 2021/05/18 10:01    45    40     0 IBMUSER",
+			&PDSMemberEntry{
+				Name:          "ASM",
+				Version:       "01.05",
+				Created:       time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+				Changed:       time.Date(2021, time.May, 18, 10, 1, 0, 0, time.UTC),
+				CurrentLines:  45,
+				InitialLines:  40,
+				ModifiedLines: 0,
+				UserID:        "IBMUSER",
+			},
+		},
+		{
+			"JCL#1    02.10 2019/12/31 2020/01/02 23:59:30  1200  1100   512 USER1",
+			&PDSMemberEntry{
+				Name:          "JCL#1",
+				Version:       "02.10",
+				Created:       time.Date(2019, time.December, 31, 0, 0, 0, 0, time.UTC),
+				Changed:       time.Date(2020, time.January, 2, 23, 59, 30, 0, time.UTC),
+				CurrentLines:  1200,
+				InitialLines:  1100,
+				ModifiedLines: 512,
+				UserID:        "USER1",
+			},
+		},
+		// without ISPF statistics
+		{"NOSTATS", &PDSMemberEntry{Name: "NOSTATS"}},
+		// load module
+		{"IEFBR14   000008   00000F          00 FO             RN RU            31    ANY", &PDSMemberEntry{Name: "IEFBR14"}},
+		{"ALIAS1    000008   00000F IEFBR14  00 FO             RN RU            31    ANY", &PDSMemberEntry{Name: "ALIAS1"}},
+	}
+
+	for _, test := range tests {
+		entry, err := parsePDSMemberLine(test.line, time.UTC)
+		if assert.NoError(t, err, test.line) {
+			assert.Equal(t, test.expected, entry, test.line)
+		}
+	}
+
+	for _, line := range []string{
+		"",
+		"ASM      01.05 2021/13/01 2021/05/18 10:01    45    40     0 IBMUSER",
+		"ASM      01.05 2021/03/01 2021/05/18 10h01    45    40     0 IBMUSER",
+		"ASM      01.05 2021/03/01 2021/05/18 10:01    45    4x     0 IBMUSER",
+	} {
+		_, err := parsePDSMemberLine(line, time.UTC)
+		assert.Error(t, err, line)
+	}
+
+	assert.True(t, isPDSHeader(" Name     VV.MM   Created       Changed      Size  Init   Mod   Id"))
+	assert.True(t, isPDSHeader(" Name      Size     TTR   Alias-of AC--------- Attributes--------- Amode Rmode"))
+	assert.False(t, isPDSHeader("NAME"))
+}
+
+func TestListPDSMembers(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	members, err := c.ListPDSMembers("HLQ.PDS.NAME")
+	assert.NoError(t, err)
+	if assert.Len(t, members, 2) {
+		assert.Equal(t, "ASM", members[0].Name)
+		assert.Equal(t, 45, members[0].CurrentLines)
+		assert.Equal(t, "NOSTATS", members[1].Name)
+	}
+	assert.Equal(t, "CWD /incoming", mock.lastFull)
+
+	_, err = c.ListPDSMembers("'HLQ.PDS.NAME'")
+	assert.NoError(t, err)
+
+	closeConn(t, mock, c, []string{"PWD", "CWD", "EPSV", "LIST", "CWD", "PWD", "CWD", "EPSV", "LIST", "CWD"})
+}
+
+func TestListDataSets(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	for _, prefix := range []string{"HLQ", "HLQ.", "HLQ.**", "'HLQ.'"} {
+		datasets, err := c.ListDataSets(prefix)
+		assert.NoError(t, err)
+		if assert.Len(t, datasets, 2) {
+			assert.Equal(t, "ISPF.PROFILE", datasets[0].Name)
+			assert.Equal(t, Partitioned, datasets[0].Organization)
+			assert.Contains(t, datasets[0].Raw, "ISPF.PROFILE")
+			assert.Equal(t, "OLD.DATA", datasets[1].Name)
+			assert.Equal(t, DataSetStatusMigrated, datasets[1].Status)
+		}
+	}
+
+	closeConn(t, mock, c, []string{
+		"PWD", "CWD", "EPSV", "LIST", "CWD",
+		"PWD", "CWD", "EPSV", "LIST", "CWD",
+		"PWD", "CWD", "EPSV", "LIST", "CWD",
+		"PWD", "CWD", "EPSV", "LIST", "CWD",
+	})
+}
+
+func TestListDataSetsDisabledRawLines(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos", DialWithDisabledRawLines(true))
+
+	datasets, err := c.ListDataSets("HLQ")
+	assert.NoError(t, err)
+	for _, dataset := range datasets {
+		assert.Empty(t, dataset.Raw, dataset.Name)
+	}
+
+	closeConn(t, mock, c, []string{"PWD", "CWD", "EPSV", "LIST", "CWD"})
+}
+
+func TestGDGGeneration(t *testing.T) {
+	tests := []struct {
+		name    string
+		gen     int
+		version int
+		ok      bool
+	}{
+		{"HLQ.GDG.G0012V00", 12, 0, true},
+		{"G9999V99", 9999, 99, true},
+		{"HLQ.GDG", 0, 0, false},
+		{"HLQ.GDG.G12V00", 0, 0, false},
+		{"HLQ.GDG.G0012X00", 0, 0, false},
+		{"HLQ.GDG.G00A2V00", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, test := range tests {
+		gen, version, ok := (&DataSetEntry{Name: test.name}).GDGGeneration()
+		assert.Equal(t, test.gen, gen, test.name)
+		assert.Equal(t, test.version, version, test.name)
+		assert.Equal(t, test.ok, ok, test.name)
+	}
+
+	line := "                                                        HLQ.GDG"
+	entry, err := ParseDataSetListLine(line, time.UTC)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &DataSetEntry{Name: "HLQ.GDG", GDGBase: true, Raw: line}, entry)
+	}
+}
+
+func TestListGDG(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	generations, err := c.ListGDG("HLQ.GDG")
+	assert.NoError(t, err)
+	var names []string
+	for _, generation := range generations {
+		names = append(names, generation.Name)
+	}
+	assert.Equal(t, []string{"G0012V00", "G0011V01", "G0011V00", "G0010V00"}, names)
+
+	closeConn(t, mock, c, []string{"PWD", "CWD", "EPSV", "LIST", "CWD"})
+}
+
+func TestRecordFormatText(t *testing.T) {
+	for f := RecordFormatF; f <= RecordFormatU; f++ {
+		parsed, err := ParseRecordFormat(f.String())
+		assert.NoError(t, err)
+		assert.Equal(t, f, parsed)
+	}
+	for o := PhysicalSequential; o <= VirtualStorageAccessMethod; o++ {
+		parsed, err := ParseDataSetOrganization(o.String())
+		assert.NoError(t, err)
+		assert.Equal(t, o, parsed)
+	}
+
+	_, err := ParseRecordFormat("XX")
+	assert.Error(t, err)
+	_, err = ParseDataSetOrganization("XX")
+	assert.Error(t, err)
+	assert.Equal(t, "RecordFormat(42)", RecordFormat(42).String())
+
+	entry := &DataSetEntry{Name: "ISPF.PROFILE", RecordFormat: RecordFormatFB, Organization: Partitioned}
+	buf, err := json.Marshal(entry)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(buf), `"record_format":"FB"`)
+		assert.Contains(t, string(buf), `"organization":"PO"`)
+
+		var decoded DataSetEntry
+		assert.NoError(t, json.Unmarshal(buf, &decoded))
+		assert.Equal(t, entry, &decoded)
+	}
+
+	var f RecordFormat
+	assert.Error(t, json.Unmarshal([]byte(`"XX"`), &f))
+}
+
+func TestDataSetAllocationSiteCommand(t *testing.T) {
+	alloc := &DataSetAllocation{
+		RecordFormat:        RecordFormatFB,
+		LogicalRecordLength: 80,
+		BlockSize:           27920,
+		Primary:             50,
+		Secondary:           10,
+		SpaceUnit:           SpaceUnitTracks,
+	}
+	assert.Equal(t, "SITE LRECL=80 RECFM=FB BLKSIZE=27920 PRIMARY=50 SECONDARY=10 TRACKS", alloc.SiteCommand())
+
+	alloc = &DataSetAllocation{Directory: 5, StorClass: "SCBASE", Volume: "WYPRC5"}
+	assert.Equal(t, "SITE DIRECTORY=5 STORCLAS=SCBASE VOLUME=WYPRC5", alloc.SiteCommand())
+
+	assert.Empty(t, (&DataSetAllocation{}).SiteCommand())
+}
+
+func TestStorDataSet(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	alloc := &DataSetAllocation{RecordFormat: RecordFormatFB, LogicalRecordLength: 80}
+	err := c.StorDataSet("HLQ.DATA", strings.NewReader("data"), alloc)
+	assert.NoError(t, err)
+	assert.Equal(t, "STOR 'HLQ.DATA'", mock.lastFull)
+
+	err = c.StorDataSet("HLQ.DATA", strings.NewReader("data"), nil)
+	assert.NoError(t, err)
+
+	r, err := c.RetrDataSet("'HLQ.DATA'")
+	if assert.NoError(t, err) {
+		buf, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "data", string(buf))
+		assert.NoError(t, r.Close())
+	}
+	assert.Equal(t, "RETR 'HLQ.DATA'", mock.lastFull)
+
+	err = c.StorDataSet("/u/ibmuser/data", strings.NewReader("data"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "STOR /u/ibmuser/data", mock.lastFull)
+
+	closeConn(t, mock, c, []string{"SITE", "EPSV", "STOR", "EPSV", "STOR", "EPSV", "RETR", "EPSV", "STOR"})
+}
+
+func TestStorDataSetRejected(t *testing.T) {
+	mock, c := openConnScenario(t, "127.0.0.1", "zos")
+
+	err := c.StorDataSet("HLQ.DATA", strings.NewReader("data"), &DataSetAllocation{Unit: "BAD"})
+	assert.Error(t, err)
+
+	closeConn(t, mock, c, []string{"SITE"})
+}
+
+func TestQuoteDataSet(t *testing.T) {
+	assert.Equal(t, "'HLQ.PDS'", QuoteDataSet("HLQ.PDS"))
+	assert.Equal(t, "'HLQ.PDS'", QuoteDataSet("'HLQ.PDS'"))
+	assert.Equal(t, "/u/ibmuser/file.txt", QuoteDataSet("/u/ibmuser/file.txt"))
+
+	assert.Equal(t, "'SYS1.PARMLIB(IEASYS00)'", DataSetMember("SYS1.PARMLIB", "IEASYS00"))
+	assert.Equal(t, "'SYS1.PARMLIB(IEASYS00)'", DataSetMember("'SYS1.PARMLIB'", "IEASYS00"))
+}
+
+func TestParseDataSetListLine(t *testing.T) {
+	referred := time.Date(2021, time.May, 18, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		line     string
+		expected *DataSetEntry
+	}{
+		{
+			"WYPRC5 3390   2021/05/18  1   15  FB      80 27920  PO  ISPF.PROFILE",
+			&DataSetEntry{
+				Name:         "ISPF.PROFILE",
+				Volume:       "WYPRC5",
+				Unit:         "3390",
+				Time:         &referred,
+				Extents:      1,
+				Used:         15,
+				RecordFormat: RecordFormatFB,
+				RecordLength: 80,
+				BlockSize:    27920,
+				Organization: Partitioned,
+			},
+		},
+		{
+			"WYPRC3 3390   2021/05/18  3   90  VB     255 27998  PS  HLQ.LOG.DATA",
+			&DataSetEntry{
+				Name:         "HLQ.LOG.DATA",
+				Volume:       "WYPRC3",
+				Unit:         "3390",
+				Time:         &referred,
+				Extents:      3,
+				Used:         90,
+				RecordFormat: RecordFormatVB,
+				RecordLength: 255,
+				BlockSize:    27998,
+				Organization: PhysicalSequential,
+			},
+		},
+		{
+			"Migrated                                                HLQ.OLD.DATA",
+			&DataSetEntry{Name: "HLQ.OLD.DATA", Status: DataSetStatusMigrated},
+		},
+		{
+			"ARCIVE Not Direct Access Device                         HLQ.TAPE.DATA",
+			&DataSetEntry{Name: "HLQ.TAPE.DATA", Status: DataSetStatusArchived},
+		},
+		{
+			"Error determining attributes                            HLQ.BROKEN",
+			&DataSetEntry{Name: "HLQ.BROKEN", Status: DataSetStatusError},
+		},
+	}
+
+	for _, test := range tests {
+		entry, err := parseDataSetListLine(test.line, time.UTC)
+		if assert.NoError(t, err, test.line) {
+			test.expected.Raw = test.line
+			assert.Equal(t, test.expected, entry, test.line)
+		}
+	}
+
+	for _, line := range []string{
+		"",
+		"Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname",
+		"Migrated",
+		"WYPRC5 3390   2021/05/18  1   15  FB      80 27920  PO",
+		"WYPRC5 3390   2021/13/18  1   15  FB      80 27920  PO  ISPF.PROFILE",
+		"WYPRC5 3390   2021/05/18  x   15  FB      80 27920  PO  ISPF.PROFILE",
+		"WYPRC5 3390   2021/05/18  1   15  XX      80 27920  PO  ISPF.PROFILE",
+		"WYPRC5 3390   2021/05/18  1   15  FB      80 27920  XX  ISPF.PROFILE",
+	} {
+		_, err := ParseDataSetListLine(line, time.UTC)
+		assert.Error(t, err, line)
+	}
+}
+
+// Synthetic catalog listings, written by hand after the column layouts of
+// older and newer z/OS levels rather than captured from real servers, with
+// short volumes, unknown space and extents overflowing their column
+const (
+	syntheticDataSetListingOld = "Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname\r\n" +
+		"WRK1   3390   2021/05/18  1   15  FB      80 27920  PO  ISPF.PROFILE\r\n" +
+		"WYPRC3 3390   2021/05/18 99+ 900  VB     255 27998  PS  HLQ.LOG.DATA\r\n" +
+		"OFFL01 3390   2021/05/18  1    ?  FB      80  3120  PS  HLQ.OFFLINE\r\n"
+	syntheticDataSetListingNew = "Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname\r\n" +
+		"WRK1 3390     2021/05/18  1   15  FB      80 27920  PO  ISPF.PROFILE\r\n" +
+		"WYPRC3 3390   2021/05/18 123+  900  VB     255 27998  PS  HLQ.LOG.DATA\r\n" +
+		"OFFL01 3390   2021/05/18  1     ?  FB      80  3120  PS  HLQ.OFFLINE\r\n"
+)
+
+func TestParseDataSetListLineLevels(t *testing.T) {
+	for _, test := range []struct {
+		listing string
+		extents uint64
+	}{
+		{syntheticDataSetListingOld, 99},
+		{syntheticDataSetListingNew, 123},
+	} {
+		var entries []*DataSetEntry
+		for _, line := range strings.Split(strings.TrimSpace(test.listing), "\r\n")[1:] {
+			entry, err := ParseDataSetListLine(line, time.UTC)
+			if assert.NoError(t, err, line) {
+				entries = append(entries, entry)
+			}
+		}
+		if !assert.Len(t, entries, 3) {
+			continue
+		}
+
+		assert.Equal(t, "WRK1", entries[0].Volume)
+		assert.Equal(t, "3390", entries[0].Unit)
+		assert.Equal(t, "ISPF.PROFILE", entries[0].Name)
+
+		assert.Equal(t, test.extents, entries[1].Extents)
+		assert.Equal(t, uint64(900), entries[1].Used)
+		assert.False(t, entries[1].UsedUnknown)
+
+		assert.Equal(t, uint64(0), entries[2].Used)
+		assert.True(t, entries[2].UsedUnknown)
+		assert.Equal(t, uint64(3120), entries[2].BlockSize)
+	}
+}
+
+// The VSAM lines are synthetic, written after the documented layout of the
+// catalog listing rather than captured from a real server.
+func TestParseDataSetListLineVSAM(t *testing.T) {
+	referred := time.Date(2021, time.May, 18, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		line     string
+		expected *DataSetEntry
+	}{
+		// KSDS cluster and its components
+		{
+			"                                                     VS  HLQ.KSDS",
+			&DataSetEntry{Name: "HLQ.KSDS", Organization: VirtualStorageAccessMethod},
+		},
+		{
+			"VSAM01 3390   2021/05/18  1   45  ?       ?     ?    VS  HLQ.KSDS.DATA",
+			&DataSetEntry{
+				Name:         "HLQ.KSDS.DATA",
+				Volume:       "VSAM01",
+				Unit:         "3390",
+				Time:         &referred,
+				Extents:      1,
+				Used:         45,
+				Organization: VirtualStorageAccessMethod,
+			},
+		},
+		{
+			"VSAM01 3390   2021/05/18  1    1  -       -     -    VS  HLQ.KSDS.INDEX",
+			&DataSetEntry{
+				Name:         "HLQ.KSDS.INDEX",
+				Volume:       "VSAM01",
+				Unit:         "3390",
+				Time:         &referred,
+				Extents:      1,
+				Used:         1,
+				Organization: VirtualStorageAccessMethod,
+			},
+		},
+		// ESDS cluster and its data component, with blank DCB columns
+		{
+			"VSAM                                                 VS  HLQ.ESDS",
+			&DataSetEntry{Name: "HLQ.ESDS", Volume: "VSAM", Organization: VirtualStorageAccessMethod},
+		},
+		{
+			"VSAM02 3390   2021/05/18  2   30                     VS  HLQ.ESDS.DATA",
+			&DataSetEntry{
+				Name:         "HLQ.ESDS.DATA",
+				Volume:       "VSAM02",
+				Unit:         "3390",
+				Time:         &referred,
+				Extents:      2,
+				Used:         30,
+				Organization: VirtualStorageAccessMethod,
+			},
+		},
+		{
+			"VSAM02 3390   2021/05/18  2   30  U    32760 32760   VS  HLQ.LDS.DATA",
+			&DataSetEntry{
+				Name:         "HLQ.LDS.DATA",
+				Volume:       "VSAM02",
+				Unit:         "3390",
+				Time:         &referred,
+				Extents:      2,
+				Used:         30,
+				RecordFormat: RecordFormatU,
+				RecordLength: 32760,
+				BlockSize:    32760,
+				Organization: VirtualStorageAccessMethod,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		entry, err := parseDataSetListLine(test.line, time.UTC)
+		if assert.NoError(t, err, test.line) {
+			test.expected.Raw = test.line
+			assert.Equal(t, test.expected, entry, test.line)
+		}
+	}
+
+	for _, line := range []string{
+		"VSAM01 3390   2021/05/18  x   45  ?       ?     ?    VS  HLQ.KSDS.DATA",
+		"VSAM01 3390   2021/05/18  1   45  XX      ?     ?    VS  HLQ.KSDS.DATA",
+		"VSAM01 3390   2021/05/18  1   45  ?       ?     ?  1 VS  HLQ.KSDS.DATA",
+	} {
+		_, err := ParseDataSetListLine(line, time.UTC)
+		assert.Error(t, err, line)
+	}
+}
+
+func BenchmarkParseDataSetListLine(b *testing.B) {
+	lines := []string{
+		"WYPRC5 3390   2021/05/18  1   15  FB      80 27920  PO  ISPF.PROFILE",
+		"WYPRC3 3390   2021/05/18  3   90  VB     255 27998  PS  HLQ.LOG.DATA",
+		"Migrated                                                HLQ.OLD.DATA",
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			if _, err := parseDataSetListLine(line, time.UTC); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}